@@ -1,21 +1,37 @@
 package main
 
 import (
+	"ai-ocs/internal/ai"
+	"ai-ocs/internal/backup"
+	"ai-ocs/internal/budget"
 	"ai-ocs/internal/database"
 	"ai-ocs/internal/handlers"
 	"ai-ocs/internal/models"
+	"ai-ocs/internal/verifier"
 	"fmt"
 	"log"
 	"net"
+	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// configPath 配置文件路径（相对于工作目录）
+var configPath = filepath.Join("configs", "config.json")
+
 func main() {
-	// 使用相对路径获取配置文件路径
-	configPath := filepath.Join("configs", "config.json")
+	// 执行子命令，如 backup、restore
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != errUnknownCommand {
+			if err != nil {
+				log.Fatalf("%s 执行失败: %v", os.Args[1], err)
+			}
+			return
+		}
+	}
 
 	// 加载配置
 	config, err := models.LoadConfig(configPath)
@@ -28,6 +44,28 @@ func main() {
 		log.Fatalf("数据库初始化失败: %v", err)
 	}
 
+	// 配置AI平台熔断
+	ai.ConfigureHealth(ai.HealthConfig{
+		FailureThreshold: config.ProviderHealth.FailureThreshold,
+		Cooldown:         time.Duration(config.ProviderHealth.CooldownSeconds) * time.Second,
+		Window:           config.ProviderHealth.Window,
+	})
+
+	// 配置API密钥读取方式
+	handlers.ConfigureAPIAuth(config.APIAuth)
+
+	// 启动查询日志异步写入
+	database.StartQueryLogger(config.QueryLog)
+
+	// 启动定时备份
+	backup.StartScheduler(config.Backup, configPath)
+
+	// 启动后台答案复核
+	verifier.Start(config)
+
+	// 初始化全局费用预算
+	budget.Start(config)
+
 	// 设置Gin为发布模式（生产环境）
 	gin.SetMode(gin.ReleaseMode)
 
@@ -35,9 +73,21 @@ func main() {
 	r := gin.Default()
 
 	// 注册API路由
-	r.GET("/api/query", handlers.SearchAnswer(config))
+	r.GET("/api/query", handlers.RequireAPIKey, handlers.RequireScope(models.APIScopeQuery, models.APIScopeCacheOnly),
+		handlers.RateLimit, handlers.SearchAnswer(config))
+	r.POST("/api/query", handlers.RequireAPIKey, handlers.RequireScope(models.APIScopeQuery, models.APIScopeCacheOnly),
+		handlers.RateLimit, handlers.SearchAnswerJSON(config))
+	r.POST("/api/query/batch", handlers.RequireAPIKey, handlers.RequireScope(models.APIScopeQuery, models.APIScopeCacheOnly),
+		handlers.RateLimit, handlers.SearchAnswers(config))
+	r.GET("/api/query/stream", handlers.RequireAPIKey, handlers.RequireScope(models.APIScopeQuery, models.APIScopeCacheOnly),
+		handlers.RateLimit, handlers.StreamAnswer(config))
+	r.POST("/api/query/stream", handlers.RequireAPIKey, handlers.RequireScope(models.APIScopeQuery, models.APIScopeCacheOnly),
+		handlers.RateLimit, handlers.StreamAnswer(config))
+	r.POST("/api/feedback", handlers.RequireAPIKey, handlers.RequireScope(models.APIScopeFeedback),
+		handlers.RateLimit, handlers.SubmitFeedback(config))
 	r.GET("/api/test", handlers.TestHandler) // 添加测试接口
-	r.GET("/api/test-answer", handlers.TestAnswerHandler) // 添加测试答题接口
+	r.GET("/api/test-answer", handlers.RequireAPIKey, handlers.RequireScope(models.APIScopeQuery),
+		handlers.RateLimit, handlers.TestAnswerHandler(config)) // 添加测试答题接口
 
 	// 注册管理后台路由
 	admin := r.Group("/admin")
@@ -48,10 +98,41 @@ func main() {
 		admin.GET("/", handlers.RequireAuth, handlers.AdminPage)
 		admin.GET("/stats", handlers.RequireAuth, handlers.GetStats)
 		admin.GET("/questions", handlers.RequireAuth, handlers.GetQuestions)
+		admin.POST("/questions", handlers.RequireAuth, handlers.CreateQuestion)
+		admin.PUT("/questions/:id", handlers.RequireAuth, handlers.UpdateQuestion)
+		admin.DELETE("/questions/:id", handlers.RequireAuth, handlers.DeleteQuestion)
+		admin.DELETE("/questions", handlers.RequireAuth, handlers.DeleteQuestions)
 		admin.GET("/search", handlers.RequireAuth, handlers.SearchQuestion)
-		admin.GET("/apikeys", handlers.RequireAuth, handlers.GetAPIKeys)
+		admin.GET("/questions/:id/revisions", handlers.RequireAuth, handlers.GetAnswerRevisions)
+		admin.POST("/questions/:id/rollback", handlers.RequireAuth, handlers.RollbackAnswer)
+		admin.POST("/questions/:id/regenerate", handlers.RequireAuth, handlers.RegenerateAnswer(config))
+		admin.POST("/questions/:id/accept", handlers.RequireAuth, handlers.AcceptAnswer)
+		admin.GET("/platforms", handlers.RequireAuth, handlers.GetPlatforms(config))
+		admin.GET("/providers", handlers.RequireAuth, handlers.GetProviderHealth(config))
+		admin.POST("/providers/reset", handlers.RequireAuth, handlers.ResetProviderCircuit)
+		admin.POST("/questions/import", handlers.RequireAuth, handlers.ImportQuestions)
+		admin.GET("/questions/export", handlers.RequireAuth, handlers.ExportQuestions)
+		admin.GET("/apikeys", handlers.RequireAuth, handlers.GetAPIKeys(config))
 		admin.POST("/apikeys", handlers.RequireAuth, handlers.CreateAPIKey)
 		admin.DELETE("/apikeys/:id", handlers.RequireAuth, handlers.DeleteAPIKey)
+		admin.PUT("/apikeys/:id", handlers.RequireAuth, handlers.UpdateAPIKey)
+		admin.POST("/apikeys/:id/rotate", handlers.RequireAuth, handlers.RotateAPIKey)
+		admin.PUT("/apikeys/:id/routing", handlers.RequireAuth, handlers.UpdateAPIKeyRouting(config))
+		admin.PUT("/apikeys/:id/limits", handlers.RequireAuth, handlers.UpdateAPIKeyLimits)
+		admin.GET("/costs", handlers.RequireAuth, handlers.GetCostReport)
+		admin.GET("/budget", handlers.RequireAuth, handlers.GetBudgetStatus)
+		admin.POST("/budget/trip", handlers.RequireAuth, handlers.TripBudget)
+		admin.POST("/budget/resume", handlers.RequireAuth, handlers.ResumeBudget)
+		admin.GET("/querylogs", handlers.RequireAuth, handlers.GetQueryLogs)
+		admin.GET("/verifier", handlers.RequireAuth, handlers.GetVerifierStatus)
+		admin.POST("/verifier/pause", handlers.RequireAuth, handlers.PauseVerifier)
+		admin.POST("/verifier/resume", handlers.RequireAuth, handlers.ResumeVerifier)
+		admin.POST("/verifier/run", handlers.RequireAuth, handlers.RunVerifier)
+		admin.GET("/verifications", handlers.RequireAuth, handlers.GetVerifications)
+		admin.GET("/review", handlers.RequireAuth, handlers.GetReviewQueue)
+		admin.POST("/review", handlers.RequireAuth, handlers.ReviewQuestions)
+		admin.GET("/feedback", handlers.RequireAuth, handlers.GetFeedbackQueue)
+		admin.POST("/feedback/:id/resolve", handlers.RequireAuth, handlers.ResolveFeedback)
 	}
 
 	// 启动服务器
@@ -86,23 +167,23 @@ func printAPIConfig(config *models.Config, actualPort int) {
 		host = "127.0.0.1"
 	}
 
-	// 获取默认API密钥
-	defaultAPIKey, err := database.GetDefaultAPIKey()
-	if err != nil {
-		log.Printf("获取默认API密钥失败: %v", err)
-		defaultAPIKey = "生成api-key"
-	}
+	// 数据库中只保存密钥的哈希值，配置中的密钥需替换为在管理后台创建的密钥；
+	// 密钥放在请求头中，避免出现在访问日志里
+	defaultAPIKey := "替换为你的API密钥"
 
 	// 构造API配置信息
 	url := fmt.Sprintf("http://%s:%d/api/query", host, actualPort)
-	jsonStr := fmt.Sprintf("{\n  \"name\": \"完美题库\",\n  \"homepage\": \"https://currso.com/\",\n  \"url\": \"%s\",\n  \"method\": \"get\",\n  \"type\": \"GM_xmlhttpRequest\",\n  \"contentType\": \"json\",\n  \"data\": {\n    \"title\": \"${title}\",\n    \"options\": \"${options}\",\n    \"type\": \"${type}\",\n    \"api-key\": \"%s\"\n  },\n  \"handler\": \"return (res)=>res.code === 0 ? [undefined, undefined] : [undefined,res.data.data]\"\n}", url, defaultAPIKey)
+	jsonStr := fmt.Sprintf("{\n  \"name\": \"完美题库\",\n  \"homepage\": \"https://currso.com/\",\n  \"url\": \"%s\",\n  \"method\": \"get\",\n  \"type\": \"GM_xmlhttpRequest\",\n  \"contentType\": \"json\",\n  \"headers\": {\n    \"X-API-Key\": \"%s\"\n  },\n  \"data\": {\n    \"title\": \"${title}\",\n    \"options\": \"${options}\",\n    \"type\": \"${type}\"\n  },\n  \"handler\": \"return (res)=>res.code === 0 ? [undefined, undefined] : [undefined,res.data.data]\"\n}", url, defaultAPIKey)
 
 	log.Printf("API配置信息:\n%s", jsonStr)
+	if !config.APIAuth.AllowQueryKey {
+		log.Printf("旧的OCS配置在查询参数中传递 api-key，如无法改用请求头，需在配置文件中设置 \"api_auth\": {\"allow_query_key\": true}")
+	}
 }
 
 // isPortAvailable 检查端口是否可用
 func isPortAvailable(host string, port int) bool {
-	address := fmt.Sprintf("%s:%d", host, port)
+	address := net.JoinHostPort(host, strconv.Itoa(port))
 	conn, err := net.DialTimeout("tcp", address, 1*time.Second)
 	if err != nil {
 		return true
@@ -2,13 +2,30 @@ package ai
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 )
 
+// Usage 模型调用的token用量
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// Result 模型调用结果，包含答案及调用信息
+type Result struct {
+	Answer   string
+	Platform string
+	Model    string
+	Usage    Usage
+}
+
 // AIResponse AI模型响应结构
 type AIResponse struct {
 	Choices []struct {
@@ -16,6 +33,7 @@ type AIResponse struct {
 			Content string `json:"content"`
 		} `json:"message"`
 	} `json:"choices"`
+	Usage Usage `json:"usage"`
 }
 
 // ZhipuAIResponse 智普AI模型响应结构
@@ -25,6 +43,7 @@ type ZhipuAIResponse struct {
 			Content string `json:"content"`
 		} `json:"message"`
 	} `json:"choices"`
+	Usage Usage `json:"usage"`
 }
 
 // OllamaResponse Ollama模型响应结构
@@ -36,6 +55,8 @@ type OllamaResponse struct {
 		Content string `json:"content"`
 	} `json:"message"`
 	Done bool `json:"done"`
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
 }
 
 // GeminiResponse Gemini模型响应结构
@@ -47,6 +68,10 @@ type GeminiResponse struct {
 			} `json:"parts"`
 		} `json:"content"`
 	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
 }
 
 // QueryRequest 查询请求结构
@@ -103,40 +128,158 @@ type GeminiRequest struct {
 	} `json:"generationConfig"`
 }
 
+// Platforms 支持的AI平台，未知的平台名会回退到 siliconflow
+var Platforms = []string{"siliconflow", "aliyun", "zhipu", "ollama", "deepseek", "chatgpt", "gemini"}
+
+// IsPlatform 判断是否为支持的AI平台
+func IsPlatform(platform string) bool {
+	for _, p := range Platforms {
+		if p == platform {
+			return true
+		}
+	}
+	return false
+}
+
 // QueryLargeModel 调用AI模型获取问题答案
 func QueryLargeModel(title, options, questionType, platform string, apiKeys map[string]string, models map[string]string) (string, error) {
+	result, err := Query(title, options, questionType, platform, apiKeys, models)
+	if err != nil {
+		return "", err
+	}
+	return result.Answer, nil
+}
+
+// QueryModel 与 Query 相同，但使用指定的模型，model 为空时使用 models 中配置的模型
+func QueryModel(title, options, questionType, platform, model string, apiKeys map[string]string, models map[string]string) (*Result, error) {
+	if model == "" {
+		return Query(title, options, questionType, platform, apiKeys, models)
+	}
+
+	return Query(title, options, questionType, platform, apiKeys, withModel(models, platform, model))
+}
+
+// withModel 返回将 platform 的模型替换为 model 的模型配置，model 为空时原样返回
+func withModel(models map[string]string, platform, model string) map[string]string {
+	if model == "" {
+		return models
+	}
+
+	// 复制一份模型配置，避免修改调用方的映射
+	override := make(map[string]string, len(models)+1)
+	for k, v := range models {
+		override[k] = v
+	}
+	override[platform] = model
+	return override
+}
+
+// Query 调用AI模型获取问题答案，并返回实际使用的平台、模型和token用量
+func Query(title, options, questionType, platform string, apiKeys map[string]string, models map[string]string) (*Result, error) {
+	content, err := BuildPrompt("", title, options, questionType)
+	if err != nil {
+		return nil, err
+	}
+	return queryPlatform(context.Background(), content, platform, apiKeys, models, nil)
+}
+
+// Route 一次AI调用使用的平台和模型，Model 为空时使用全局配置的模型
+type Route struct {
+	Platform string
+	Model    string
+}
+
+// QueryRoutes 按顺序尝试 routes 中的平台，跳过熔断中的平台，返回第一个成功的结果，全部失败时返回最后一次的错误。
+// prompt 为提示词模板，为空时使用默认模板
+func QueryRoutes(title, options, questionType string, routes []Route, prompt string, apiKeys map[string]string, models map[string]string) (*Result, error) {
+	return QueryRoutesStream(context.Background(), title, options, questionType, routes, prompt, apiKeys, models, nil)
+}
+
+// QueryRoutesStream 与 QueryRoutes 相同，stream 不为空时以流式调用平台，并通过 stream 的回调返回调用的平台和模型输出。
+// 流式调用随 ctx 取消，取消后不再尝试后面的平台
+func QueryRoutesStream(ctx context.Context, title, options, questionType string, routes []Route, prompt string, apiKeys map[string]string, models map[string]string, stream *StreamHandler) (*Result, error) {
+	content, err := BuildPrompt(prompt, title, options, questionType)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *Result
+	for _, route := range routes {
+		result, err = queryPlatform(ctx, content, route.Platform, apiKeys, withModel(models, route.Platform, route.Model), stream)
+		if err == nil || ctx.Err() != nil {
+			return result, err
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("没有可用的AI平台")
+	}
+	return nil, err
+}
+
+// queryPlatform 用已生成的提示词调用指定平台，平台熔断中时不发出请求，直接返回 CircuitOpenError。
+// stream 不为空时以流式调用平台，调用方取消 ctx 导致的失败不计入平台的熔断统计
+func queryPlatform(ctx context.Context, content, platform string, apiKeys map[string]string, models map[string]string, stream *StreamHandler) (*Result, error) {
+	if !IsPlatform(platform) {
+		platform = "siliconflow"
+	}
+
+	start := time.Now()
+	probe, err := acquire(platform, models[platform], start)
+	if err != nil {
+		return nil, err
+	}
+
+	var answer string
+	var usage Usage
+
+	if stream != nil {
+		stream.route(platform, models[platform])
+		answer, usage, err = streamPlatform(ctx, content, platform, apiKeys, models, stream)
+	} else {
+		answer, usage, err = callPlatform(content, platform, apiKeys, models)
+	}
+	if err != nil && ctx.Err() != nil {
+		abandon(platform, models[platform], probe)
+		return nil, ctx.Err()
+	}
+	report(platform, models[platform], probe, time.Since(start), err, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Answer:   answer,
+		Platform: platform,
+		Model:    models[platform],
+		Usage:    usage,
+	}, nil
+}
+
+// callPlatform 以非流式调用平台
+func callPlatform(content, platform string, apiKeys map[string]string, models map[string]string) (answer string, usage Usage, err error) {
 	switch platform {
 	case "siliconflow":
-		return querySiliconFlow(title, options, questionType, apiKeys["siliconflow"], models["siliconflow"])
+		answer, usage, err = querySiliconFlow(content, apiKeys["siliconflow"], models["siliconflow"])
 	case "aliyun":
-		return queryAliyunBailian(title, options, questionType, apiKeys["aliyun"], models["aliyun"])
+		answer, usage, err = queryAliyunBailian(content, apiKeys["aliyun"], models["aliyun"])
 	case "zhipu":
-		return queryZhipuAI(title, options, questionType, apiKeys["zhipu"], models["zhipu"])
+		answer, usage, err = queryZhipuAI(content, apiKeys["zhipu"], models["zhipu"])
 	case "ollama":
-		return queryOllama(title, options, questionType, models["ollama"])
+		answer, usage, err = queryOllama(content, models["ollama"])
 	case "deepseek":
-		return queryDeepSeek(title, options, questionType, apiKeys["deepseek"], models["deepseek"])
+		answer, usage, err = queryDeepSeek(content, apiKeys["deepseek"], models["deepseek"])
 	case "chatgpt":
-		return queryChatGPT(title, options, questionType, apiKeys["chatgpt"], models["chatgpt"])
+		answer, usage, err = queryChatGPT(content, apiKeys["chatgpt"], models["chatgpt"])
 	case "gemini":
-		return queryGemini(title, options, questionType, apiKeys["gemini"], models["gemini"])
-	default:
-		return querySiliconFlow(title, options, questionType, apiKeys["siliconflow"], models["siliconflow"])
+		answer, usage, err = queryGemini(content, apiKeys["gemini"], models["gemini"])
 	}
+	return answer, usage, err
 }
 
 // querySiliconFlow 调用SiliconFlow API获取问题答案
-func querySiliconFlow(title, options, questionType, apiKey, model string) (string, error) {
+func querySiliconFlow(content, apiKey, model string) (string, Usage, error) {
 	url := "https://api.siliconflow.cn/v1/chat/completions"
 
-	// 构建简化的提问内容，减少token数量
-	content := fmt.Sprintf(`你是题库接口，根据问题和选项提供答案。选择题返回选项内容；多选题用###连接；判断题返回"对"或"错"；填空题用###连接多个空。格式：{"anwser":"答案"}。只返回json格式。
-{
-	"问题": "%s",
-	"选项": "%s",
-	"类型": "%s"
-}`, title, options, questionType)
-
 	// 构建请求体
 	requestBody := QueryRequest{
 		Model:     model,
@@ -156,13 +299,13 @@ func querySiliconFlow(title, options, questionType, apiKey, model string) (strin
 	// 转换为JSON
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 
 	// 创建HTTP请求
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 
 	// 设置请求头
@@ -175,48 +318,40 @@ func querySiliconFlow(title, options, questionType, apiKey, model string) (strin
 	}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 
 	// 检查HTTP状态码
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Sprintf("API调用失败，状态码: %d", resp.StatusCode), nil
+		return "API调用失败", Usage{}, statusError(resp.StatusCode, body)
 	}
 
 	// 解析响应
 	var aiResp AIResponse
 	err = json.Unmarshal(body, &aiResp)
 	if err != nil {
-		return "无法解析API响应", err
+		return "无法解析API响应", Usage{}, err
 	}
 
 	// 提取答案
 	if len(aiResp.Choices) > 0 {
-		return aiResp.Choices[0].Message.Content, nil
+		return aiResp.Choices[0].Message.Content, aiResp.Usage, nil
 	}
 
-	return "无法从API获取答案", nil
+	return "无法从API获取答案", Usage{}, errNoAnswer
 }
 
 // queryAliyunBailian 调用阿里云百炼平台API获取问题答案
-func queryAliyunBailian(title, options, questionType, apiKey, model string) (string, error) {
+func queryAliyunBailian(content, apiKey, model string) (string, Usage, error) {
 	url := "https://dashscope.aliyuncs.com/compatible-mode/v1/chat/completions"
 
-	// 构建简化的提问内容，减少token数量
-	content := fmt.Sprintf(`你是题库接口，根据问题和选项提供答案。选择题返回选项内容；多选题用###连接；判断题返回"对"或"错"；填空题用###连接多个空。格式：{"anwser":"答案"}。只返回json格式。
-{
-	"问题": "%s",
-	"选项": "%s",
-	"类型": "%s"
-}`, title, options, questionType)
-
 	// 构建请求体
 	requestBody := QueryRequest{
 		Model:     model,
@@ -235,13 +370,13 @@ func queryAliyunBailian(title, options, questionType, apiKey, model string) (str
 	// 转换为JSON
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 
 	// 创建HTTP请求
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 
 	// 设置请求头，使用阿里云的API密钥
@@ -255,48 +390,40 @@ func queryAliyunBailian(title, options, questionType, apiKey, model string) (str
 	}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 
 	// 检查HTTP状态码
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Sprintf("API调用失败，状态码: %d", resp.StatusCode), nil
+		return "API调用失败", Usage{}, statusError(resp.StatusCode, body)
 	}
 
 	// 解析响应
 	var aiResp AIResponse
 	err = json.Unmarshal(body, &aiResp)
 	if err != nil {
-		return "无法解析API响应: " + err.Error(), err
+		return "无法解析API响应: " + err.Error(), Usage{}, err
 	}
 
 	// 提取答案
 	if len(aiResp.Choices) > 0 {
-		return aiResp.Choices[0].Message.Content, nil
+		return aiResp.Choices[0].Message.Content, aiResp.Usage, nil
 	}
 
-	return "无法从API获取答案", nil
+	return "无法从API获取答案", Usage{}, errNoAnswer
 }
 
 // queryZhipuAI 调用智普AI平台API获取问题答案
-func queryZhipuAI(title, options, questionType, apiKey, model string) (string, error) {
+func queryZhipuAI(content, apiKey, model string) (string, Usage, error) {
 	url := "https://open.bigmodel.cn/api/paas/v4/chat/completions"
 
-	// 构建简化的提问内容，减少token数量
-	content := fmt.Sprintf(`你是题库接口，根据问题和选项提供答案。选择题返回选项内容；多选题用###连接；判断题返回"对"或"错"；填空题用###连接多个空。格式：{"anwser":"答案"}。只返回json格式。
-{
-	"问题": "%s",
-	"选项": "%s",
-	"类型": "%s"
-}`, title, options, questionType)
-
 	// 构建请求体
 	requestBody := ZhipuAIRequest{
 		Model:       model,
@@ -313,13 +440,13 @@ func queryZhipuAI(title, options, questionType, apiKey, model string) (string, e
 	// 转换为JSON
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 
 	// 创建HTTP请求
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 
 	// 设置请求头
@@ -332,48 +459,40 @@ func queryZhipuAI(title, options, questionType, apiKey, model string) (string, e
 	}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 
 	// 检查HTTP状态码
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Sprintf("API调用失败，状态码: %d", resp.StatusCode), nil
+		return "API调用失败", Usage{}, statusError(resp.StatusCode, body)
 	}
 
 	// 解析响应
 	var aiResp ZhipuAIResponse
 	err = json.Unmarshal(body, &aiResp)
 	if err != nil {
-		return "无法解析API响应: " + err.Error(), err
+		return "无法解析API响应: " + err.Error(), Usage{}, err
 	}
 
 	// 提取答案
 	if len(aiResp.Choices) > 0 {
-		return aiResp.Choices[0].Message.Content, nil
+		return aiResp.Choices[0].Message.Content, aiResp.Usage, nil
 	}
 
-	return "无法从API获取答案", nil
+	return "无法从API获取答案", Usage{}, errNoAnswer
 }
 
 // queryOllama 调用Ollama本地模型获取问题答案
-func queryOllama(title, options, questionType, model string) (string, error) {
+func queryOllama(content, model string) (string, Usage, error) {
 	url := "http://localhost:11434/api/generate"
 
-	// 构建简化的提问内容，减少token数量
-	content := fmt.Sprintf(`你是题库接口，根据问题和选项提供答案。选择题返回选项内容；多选题用###连接；判断题返回"对"或"错"；填空题用###连接多个空。格式：{"anwser":"答案"}。只返回json格式。
-{
-	"问题": "%s",
-	"选项": "%s",
-	"类型": "%s"
-}`, title, options, questionType)
-
 	// 构建请求体
 	requestBody := OllamaRequest{
 		Model:  model,
@@ -385,13 +504,13 @@ func queryOllama(title, options, questionType, model string) (string, error) {
 	// 转换为JSON
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 
 	// 创建HTTP请求
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 
 	// 设置请求头
@@ -403,44 +522,36 @@ func queryOllama(title, options, questionType, model string) (string, error) {
 	}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 
 	// 检查HTTP状态码
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Sprintf("API调用失败，状态码: %d", resp.StatusCode), nil
+		return "API调用失败", Usage{}, statusError(resp.StatusCode, body)
 	}
 
 	// 解析响应
 	var aiResp OllamaResponse
 	err = json.Unmarshal(body, &aiResp)
 	if err != nil {
-		return "无法解析API响应: " + err.Error(), err
+		return "无法解析API响应: " + err.Error(), Usage{}, err
 	}
 
 	// 提取答案
-	return aiResp.Message.Content, nil
+	return aiResp.Message.Content, Usage{PromptTokens: aiResp.PromptEvalCount, CompletionTokens: aiResp.EvalCount}, nil
 }
 
 // queryDeepSeek 调用DeepSeek官方API获取问题答案
-func queryDeepSeek(title, options, questionType, apiKey, model string) (string, error) {
+func queryDeepSeek(content, apiKey, model string) (string, Usage, error) {
 	url := "https://api.deepseek.com/chat/completions"
 
-	// 构建简化的提问内容，减少token数量
-	content := fmt.Sprintf(`你是题库接口，根据问题和选项提供答案。选择题返回选项内容；多选题用###连接；判断题返回"对"或"错"；填空题用###连接多个空。格式：{"anwser":"答案"}。只返回json格式。
-{
-	"问题": "%s",
-	"选项": "%s",
-	"类型": "%s"
-}`, title, options, questionType)
-
 	// 构建请求体
 	requestBody := QueryRequest{
 		Model:     model,
@@ -459,13 +570,13 @@ func queryDeepSeek(title, options, questionType, apiKey, model string) (string,
 	// 转换为JSON
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 
 	// 创建HTTP请求
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 
 	// 设置请求头
@@ -478,48 +589,40 @@ func queryDeepSeek(title, options, questionType, apiKey, model string) (string,
 	}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 
 	// 检查HTTP状态码
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Sprintf("API调用失败，状态码: %d", resp.StatusCode), nil
+		return "API调用失败", Usage{}, statusError(resp.StatusCode, body)
 	}
 
 	// 解析响应
 	var aiResp AIResponse
 	err = json.Unmarshal(body, &aiResp)
 	if err != nil {
-		return "无法解析API响应: " + err.Error(), err
+		return "无法解析API响应: " + err.Error(), Usage{}, err
 	}
 
 	// 提取答案
 	if len(aiResp.Choices) > 0 {
-		return aiResp.Choices[0].Message.Content, nil
+		return aiResp.Choices[0].Message.Content, aiResp.Usage, nil
 	}
 
-	return "无法从API获取答案", nil
+	return "无法从API获取答案", Usage{}, errNoAnswer
 }
 
 // queryChatGPT 调用ChatGPT API获取问题答案
-func queryChatGPT(title, options, questionType, apiKey, model string) (string, error) {
+func queryChatGPT(content, apiKey, model string) (string, Usage, error) {
 	url := "https://api.openai.com/v1/chat/completions"
 
-	// 构建简化的提问内容，减少token数量
-	content := fmt.Sprintf(`你是题库接口，根据问题和选项提供答案。选择题返回选项内容；多选题用###连接；判断题返回"对"或"错"；填空题用###连接多个空。格式：{"anwser":"答案"}。只返回json格式。
-{
-	"问题": "%s",
-	"选项": "%s",
-	"类型": "%s"
-}`, title, options, questionType)
-
 	// 构建请求体
 	requestBody := QueryRequest{
 		Model:     model,
@@ -538,13 +641,13 @@ func queryChatGPT(title, options, questionType, apiKey, model string) (string, e
 	// 转换为JSON
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 
 	// 创建HTTP请求
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 
 	// 设置请求头
@@ -557,48 +660,40 @@ func queryChatGPT(title, options, questionType, apiKey, model string) (string, e
 	}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 
 	// 检查HTTP状态码
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Sprintf("API调用失败，状态码: %d", resp.StatusCode), nil
+		return "API调用失败", Usage{}, statusError(resp.StatusCode, body)
 	}
 
 	// 解析响应
 	var aiResp AIResponse
 	err = json.Unmarshal(body, &aiResp)
 	if err != nil {
-		return "无法解析API响应: " + err.Error(), err
+		return "无法解析API响应: " + err.Error(), Usage{}, err
 	}
 
 	// 提取答案
 	if len(aiResp.Choices) > 0 {
-		return aiResp.Choices[0].Message.Content, nil
+		return aiResp.Choices[0].Message.Content, aiResp.Usage, nil
 	}
 
-	return "无法从API获取答案", nil
+	return "无法从API获取答案", Usage{}, errNoAnswer
 }
 
 // queryGemini 调用Gemini API获取问题答案
-func queryGemini(title, options, questionType, apiKey, model string) (string, error) {
+func queryGemini(content, apiKey, model string) (string, Usage, error) {
 	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s", model, apiKey)
 
-	// 构建简化的提问内容，减少token数量
-	content := fmt.Sprintf(`你是题库接口，根据问题和选项提供答案。选择题返回选项内容；多选题用###连接；判断题返回"对"或"错"；填空题用###连接多个空。格式：{"anwser":"答案"}。只返回json格式。
-{
-	"问题": "%s",
-	"选项": "%s",
-	"类型": "%s"
-}`, title, options, questionType)
-
 	// 构建请求体
 	requestBody := GeminiRequest{}
 	requestBody.Contents = append(requestBody.Contents, struct {
@@ -618,13 +713,13 @@ func queryGemini(title, options, questionType, apiKey, model string) (string, er
 	// 转换为JSON
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 
 	// 创建HTTP请求
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 
 	// 设置请求头
@@ -636,32 +731,47 @@ func queryGemini(title, options, questionType, apiKey, model string) (string, er
 	}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "API调用失败", err
+		return "API调用失败", Usage{}, err
 	}
 
 	// 检查HTTP状态码
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Sprintf("API调用失败，状态码: %d", resp.StatusCode), nil
+		return "API调用失败", Usage{}, statusError(resp.StatusCode, body)
 	}
 
 	// 解析响应
 	var aiResp GeminiResponse
 	err = json.Unmarshal(body, &aiResp)
 	if err != nil {
-		return "无法解析API响应: " + err.Error(), err
+		return "无法解析API响应: " + err.Error(), Usage{}, err
 	}
 
 	// 提取答案
 	if len(aiResp.Candidates) > 0 && len(aiResp.Candidates[0].Content.Parts) > 0 {
-		return aiResp.Candidates[0].Content.Parts[0].Text, nil
+		return aiResp.Candidates[0].Content.Parts[0].Text, Usage{PromptTokens: aiResp.UsageMetadata.PromptTokenCount, CompletionTokens: aiResp.UsageMetadata.CandidatesTokenCount}, nil
 	}
 
-	return "无法从API获取答案", nil
-}
\ No newline at end of file
+	return "无法从API获取答案", Usage{}, errNoAnswer
+}
+
+// errNoAnswer 平台返回成功但响应中没有答案
+var errNoAnswer = errors.New("无法从API获取答案")
+
+// statusError 平台返回非200状态码时的错误，附带截断后的响应内容便于排查
+func statusError(statusCode int, body []byte) error {
+	msg := strings.TrimSpace(string(body))
+	if r := []rune(msg); len(r) > 200 {
+		msg = string(r[:200]) + "…"
+	}
+	if msg == "" {
+		return fmt.Errorf("API调用失败，状态码: %d", statusCode)
+	}
+	return fmt.Errorf("API调用失败，状态码: %d，响应: %s", statusCode, msg)
+}
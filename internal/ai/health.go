@@ -0,0 +1,271 @@
+package ai
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 熔断器状态
+const (
+	CircuitClosed   = "closed"    // 正常调用
+	CircuitOpen     = "open"      // 熔断中，直接跳过该平台
+	CircuitHalfOpen = "half-open" // 冷却结束，正在用一次请求试探平台是否恢复
+)
+
+// HealthConfig 平台熔断配置
+type HealthConfig struct {
+	FailureThreshold int           // 连续失败多少次后熔断
+	Cooldown         time.Duration // 熔断后多久允许一次试探请求
+	Window           int           // 统计错误率和延迟的最近调用次数
+}
+
+// ProviderHealth 一个平台和模型最近调用的健康状况
+type ProviderHealth struct {
+	Platform            string     `json:"platform"`
+	Model               string     `json:"model"`
+	State               string     `json:"state"`
+	Requests            int        `json:"requests"` // 统计窗口内的调用次数
+	Failures            int        `json:"failures"` // 统计窗口内的失败次数
+	ErrorRate           float64    `json:"error_rate"`
+	AvgLatencyMs        int64      `json:"avg_latency_ms"`
+	P95LatencyMs        int64      `json:"p95_latency_ms"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	TotalRequests       int64      `json:"total_requests"` // 服务启动以来的调用次数
+	TotalFailures       int64      `json:"total_failures"`
+	Skipped             int64      `json:"skipped"` // 熔断期间跳过的请求数
+	LastError           string     `json:"last_error,omitempty"`
+	LastErrorAt         *time.Time `json:"last_error_at,omitempty"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"` // 熔断时下一次允许试探的时间
+}
+
+// CircuitOpenError 平台熔断中，请求未发出
+type CircuitOpenError struct {
+	Platform string
+	Model    string
+	RetryAt  time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s/%s 连续调用失败，暂停使用至 %s", e.Platform, e.Model, e.RetryAt.Format("15:04:05"))
+}
+
+// providerKey 熔断器按平台和模型区分，避免某个模型配置错误影响同一平台的其他模型
+type providerKey struct {
+	platform string
+	model    string
+}
+
+// callOutcome 一次调用的结果
+type callOutcome struct {
+	ok      bool
+	latency time.Duration
+}
+
+// providerState 一个平台和模型的熔断器及最近调用记录
+type providerState struct {
+	outcomes      []callOutcome // 环形缓冲区，保存最近 Window 次调用
+	next          int
+	consecutive   int
+	state         string
+	openedAt      time.Time
+	probing       bool
+	total         int64
+	totalFailures int64
+	skipped       int64
+	lastError     string
+	lastErrorAt   time.Time
+	lastSuccessAt time.Time
+}
+
+var health = struct {
+	sync.Mutex
+	cfg       HealthConfig
+	providers map[providerKey]*providerState
+}{
+	cfg:       HealthConfig{FailureThreshold: 5, Cooldown: 30 * time.Second, Window: 50},
+	providers: make(map[providerKey]*providerState),
+}
+
+// ConfigureHealth 设置熔断配置，值不大于0的项保留默认值
+func ConfigureHealth(cfg HealthConfig) {
+	health.Lock()
+	defer health.Unlock()
+	if cfg.FailureThreshold > 0 {
+		health.cfg.FailureThreshold = cfg.FailureThreshold
+	}
+	if cfg.Cooldown > 0 {
+		health.cfg.Cooldown = cfg.Cooldown
+	}
+	if cfg.Window > 0 {
+		health.cfg.Window = cfg.Window
+	}
+}
+
+// providerLocked 获取平台和模型的状态，不存在时创建；需持有 health 锁
+func providerLocked(k providerKey) *providerState {
+	p := health.providers[k]
+	if p == nil {
+		p = &providerState{state: CircuitClosed}
+		health.providers[k] = p
+	}
+	return p
+}
+
+// acquire 判断是否可以调用平台。熔断中的平台在冷却结束后只放行一次试探请求，probe 为 true 表示本次是试探请求
+func acquire(platform, model string, now time.Time) (probe bool, err error) {
+	health.Lock()
+	defer health.Unlock()
+
+	p := providerLocked(providerKey{platform, model})
+	if p.state == CircuitClosed {
+		return false, nil
+	}
+
+	retryAt := p.openedAt.Add(health.cfg.Cooldown)
+	if p.state == CircuitOpen && !now.Before(retryAt) {
+		p.state = CircuitHalfOpen
+		p.probing = true
+		return true, nil
+	}
+	p.skipped++
+	return false, &CircuitOpenError{Platform: platform, Model: model, RetryAt: retryAt}
+}
+
+// abandon 调用被调用方取消，不记录结果；试探请求被取消时恢复为熔断状态，下一次请求重新试探
+func abandon(platform, model string, probe bool) {
+	if !probe {
+		return
+	}
+	health.Lock()
+	defer health.Unlock()
+
+	p := providerLocked(providerKey{platform, model})
+	p.probing = false
+	if p.state == CircuitHalfOpen {
+		p.state = CircuitOpen
+	}
+}
+
+// report 记录一次调用的结果并更新熔断器状态
+func report(platform, model string, probe bool, latency time.Duration, err error, now time.Time) {
+	health.Lock()
+	defer health.Unlock()
+
+	p := providerLocked(providerKey{platform, model})
+	outcome := callOutcome{ok: err == nil, latency: latency}
+	if len(p.outcomes) < health.cfg.Window {
+		p.outcomes = append(p.outcomes, outcome)
+	} else {
+		p.outcomes[p.next%len(p.outcomes)] = outcome
+	}
+	p.next++
+	p.total++
+
+	if probe {
+		p.probing = false
+	}
+
+	if err == nil {
+		p.consecutive = 0
+		p.lastSuccessAt = now
+		p.state = CircuitClosed
+		return
+	}
+
+	p.totalFailures++
+	p.consecutive++
+	p.lastError = err.Error()
+	p.lastErrorAt = now
+	// 试探失败时重新开始冷却
+	if probe || (p.state == CircuitClosed && p.consecutive >= health.cfg.FailureThreshold) {
+		p.state = CircuitOpen
+		p.openedAt = now
+	}
+}
+
+// ResetCircuit 手动关闭平台和模型的熔断器
+func ResetCircuit(platform, model string) {
+	health.Lock()
+	defer health.Unlock()
+
+	p := providerLocked(providerKey{platform, model})
+	p.state = CircuitClosed
+	p.consecutive = 0
+	p.probing = false
+}
+
+// Health 返回平台和模型最近调用的健康状况
+func Health(platform, model string) ProviderHealth {
+	health.Lock()
+	defer health.Unlock()
+
+	return healthLocked(providerKey{platform, model})
+}
+
+// AllHealth 返回所有调用过的平台和模型的健康状况，按平台和模型排序
+func AllHealth() []ProviderHealth {
+	health.Lock()
+	defer health.Unlock()
+
+	list := make([]ProviderHealth, 0, len(health.providers))
+	for k := range health.providers {
+		list = append(list, healthLocked(k))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Platform != list[j].Platform {
+			return list[i].Platform < list[j].Platform
+		}
+		return list[i].Model < list[j].Model
+	})
+	return list
+}
+
+// healthLocked 汇总一个平台和模型的健康状况；需持有 health 锁
+func healthLocked(k providerKey) ProviderHealth {
+	h := ProviderHealth{Platform: k.platform, Model: k.model, State: CircuitClosed}
+	p := health.providers[k]
+	if p == nil {
+		return h
+	}
+
+	h.State = p.state
+	h.ConsecutiveFailures = p.consecutive
+	h.TotalRequests = p.total
+	h.TotalFailures = p.totalFailures
+	h.Skipped = p.skipped
+	h.LastError = p.lastError
+	if !p.lastErrorAt.IsZero() {
+		t := p.lastErrorAt
+		h.LastErrorAt = &t
+	}
+	if !p.lastSuccessAt.IsZero() {
+		t := p.lastSuccessAt
+		h.LastSuccessAt = &t
+	}
+	if p.state != CircuitClosed {
+		opened, retry := p.openedAt, p.openedAt.Add(health.cfg.Cooldown)
+		h.OpenedAt, h.RetryAt = &opened, &retry
+	}
+
+	latencies := make([]time.Duration, 0, len(p.outcomes))
+	var sum time.Duration
+	for _, o := range p.outcomes {
+		if !o.ok {
+			h.Failures++
+		}
+		sum += o.latency
+		latencies = append(latencies, o.latency)
+	}
+	h.Requests = len(p.outcomes)
+	if h.Requests > 0 {
+		h.ErrorRate = float64(h.Failures) / float64(h.Requests)
+		h.AvgLatencyMs = (sum / time.Duration(h.Requests)).Milliseconds()
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		h.P95LatencyMs = latencies[(len(latencies)*95+99)/100-1].Milliseconds()
+	}
+	return h
+}
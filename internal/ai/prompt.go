@@ -0,0 +1,43 @@
+package ai
+
+import (
+	"strings"
+	"text/template"
+)
+
+// DefaultPromptTemplate 默认的提示词模板，模板中可以使用 {{.Title}}、{{.Options}}、{{.Type}}
+const DefaultPromptTemplate = `你是题库接口，根据问题和选项提供答案。选择题返回选项内容；多选题用###连接；判断题返回"对"或"错"；填空题用###连接多个空。格式：{"anwser":"答案"}。只返回json格式。
+{
+	"问题": "{{.Title}}",
+	"选项": "{{.Options}}",
+	"类型": "{{.Type}}"
+}`
+
+// promptData 渲染提示词模板的数据
+type promptData struct {
+	Title   string
+	Options string
+	Type    string
+}
+
+// ParsePromptTemplate 解析提示词模板，用于检查模板语法
+func ParsePromptTemplate(text string) (*template.Template, error) {
+	return template.New("prompt").Option("missingkey=error").Parse(text)
+}
+
+// BuildPrompt 用题目内容渲染提示词模板，text 为空时使用默认模板
+func BuildPrompt(text, title, options, questionType string) (string, error) {
+	if text == "" {
+		text = DefaultPromptTemplate
+	}
+	tmpl, err := ParsePromptTemplate(text)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, promptData{Title: title, Options: options, Type: questionType}); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,333 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 流式调用不限制整个调用的时长，而是限制等待响应头及两段输出之间的间隔，避免较长的输出被中途截断
+const (
+	streamIdleTimeout = 15 * time.Second // 等待响应头或下一段输出的最长时间
+	streamMaxDuration = 2 * time.Minute  // 整个流式调用的最长时间
+)
+
+// StreamHandler 流式调用的回调，均可为空
+type StreamHandler struct {
+	// OnRoute 开始调用一个平台和模型。前一个平台失败后会改用下一个平台，此前收到的输出应丢弃
+	OnRoute func(platform, model string)
+	// OnToken 收到一段模型输出
+	OnToken func(token string)
+	// OnReasoning 收到一段推理模型的思考过程（reasoning_content），不计入答案
+	OnReasoning func(text string)
+}
+
+func (h *StreamHandler) route(platform, model string) {
+	if h.OnRoute != nil {
+		h.OnRoute(platform, model)
+	}
+}
+
+func (h *StreamHandler) token(token string) {
+	if h.OnToken != nil && token != "" {
+		h.OnToken(token)
+	}
+}
+
+func (h *StreamHandler) reasoning(text string) {
+	if h.OnReasoning != nil && text != "" {
+		h.OnReasoning(text)
+	}
+}
+
+// chatStreamRequest OpenAI 兼容接口的流式请求，include_usage 使最后一段输出附带token用量
+type chatStreamRequest struct {
+	QueryRequest
+	StreamOptions struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+}
+
+// chatStreamChunk OpenAI 兼容接口流式响应中的一段
+type chatStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"`
+}
+
+// ollamaStreamChunk Ollama 流式响应中的一行，/api/generate 的输出在 response 中，兼容 /api/chat 的 message.content
+type ollamaStreamChunk struct {
+	OllamaResponse
+	Response string `json:"response"`
+}
+
+// streamPlatform 以流式调用平台，每收到一段输出调用一次 h 的回调，返回完整的输出和token用量
+func streamPlatform(ctx context.Context, content, platform string, apiKeys map[string]string, models map[string]string, h *StreamHandler) (string, Usage, error) {
+	switch platform {
+	case "siliconflow":
+		return streamChat(ctx, "https://api.siliconflow.cn/v1/chat/completions", apiKeys["siliconflow"],
+			newChatStreamRequest(content, models["siliconflow"]), h)
+	case "aliyun":
+		return streamChat(ctx, "https://dashscope.aliyuncs.com/compatible-mode/v1/chat/completions", apiKeys["aliyun"],
+			newChatStreamRequest(content, models["aliyun"]), h)
+	case "zhipu":
+		// 智普AI在最后一段输出中返回token用量，不需要 stream_options
+		requestBody := ZhipuAIRequest{
+			Model:       models["zhipu"],
+			Stream:      true,
+			Temperature: 0.05,
+			TopP:        0.95,
+			MaxTokens:   256,
+		}
+		requestBody.Messages = append(requestBody.Messages, struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		}{Role: "user", Content: content})
+		return streamChat(ctx, "https://open.bigmodel.cn/api/paas/v4/chat/completions", apiKeys["zhipu"], requestBody, h)
+	case "ollama":
+		return streamOllama(ctx, content, models["ollama"], h)
+	case "deepseek":
+		return streamChat(ctx, "https://api.deepseek.com/chat/completions", apiKeys["deepseek"],
+			newChatStreamRequest(content, models["deepseek"]), h)
+	case "chatgpt":
+		return streamChat(ctx, "https://api.openai.com/v1/chat/completions", apiKeys["chatgpt"],
+			newChatStreamRequest(content, models["chatgpt"]), h)
+	case "gemini":
+		return streamGemini(ctx, content, apiKeys["gemini"], models["gemini"], h)
+	}
+	return "", Usage{}, fmt.Errorf("不支持的AI平台: %s", platform)
+}
+
+// newChatStreamRequest 构建 OpenAI 兼容接口的流式请求，参数与非流式调用相同
+func newChatStreamRequest(content, model string) chatStreamRequest {
+	requestBody := chatStreamRequest{QueryRequest: QueryRequest{
+		Model:       model,
+		Stream:      true,
+		MaxTokens:   256,
+		Temperature: 0.05,
+		TopP:        0.95,
+		N:           1,
+	}}
+	requestBody.Messages = append(requestBody.Messages, struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}{Role: "user", Content: content})
+	requestBody.ResponseFormat.Type = "json_object"
+	requestBody.StreamOptions.IncludeUsage = true
+	return requestBody
+}
+
+// streamChat 以流式调用 OpenAI 兼容的 chat/completions 接口，响应为 SSE 格式
+func streamChat(ctx context.Context, url, apiKey string, requestBody interface{}, h *StreamHandler) (string, Usage, error) {
+	req, err := newStreamRequest(ctx, url, requestBody)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	req.Header.Set("Accept", "text/event-stream")
+
+	var answer strings.Builder
+	var usage Usage
+	err = doStream(req, func(line string) (bool, error) {
+		data, ok := sseData(line)
+		if !ok {
+			return false, nil
+		}
+		if data == "[DONE]" {
+			return true, nil
+		}
+
+		var chunk chatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return false, fmt.Errorf("无法解析API响应: %v", err)
+		}
+		if len(chunk.Choices) > 0 {
+			delta := chunk.Choices[0].Delta
+			h.reasoning(delta.ReasoningContent)
+			answer.WriteString(delta.Content)
+			h.token(delta.Content)
+		}
+		if chunk.Usage != nil {
+			usage = *chunk.Usage
+		}
+		return false, nil
+	})
+	return streamResult(answer.String(), usage, err)
+}
+
+// streamOllama 以流式调用Ollama本地模型，响应为每行一个JSON对象
+func streamOllama(ctx context.Context, content, model string, h *StreamHandler) (string, Usage, error) {
+	req, err := newStreamRequest(ctx, "http://localhost:11434/api/generate", OllamaRequest{
+		Model:  model,
+		Prompt: content,
+		Stream: true,
+		Format: "json",
+	})
+	if err != nil {
+		return "", Usage{}, err
+	}
+
+	var answer strings.Builder
+	var usage Usage
+	err = doStream(req, func(line string) (bool, error) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return false, nil
+		}
+
+		var chunk ollamaStreamChunk
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return false, fmt.Errorf("无法解析API响应: %v", err)
+		}
+		token := chunk.Response
+		if token == "" {
+			token = chunk.Message.Content
+		}
+		answer.WriteString(token)
+		h.token(token)
+		if chunk.Done {
+			usage = Usage{PromptTokens: chunk.PromptEvalCount, CompletionTokens: chunk.EvalCount}
+		}
+		return chunk.Done, nil
+	})
+	return streamResult(answer.String(), usage, err)
+}
+
+// streamGemini 以流式调用Gemini API，alt=sse 时响应为 SSE 格式，每段与非流式响应的结构相同
+func streamGemini(ctx context.Context, content, apiKey, model string, h *StreamHandler) (string, Usage, error) {
+	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:streamGenerateContent?alt=sse&key=%s", model, apiKey)
+
+	requestBody := GeminiRequest{}
+	requestBody.Contents = append(requestBody.Contents, struct {
+		Role  string `json:"role"`
+		Parts []struct {
+			Text string `json:"text"`
+		} `json:"parts"`
+	}{Role: "user"})
+	requestBody.Contents[0].Parts = append(requestBody.Contents[0].Parts, struct {
+		Text string `json:"text"`
+	}{Text: content})
+	requestBody.GenerationConfig.MaxOutputTokens = 256
+	requestBody.GenerationConfig.Temperature = 0.05
+	requestBody.GenerationConfig.TopP = 0.95
+
+	req, err := newStreamRequest(ctx, url, requestBody)
+	if err != nil {
+		return "", Usage{}, err
+	}
+
+	var answer strings.Builder
+	var usage Usage
+	err = doStream(req, func(line string) (bool, error) {
+		data, ok := sseData(line)
+		if !ok {
+			return false, nil
+		}
+
+		var chunk GeminiResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return false, fmt.Errorf("无法解析API响应: %v", err)
+		}
+		if len(chunk.Candidates) > 0 {
+			for _, part := range chunk.Candidates[0].Content.Parts {
+				answer.WriteString(part.Text)
+				h.token(part.Text)
+			}
+		}
+		// 每段都附带截至当前的token用量，以最后一段为准
+		if m := chunk.UsageMetadata; m.PromptTokenCount > 0 || m.CandidatesTokenCount > 0 {
+			usage = Usage{PromptTokens: m.PromptTokenCount, CompletionTokens: m.CandidatesTokenCount}
+		}
+		return false, nil
+	})
+	return streamResult(answer.String(), usage, err)
+}
+
+// newStreamRequest 构建JSON请求，请求随 ctx 取消
+func newStreamRequest(ctx context.Context, url string, requestBody interface{}) (*http.Request, error) {
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// doStream 发送流式请求并逐行读取响应，handle 返回 true 时停止读取。
+// 等待响应头或两行之间超过 streamIdleTimeout，或整个调用超过 streamMaxDuration 时返回超时错误；请求的 ctx 被取消时返回其错误
+func doStream(req *http.Request, handle func(line string) (bool, error)) error {
+	ctx, cancel := context.WithTimeout(req.Context(), streamMaxDuration)
+	defer cancel()
+	idle := time.AfterFunc(streamIdleTimeout, cancel)
+	defer idle.Stop()
+
+	client := &http.Client{}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return streamError(req.Context(), ctx, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return statusError(resp.StatusCode, body)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		idle.Reset(streamIdleTimeout)
+		done, err := handle(scanner.Text())
+		if err != nil || done {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return streamError(req.Context(), ctx, err)
+	}
+	return nil
+}
+
+// streamError 区分调用方取消、超时和其他网络错误
+func streamError(parent, ctx context.Context, err error) error {
+	if parent.Err() != nil {
+		return parent.Err()
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("等待模型输出超时")
+	}
+	return err
+}
+
+// streamResult 流式调用结束后检查是否得到了答案
+func streamResult(answer string, usage Usage, err error) (string, Usage, error) {
+	if err != nil {
+		return "", Usage{}, err
+	}
+	if strings.TrimSpace(answer) == "" {
+		return "", Usage{}, errNoAnswer
+	}
+	return answer, usage, nil
+}
+
+// sseData 返回 SSE 中 data 行的内容，其他行返回 false
+func sseData(line string) (string, bool) {
+	if !strings.HasPrefix(line, "data:") {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(line, "data:")), true
+}
@@ -0,0 +1,169 @@
+package backup
+
+import (
+	"ai-ocs/internal/database"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// formatVersion 备份文件格式版本
+const formatVersion = 1
+
+// Options 备份选项
+type Options struct {
+	IncludeLogs bool   // 是否备份查询日志
+	ConfigPath  string // 非空时将该配置文件一并写入备份
+}
+
+// Summary 备份或恢复的结果，记录每张表的行数
+type Summary struct {
+	Counts map[string]int64
+}
+
+// record 备份文件中的一行，文件为gzip压缩的JSON Lines：
+// manifest 开头，每张表先写 table 再写若干 row，可选的 config，最后以 end 结束
+type record struct {
+	Type         string           `json:"type"`
+	Version      int              `json:"version,omitempty"`
+	CreatedAt    string           `json:"created_at,omitempty"`
+	DatabaseType string           `json:"database_type,omitempty"`
+	Table        string           `json:"table,omitempty"`
+	Columns      []string         `json:"columns,omitempty"`
+	TimeColumns  []string         `json:"time_columns,omitempty"`
+	Values       []interface{}    `json:"values,omitempty"`
+	Config       json.RawMessage  `json:"config,omitempty"`
+	Counts       map[string]int64 `json:"counts,omitempty"`
+}
+
+// Write 将当前数据库导出到w
+func Write(w io.Writer, opts Options) (*Summary, error) {
+	db := database.GetDB()
+	dbType := database.GetDBType()
+
+	gz := gzip.NewWriter(w)
+	buf := bufio.NewWriter(gz)
+	enc := json.NewEncoder(buf)
+
+	err := enc.Encode(record{
+		Type:         "manifest",
+		Version:      formatVersion,
+		CreatedAt:    time.Now().UTC().Format(time.RFC3339),
+		DatabaseType: dbType,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tables := Tables
+	if opts.IncludeLogs {
+		tables = append(append([]string{}, Tables...), LogTables...)
+	}
+
+	summary := &Summary{Counts: make(map[string]int64)}
+	for _, table := range tables {
+		columns, timeColumns, err := tableColumns(db, dbType, table)
+		if err != nil {
+			return nil, err
+		}
+
+		header := record{Type: "table", Table: table, Columns: columns}
+		for _, col := range columns {
+			if timeColumns[col] {
+				header.TimeColumns = append(header.TimeColumns, col)
+			}
+		}
+		if err := enc.Encode(header); err != nil {
+			return nil, err
+		}
+
+		rows, err := db.Query("SELECT * FROM " + quoteIdent(dbType, table) + orderByKeys(dbType, table))
+		if err != nil {
+			return nil, fmt.Errorf("读取数据表 %s 失败: %v", table, err)
+		}
+		for rows.Next() {
+			values, err := scanRow(rows, len(columns))
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if err := enc.Encode(record{Type: "row", Values: values}); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			summary.Counts[table]++
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.ConfigPath != "" {
+		data, err := os.ReadFile(opts.ConfigPath)
+		if err != nil {
+			return nil, fmt.Errorf("读取配置文件失败: %v", err)
+		}
+		if err := enc.Encode(record{Type: "config", Config: data}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := enc.Encode(record{Type: "end", Counts: summary.Counts}); err != nil {
+		return nil, err
+	}
+	if err := buf.Flush(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// WriteFile 将数据库备份到文件，先写临时文件再重命名，避免留下不完整的备份
+func WriteFile(path string, opts Options) (*Summary, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := Write(f, opts)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	return summary, nil
+}
+
+// DefaultFileName 生成带时间戳的备份文件名
+func DefaultFileName() string {
+	return fmt.Sprintf("%s%s%s", filePrefix, time.Now().Format("20060102-150405"), fileSuffix)
+}
+
+// 备份文件名的前缀和后缀，用于定时备份的轮转
+const (
+	filePrefix = "ai-ocs-backup-"
+	fileSuffix = ".jsonl.gz"
+)
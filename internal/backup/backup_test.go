@@ -0,0 +1,154 @@
+package backup
+
+import (
+	"ai-ocs/internal/database"
+	"ai-ocs/internal/models"
+	"bytes"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// connectTestDB 连接临时目录中的SQLite数据库，并将其设为当前数据库
+func connectTestDB(t *testing.T, name string) *sql.DB {
+	t.Helper()
+	config := &models.Config{
+		DatabaseType: "sqlite",
+		SQLiteConfig: models.SQLiteConfig{Path: filepath.Join(t.TempDir(), name)},
+	}
+	if err := database.Connect(config); err != nil {
+		t.Fatalf("连接数据库 %s 失败: %v", name, err)
+	}
+	return database.GetDB()
+}
+
+// seedAllTables 为 Tables 中的每张表写入数据，多字段主键的表包含多行，以便覆盖分页和排序
+func seedAllTables(t *testing.T) {
+	t.Helper()
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.Local)
+
+	var keyIDs []int64
+	for _, desc := range []string{"key-a", "key-b"} {
+		key, err := database.CreateAPIKey(desc, []string{models.APIScopeQuery}, nil)
+		if err != nil {
+			t.Fatalf("创建API密钥失败: %v", err)
+		}
+		keyIDs = append(keyIDs, key.ID)
+		if err := database.IncrementAPIKeyUsage(key.ID); err != nil {
+			t.Fatalf("记录调用次数失败: %v", err)
+		}
+	}
+
+	for _, keyID := range keyIDs {
+		for _, day := range []time.Time{now.AddDate(0, 0, -1), now} {
+			if err := database.IncrementQuotaUsage(keyID, day); err != nil {
+				t.Fatalf("记录每日用量失败: %v", err)
+			}
+			if err := database.AddQuotaAIRequests(keyID, day, 1); err != nil {
+				t.Fatalf("记录AI调用次数失败: %v", err)
+			}
+			// 大小写不同的模型名在SQLite和MySQL中的排序不同
+			for _, model := range []string{"Qwen-Plus", "gpt-4o", "deepseek-chat"} {
+				if err := database.RecordAICost(keyID, day, "openai", model, 100, 20, 0.5); err != nil {
+					t.Fatalf("记录AI调用费用失败: %v", err)
+				}
+			}
+		}
+	}
+
+	for _, q := range []string{"题目一", "题目二"} {
+		if err := database.SaveAnswer(q, "A", models.AnswerSource{Source: models.AnswerSourceModel}); err != nil {
+			t.Fatalf("保存答案失败: %v", err)
+		}
+		if err := database.SaveAnswer(q, "B", models.AnswerSource{Source: models.AnswerSourceAdmin}); err != nil {
+			t.Fatalf("修改答案失败: %v", err)
+		}
+	}
+
+	qa, err := database.GetAnswer("题目一")
+	if err != nil || qa == nil {
+		t.Fatalf("查询题目失败: %v", err)
+	}
+	_, err = database.RecordFeedback(&models.AnswerFeedback{
+		QuestionID: qa.ID,
+		APIKeyID:   keyIDs[0],
+		Correct:    true,
+	}, models.FeedbackConfig{Action: models.FeedbackActionNone})
+	if err != nil {
+		t.Fatalf("记录反馈失败: %v", err)
+	}
+
+	for _, table := range Tables {
+		var n int
+		if err := database.GetDB().QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+			t.Fatalf("统计数据表 %s 失败: %v", table, err)
+		}
+		if n == 0 {
+			t.Fatalf("测试数据未覆盖数据表 %s", table)
+		}
+	}
+}
+
+// assertSameTables 比较两个库中 Tables 每张表的行数和校验和
+func assertSameTables(t *testing.T, want, got *sql.DB) {
+	t.Helper()
+	for _, table := range Tables {
+		mt, err := commonColumns(want, "sqlite", got, "sqlite", table)
+		if err != nil {
+			t.Fatalf("读取数据表 %s 的字段失败: %v", table, err)
+		}
+		wantRows, wantSum, err := tableChecksum(want, "sqlite", mt)
+		if err != nil {
+			t.Fatalf("校验源数据表 %s 失败: %v", table, err)
+		}
+		gotRows, gotSum, err := tableChecksum(got, "sqlite", mt)
+		if err != nil {
+			t.Fatalf("校验目标数据表 %s 失败: %v", table, err)
+		}
+		if wantRows != gotRows || wantSum != gotSum {
+			t.Errorf("数据表 %s 不一致: 期望 %d 行（%s），实际 %d 行（%s）", table, wantRows, wantSum, gotRows, gotSum)
+		}
+	}
+}
+
+func TestBackupRestoreMigrateRoundTrip(t *testing.T) {
+	src := connectTestDB(t, "source.db")
+	seedAllTables(t)
+
+	var buf bytes.Buffer
+	written, err := Write(&buf, Options{})
+	if err != nil {
+		t.Fatalf("备份失败: %v", err)
+	}
+
+	restored := connectTestDB(t, "restored.db")
+	read, err := Restore(bytes.NewReader(buf.Bytes()), RestoreOptions{Mode: ModeReplace})
+	if err != nil {
+		t.Fatalf("恢复失败: %v", err)
+	}
+	for _, table := range Tables {
+		if written.Counts[table] != read.Counts[table] {
+			t.Errorf("数据表 %s 备份 %d 行，恢复 %d 行", table, written.Counts[table], read.Counts[table])
+		}
+	}
+	assertSameTables(t, src, restored)
+
+	// 以恢复后的库为源迁移，每批1行以覆盖多字段主键的分页；重复执行时跳过已迁移的记录
+	migrated := connectTestDB(t, "migrated.db")
+	for run := 1; run <= 2; run++ {
+		reports, err := Migrate(restored, "sqlite", MigrateOptions{BatchSize: 1})
+		if err != nil {
+			t.Fatalf("第 %d 次迁移失败: %v", run, err)
+		}
+		if len(reports) != len(Tables) {
+			t.Fatalf("迁移了 %d 张表，期望 %d 张", len(reports), len(Tables))
+		}
+		for _, r := range reports {
+			if r.Skipped || !r.OK() {
+				t.Errorf("第 %d 次迁移数据表 %s 校验不一致: %+v", run, r.Table, r)
+			}
+		}
+	}
+	assertSameTables(t, src, migrated)
+}
@@ -0,0 +1,362 @@
+package backup
+
+import (
+	"ai-ocs/internal/database"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"log"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultBatchSize 迁移时每批复制的行数
+const defaultBatchSize = 1000
+
+// MigrateOptions 迁移选项
+type MigrateOptions struct {
+	BatchSize   int  // 每批复制的行数，每批在一个事务中写入
+	IncludeLogs bool // 是否迁移查询日志
+}
+
+// TableReport 单张表的迁移及校验结果
+type TableReport struct {
+	Table          string
+	Skipped        bool  // 源库中没有该表
+	Copied         int64 // 本次复制的行数
+	SourceRows     int64
+	TargetRows     int64
+	SourceChecksum string
+	TargetChecksum string
+}
+
+// OK 行数和校验和是否一致
+func (r *TableReport) OK() bool {
+	return r.Skipped || (r.SourceRows == r.TargetRows && r.SourceChecksum == r.TargetChecksum)
+}
+
+// migrateTable 正在迁移的数据表
+type migrateTable struct {
+	name    string
+	columns []string        // 源库和目标库共有的字段
+	isTime  map[string]bool // 时间类型字段
+}
+
+// Migrate 将源数据库的数据复制到当前数据库（目标库）。
+// 按主键分批复制，以 id 为主键的表中目标表已存在的最大 id 之前的记录视为已迁移，
+// 其他表跳过主键已存在的记录，中断后重新执行即可继续；
+// 复制完成后比较两边的行数和校验和。
+func Migrate(src *sql.DB, srcType string, opts MigrateOptions) ([]*TableReport, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+
+	dst := database.GetDB()
+	dstType := database.GetDBType()
+
+	tables := Tables
+	if opts.IncludeLogs {
+		tables = append(append([]string{}, Tables...), LogTables...)
+	}
+
+	var reports []*TableReport
+	for _, table := range tables {
+		report := &TableReport{Table: table}
+		reports = append(reports, report)
+
+		exists, err := tableExists(src, srcType, table)
+		if err != nil {
+			return reports, err
+		}
+		if !exists {
+			log.Printf("源库中没有数据表 %s，已跳过", table)
+			report.Skipped = true
+			continue
+		}
+
+		t, err := commonColumns(src, srcType, dst, dstType, table)
+		if err != nil {
+			return reports, err
+		}
+
+		report.Copied, err = copyTable(src, srcType, dst, dstType, t, opts.BatchSize)
+		if err != nil {
+			return reports, err
+		}
+
+		report.SourceRows, report.SourceChecksum, err = tableChecksum(src, srcType, t)
+		if err != nil {
+			return reports, fmt.Errorf("校验源数据表 %s 失败: %v", table, err)
+		}
+		report.TargetRows, report.TargetChecksum, err = tableChecksum(dst, dstType, t)
+		if err != nil {
+			return reports, fmt.Errorf("校验目标数据表 %s 失败: %v", table, err)
+		}
+	}
+
+	// 从旧版本的数据库迁移时API密钥为明文，校验完成后再转换，以免影响校验和
+	if err := database.HashPlaintextAPIKeys(); err != nil {
+		return reports, fmt.Errorf("转换API密钥失败: %v", err)
+	}
+	return reports, nil
+}
+
+// tableExists 检查数据表是否存在
+func tableExists(db *sql.DB, dbType, table string) (bool, error) {
+	var count int
+	var err error
+	if dbType == "sqlite" {
+		err = db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&count)
+	} else {
+		err = db.QueryRow(`SELECT COUNT(*) FROM information_schema.tables
+			WHERE table_schema = DATABASE() AND table_name = ?`, table).Scan(&count)
+	}
+	return count > 0, err
+}
+
+// commonColumns 取源表和目标表共有的字段，源库版本较旧时缺少的字段使用目标库的默认值
+func commonColumns(src *sql.DB, srcType string, dst *sql.DB, dstType, table string) (*migrateTable, error) {
+	srcColumns, srcTime, err := tableColumns(src, srcType, table)
+	if err != nil {
+		return nil, err
+	}
+	dstColumns, dstTime, err := tableColumns(dst, dstType, table)
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool, len(dstColumns))
+	for _, col := range dstColumns {
+		exists[col] = true
+	}
+
+	t := &migrateTable{name: table, isTime: make(map[string]bool)}
+	for _, col := range srcColumns {
+		if !exists[col] {
+			log.Printf("目标库 %s 表中没有字段 %s，已忽略", table, col)
+			continue
+		}
+		t.columns = append(t.columns, col)
+		t.isTime[col] = srcTime[col] || dstTime[col]
+	}
+	return t, nil
+}
+
+// selectColumns 构造查询字段列表
+func (t *migrateTable) selectColumns(dbType string) string {
+	quoted := make([]string, len(t.columns))
+	for i, col := range t.columns {
+		quoted[i] = quoteIdent(dbType, col)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// copyTable 按主键顺序分批复制，返回复制的行数。以 id 为主键的表从目标表已有的最大 id 之后开始，
+// 其他表从头复制并跳过目标表中主键已存在的记录
+func copyTable(src *sql.DB, srcType string, dst *sql.DB, dstType string, t *migrateTable, batchSize int) (int64, error) {
+	keys := keyColumns(t.name)
+	keyIndexes := make([]int, len(keys))
+	for i, key := range keys {
+		keyIndexes[i] = -1
+		for j, col := range t.columns {
+			if col == key {
+				keyIndexes[i] = j
+			}
+		}
+		if keyIndexes[i] < 0 {
+			return 0, fmt.Errorf("数据表 %s 缺少主键字段 %s", t.name, key)
+		}
+	}
+
+	var after []interface{}
+	insert := insertSQL(dstType, t.name, t.columns, false)
+	if len(keys) == 1 && keys[0] == "id" {
+		var lastID int64
+		err := dst.QueryRow("SELECT COALESCE(MAX(id), 0) FROM " + quoteIdent(dstType, t.name)).Scan(&lastID)
+		if err != nil {
+			return 0, err
+		}
+		if lastID > 0 {
+			log.Printf("目标表 %s 已有数据，从 id > %d 继续迁移", t.name, lastID)
+			after = []interface{}{lastID}
+		}
+	} else {
+		insert = insertSQL(dstType, t.name, t.columns, true)
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", t.selectColumns(srcType), quoteIdent(srcType, t.name))
+
+	var copied int64
+	for {
+		batch, err := readBatch(src, srcType, query, t.name, after, batchSize, len(t.columns))
+		if err != nil {
+			return copied, fmt.Errorf("读取数据表 %s 失败: %v", t.name, err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		if err := writeBatch(dst, dstType, insert, t, batch); err != nil {
+			return copied, fmt.Errorf("写入数据表 %s 失败: %v", t.name, err)
+		}
+
+		copied += int64(len(batch))
+		last := batch[len(batch)-1]
+		after = make([]interface{}, len(keys))
+		for i, idx := range keyIndexes {
+			after[i] = last[idx]
+		}
+		log.Printf("  %s: 已复制 %d 行（%s ≤ %s）", t.name, copied, strings.Join(keys, ", "), strings.Trim(fmt.Sprint(after), "[]"))
+
+		if len(batch) < batchSize {
+			break
+		}
+	}
+	return copied, nil
+}
+
+// afterKeys 构造只取主键大于 after 的记录的条件，多字段主键按字典序比较
+func afterKeys(dbType string, keys []string, after []interface{}) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	for i := range keys {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, quoteIdent(dbType, keys[j])+" = ?")
+			args = append(args, after[j])
+		}
+		parts = append(parts, quoteIdent(dbType, keys[i])+" > ?")
+		args = append(args, after[i])
+		conds = append(conds, "("+strings.Join(parts, " AND ")+")")
+	}
+	return strings.Join(conds, " OR "), args
+}
+
+// readBatch 按主键顺序读取 after 之后的一批数据，after 为空时从头读取
+func readBatch(db *sql.DB, dbType, query, table string, after []interface{}, limit, n int) ([][]interface{}, error) {
+	var args []interface{}
+	if after != nil {
+		cond, keyArgs := afterKeys(dbType, keyColumns(table), after)
+		query += " WHERE " + cond
+		args = keyArgs
+	}
+	query += orderByKeys(dbType, table) + " LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batch [][]interface{}
+	for rows.Next() {
+		values, err := scanRow(rows, n)
+		if err != nil {
+			return nil, err
+		}
+		batch = append(batch, values)
+	}
+	return batch, rows.Err()
+}
+
+// writeBatch 在一个事务中写入一批数据
+func writeBatch(db *sql.DB, dbType, insert string, t *migrateTable, batch [][]interface{}) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(insert)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	args := make([]interface{}, len(t.columns))
+	for _, values := range batch {
+		for i, col := range t.columns {
+			v := denormalizeValue(values[i], t.isTime[col])
+			// MySQL的TIMESTAMP会对小数秒四舍五入，先截断到秒以便校验一致
+			if tm, ok := v.(time.Time); ok && dbType == "mysql" {
+				v = tm.Truncate(time.Second)
+			}
+			args[i] = v
+		}
+		if _, err := stmt.Exec(args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// tableChecksum 计算共有字段的校验和，返回行数和校验和。
+// 各数据库返回的类型不同，计算前统一转换为文本，时间统一为UTC并精确到秒。
+// 主键含文本字段时MySQL按不区分大小写的排序规则排序，与SQLite的顺序不同，
+// 因此逐行计算SHA-256后累加（模2^256），结果与读取顺序无关。
+func tableChecksum(db *sql.DB, dbType string, t *migrateTable) (int64, string, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT %s FROM %s", t.selectColumns(dbType), quoteIdent(dbType, t.name)))
+	if err != nil {
+		return 0, "", err
+	}
+	defer rows.Close()
+
+	sum := new(big.Int)
+	rowSum := new(big.Int)
+	var count int64
+	for rows.Next() {
+		values, err := scanRow(rows, len(t.columns))
+		if err != nil {
+			return 0, "", err
+		}
+		h := sha256.New()
+		for i, col := range t.columns {
+			if i > 0 {
+				h.Write([]byte{0x1f})
+			}
+			writeChecksumValue(h, values[i], t.isTime[col])
+		}
+		sum.Add(sum, rowSum.SetBytes(h.Sum(nil)))
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, "", err
+	}
+	sum.Mod(sum, checksumModulus)
+	return count, hex.EncodeToString(sum.FillBytes(make([]byte, sha256.Size))), nil
+}
+
+// checksumModulus 校验和累加的模数
+var checksumModulus = new(big.Int).Lsh(big.NewInt(1), 8*sha256.Size)
+
+// writeChecksumValue 以与数据库无关的文本形式写入一个字段值
+func writeChecksumValue(h hash.Hash, v interface{}, isTime bool) {
+	if v == nil {
+		h.Write([]byte{0})
+		return
+	}
+	if isTime {
+		if tm, ok := denormalizeValue(v, true).(time.Time); ok {
+			h.Write([]byte(tm.UTC().Truncate(time.Second).Format(time.RFC3339)))
+			return
+		}
+	}
+	switch val := v.(type) {
+	case string:
+		h.Write([]byte(val))
+	case float64:
+		h.Write([]byte(strconv.FormatFloat(val, 'g', -1, 64)))
+	case bool:
+		if val {
+			h.Write([]byte("1"))
+		} else {
+			h.Write([]byte("0"))
+		}
+	default:
+		h.Write([]byte(fmt.Sprint(val)))
+	}
+}
@@ -0,0 +1,225 @@
+package backup
+
+import (
+	"ai-ocs/internal/database"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// 恢复模式
+const (
+	ModeReplace = "replace" // 清空备份中包含的表后再写入
+	ModeMerge   = "merge"   // 保留现有数据，跳过主键已存在的记录
+)
+
+// RestoreOptions 恢复选项
+type RestoreOptions struct {
+	Mode       string // replace 或 merge
+	ConfigPath string // 非空时将备份中的配置写入该文件
+}
+
+// tableWriter 正在恢复的数据表
+type tableWriter struct {
+	name    string
+	stmt    *sql.Stmt
+	indexes []int  // 备份字段在目标表中保留的下标
+	isTime  []bool // 对应字段是否为时间类型
+}
+
+// Restore 从r读取备份并写入当前数据库，全部表在同一事务中完成
+func Restore(r io.Reader, opts RestoreOptions) (*Summary, error) {
+	if opts.Mode == "" {
+		opts.Mode = ModeReplace
+	}
+	if opts.Mode != ModeReplace && opts.Mode != ModeMerge {
+		return nil, fmt.Errorf("未知的恢复模式: %s", opts.Mode)
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取备份文件: %v", err)
+	}
+	defer gz.Close()
+
+	dec := json.NewDecoder(gz)
+	dec.UseNumber()
+
+	var manifest record
+	if err := dec.Decode(&manifest); err != nil || manifest.Type != "manifest" {
+		return nil, fmt.Errorf("备份文件格式不正确")
+	}
+	if manifest.Version > formatVersion {
+		return nil, fmt.Errorf("备份文件版本 %d 高于当前程序支持的版本 %d", manifest.Version, formatVersion)
+	}
+
+	db := database.GetDB()
+	dbType := database.GetDBType()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	summary := &Summary{Counts: make(map[string]int64)}
+	var current *tableWriter
+	var config json.RawMessage
+	finished := false
+
+	closeCurrent := func() {
+		if current != nil && current.stmt != nil {
+			current.stmt.Close()
+		}
+		current = nil
+	}
+	defer closeCurrent()
+
+	for !finished {
+		var rec record
+		if err := dec.Decode(&rec); err != nil {
+			if err == io.EOF {
+				return nil, fmt.Errorf("备份文件不完整")
+			}
+			return nil, fmt.Errorf("解析备份文件失败: %v", err)
+		}
+
+		switch rec.Type {
+		case "table":
+			closeCurrent()
+			current, err = prepareTable(tx, dbType, rec, opts.Mode)
+			if err != nil {
+				return nil, err
+			}
+		case "row":
+			if current == nil {
+				return nil, fmt.Errorf("备份文件格式不正确：数据行缺少表头")
+			}
+			if current.stmt == nil {
+				continue
+			}
+			args := make([]interface{}, len(current.indexes))
+			for i, idx := range current.indexes {
+				if idx >= len(rec.Values) {
+					return nil, fmt.Errorf("数据表 %s 的数据行字段数量不正确", current.name)
+				}
+				args[i] = denormalizeValue(jsonValue(rec.Values[idx]), current.isTime[i])
+			}
+			if _, err := current.stmt.Exec(args...); err != nil {
+				return nil, fmt.Errorf("写入数据表 %s 失败: %v", current.name, err)
+			}
+			summary.Counts[current.name]++
+		case "config":
+			config = rec.Config
+		case "end":
+			for table, count := range rec.Counts {
+				if summary.Counts[table] != count && !skippedTable(table) {
+					return nil, fmt.Errorf("数据表 %s 行数不一致：备份中 %d 行，已恢复 %d 行", table, count, summary.Counts[table])
+				}
+			}
+			finished = true
+		}
+	}
+	closeCurrent()
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// 旧版本的备份中API密钥为明文
+	if err := database.HashPlaintextAPIKeys(); err != nil {
+		return summary, fmt.Errorf("转换API密钥失败: %v", err)
+	}
+
+	if opts.ConfigPath != "" {
+		if config == nil {
+			log.Println("备份中不包含配置文件，跳过恢复配置")
+		} else if err := os.WriteFile(opts.ConfigPath, config, 0600); err != nil {
+			return summary, fmt.Errorf("写入配置文件失败: %v", err)
+		}
+	}
+
+	return summary, nil
+}
+
+// RestoreFile 从备份文件恢复数据库
+func RestoreFile(path string, opts RestoreOptions) (*Summary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Restore(f, opts)
+}
+
+// skippedTable 判断数据表是否因目标库中不存在而被跳过
+func skippedTable(table string) bool {
+	for _, t := range append(append([]string{}, Tables...), LogTables...) {
+		if t == table {
+			return false
+		}
+	}
+	return true
+}
+
+// prepareTable 根据表头准备写入语句，只写入目标表中存在的字段
+func prepareTable(tx *sql.Tx, dbType string, header record, mode string) (*tableWriter, error) {
+	w := &tableWriter{name: header.Table}
+	if skippedTable(header.Table) {
+		log.Printf("跳过未知的数据表 %s", header.Table)
+		return w, nil
+	}
+
+	targetColumns, _, err := tableColumns(database.GetDB(), dbType, header.Table)
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool, len(targetColumns))
+	for _, col := range targetColumns {
+		exists[col] = true
+	}
+	isTime := make(map[string]bool, len(header.TimeColumns))
+	for _, col := range header.TimeColumns {
+		isTime[col] = true
+	}
+
+	var columns []string
+	for i, col := range header.Columns {
+		if !exists[col] {
+			log.Printf("目标库 %s 表中没有字段 %s，已忽略", header.Table, col)
+			continue
+		}
+		columns = append(columns, col)
+		w.indexes = append(w.indexes, i)
+		w.isTime = append(w.isTime, isTime[col])
+	}
+
+	if mode == ModeReplace {
+		if _, err := tx.Exec("DELETE FROM " + quoteIdent(dbType, header.Table)); err != nil {
+			return nil, fmt.Errorf("清空数据表 %s 失败: %v", header.Table, err)
+		}
+	}
+
+	w.stmt, err = tx.Prepare(insertSQL(dbType, header.Table, columns, mode == ModeMerge))
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// jsonValue 将 json.Number 转换为整数或浮点数
+func jsonValue(v interface{}) interface{} {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v
+	}
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	f, _ := n.Float64()
+	return f
+}
@@ -0,0 +1,72 @@
+package backup
+
+import (
+	"ai-ocs/internal/models"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StartScheduler 按配置的间隔定时备份，并只保留最近的若干份
+func StartScheduler(config models.BackupConfig, configPath string) {
+	if !config.Enabled {
+		return
+	}
+
+	opts := Options{IncludeLogs: config.IncludeLogs}
+	if config.IncludeConfig {
+		opts.ConfigPath = configPath
+	}
+	interval := time.Duration(config.IntervalHours) * time.Hour
+
+	log.Printf("已启用定时备份，每 %d 小时备份到 %s，保留 %d 份", config.IntervalHours, config.Dir, config.Keep)
+	go func() {
+		for {
+			time.Sleep(interval)
+
+			path := filepath.Join(config.Dir, DefaultFileName())
+			summary, err := WriteFile(path, opts)
+			if err != nil {
+				log.Printf("定时备份失败: %v", err)
+				continue
+			}
+			log.Printf("定时备份完成: %s，题目 %d 条", path, summary.Counts["question_answer"])
+
+			if err := rotate(config.Dir, config.Keep); err != nil {
+				log.Printf("清理旧备份失败: %v", err)
+			}
+		}
+	}()
+}
+
+// rotate 删除目录中超出保留数量的旧备份
+func rotate(dir string, keep int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, filePrefix) && strings.HasSuffix(name, fileSuffix) {
+			files = append(files, name)
+		}
+	}
+	if len(files) <= keep {
+		return nil
+	}
+
+	// 文件名中包含时间戳，按名称排序即按时间排序
+	sort.Strings(files)
+	for _, name := range files[:len(files)-keep] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+		log.Printf("已删除旧备份: %s", name)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package backup
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Tables 需要备份的数据表，按依赖顺序排列（被引用的表在前）
+var Tables = []string{"api_keys", "api_key_usage", "api_key_daily_usage", "api_key_daily_cost", "question_answer", "answer_revisions", "answer_feedback"}
+
+// LogTables 体积较大、默认不备份的日志表
+var LogTables = []string{"query_log", "answer_verifications"}
+
+// tableKeys 没有自增 id 字段的数据表的主键，未列出的表以 id 为主键
+var tableKeys = map[string][]string{
+	"api_key_daily_usage": {"api_key_id", "day"},
+	"api_key_daily_cost":  {"api_key_id", "day", "platform", "model"},
+}
+
+// keyColumns 返回数据表的主键字段，备份、迁移和校验都按主键顺序读取
+func keyColumns(table string) []string {
+	if keys, ok := tableKeys[table]; ok {
+		return keys
+	}
+	return []string{"id"}
+}
+
+// orderByKeys 构造按主键排序的 ORDER BY 子句
+func orderByKeys(dbType, table string) string {
+	keys := keyColumns(table)
+	quoted := make([]string, len(keys))
+	for i, key := range keys {
+		quoted[i] = quoteIdent(dbType, key)
+	}
+	return " ORDER BY " + strings.Join(quoted, ", ")
+}
+
+// tableColumns 获取表的字段名及时间类型字段
+func tableColumns(db *sql.DB, dbType, table string) ([]string, map[string]bool, error) {
+	var rows *sql.Rows
+	var err error
+	if dbType == "sqlite" {
+		rows, err = db.Query("SELECT name, type FROM pragma_table_info(?) ORDER BY cid", table)
+	} else {
+		rows, err = db.Query(`SELECT column_name, data_type FROM information_schema.columns
+			WHERE table_schema = DATABASE() AND table_name = ? ORDER BY ordinal_position`, table)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	timeColumns := make(map[string]bool)
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err != nil {
+			return nil, nil, err
+		}
+		columns = append(columns, name)
+		switch strings.ToUpper(typ) {
+		case "DATETIME", "TIMESTAMP", "DATE":
+			timeColumns[name] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(columns) == 0 {
+		return nil, nil, fmt.Errorf("数据表 %s 不存在", table)
+	}
+	return columns, timeColumns, nil
+}
+
+// normalizeValue 将数据库驱动返回的值转换为可移植的表示
+// 时间统一为UTC的RFC3339格式，字节切片转为字符串
+func normalizeValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case []byte:
+		return string(val)
+	case time.Time:
+		return val.UTC().Format(time.RFC3339Nano)
+	default:
+		return val
+	}
+}
+
+// denormalizeValue 将可移植的表示转换为写入数据库的参数
+func denormalizeValue(v interface{}, isTime bool) interface{} {
+	s, ok := v.(string)
+	if !ok || !isTime {
+		return v
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return s
+}
+
+// quoteIdent 为字段名或表名加引号
+func quoteIdent(dbType, name string) string {
+	if dbType == "sqlite" {
+		return `"` + name + `"`
+	}
+	return "`" + name + "`"
+}
+
+// insertSQL 构造批量插入语句，ignoreExisting 为 true 时跳过主键冲突的记录
+func insertSQL(dbType, table string, columns []string, ignoreExisting bool) string {
+	quoted := make([]string, len(columns))
+	for i, col := range columns {
+		quoted[i] = quoteIdent(dbType, col)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+
+	verb := "INSERT INTO"
+	if ignoreExisting {
+		verb = "INSERT IGNORE INTO"
+		if dbType == "sqlite" {
+			verb = "INSERT OR IGNORE INTO"
+		}
+	}
+	return fmt.Sprintf("%s %s (%s) VALUES (%s)", verb, quoteIdent(dbType, table), strings.Join(quoted, ", "), placeholders)
+}
+
+// scanRow 按字段数量扫描一行数据并转换为可移植的表示
+func scanRow(rows *sql.Rows, n int) ([]interface{}, error) {
+	values := make([]interface{}, n)
+	ptrs := make([]interface{}, n)
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return nil, err
+	}
+	for i, v := range values {
+		values[i] = normalizeValue(v)
+	}
+	return values, nil
+}
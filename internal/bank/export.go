@@ -0,0 +1,276 @@
+package bank
+
+import (
+	"ai-ocs/internal/database"
+	"ai-ocs/internal/models"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// exportColumns 导出的列，CSV和XLSX的表头可直接用于再次导入
+var exportColumns = []string{"id", "question", "options", "type", "answer", "source", "platform", "model",
+	"confidence", "hit_count", "created_at", "updated_at"}
+
+// ContentTypes 各导出格式的MIME类型
+var ContentTypes = map[string]string{
+	FormatCSV:      "text/csv; charset=utf-8",
+	FormatJSONL:    "application/x-ndjson; charset=utf-8",
+	FormatXLSX:     "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	FormatMarkdown: "text/markdown; charset=utf-8",
+}
+
+// rowWriter 逐条写出题目
+type rowWriter interface {
+	Write(qa *models.QuestionAnswer) error
+	Close() error
+}
+
+// Export 将符合条件的题目按指定格式写入w，逐条读取和写出，返回导出的题目数
+func Export(w io.Writer, format string, filter database.QuestionFilter) (int, error) {
+	rw, err := newRowWriter(w, format)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = database.IterateQuestions(filter, func(qa *models.QuestionAnswer) error {
+		count++
+		return rw.Write(qa)
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, rw.Close()
+}
+
+// ExportFile 将题目导出到文件，未指定格式时按扩展名判断
+func ExportFile(path, format string, filter database.QuestionFilter) (int, error) {
+	if format == "" {
+		var err error
+		if format, err = DetectFormat(path); err != nil {
+			return 0, err
+		}
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return 0, err
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	count, err := Export(f, format, filter)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return count, err
+}
+
+// ExportFileName 生成带时间戳的导出文件名
+func ExportFileName(format string) string {
+	return fmt.Sprintf("questions-%s.%s", time.Now().Format("20060102-150405"), format)
+}
+
+// newRowWriter 按格式创建写出器
+func newRowWriter(w io.Writer, format string) (rowWriter, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatJSONL:
+		return newJSONLWriter(w), nil
+	case FormatXLSX:
+		return newXLSXWriter(w)
+	case FormatMarkdown:
+		return newMarkdownWriter(w), nil
+	}
+	return nil, fmt.Errorf("不支持导出 %s 格式", format)
+}
+
+// exportValues 按 exportColumns 的顺序返回题目各列的文本
+func exportValues(qa *models.QuestionAnswer) []string {
+	values := []string{
+		strconv.FormatInt(qa.ID, 10),
+		qa.Question,
+		stringValue(qa.Options),
+		stringValue(qa.Type),
+		qa.Answer,
+		qa.Source,
+		qa.Platform,
+		qa.Model,
+		"",
+		strconv.FormatInt(qa.HitCount, 10),
+		qa.CreatedAt.Format("2006-01-02 15:04:05"),
+		"",
+	}
+	if qa.Confidence != nil {
+		values[8] = strconv.FormatFloat(*qa.Confidence, 'f', -1, 64)
+	}
+	if qa.UpdatedAt != nil {
+		values[11] = qa.UpdatedAt.Format("2006-01-02 15:04:05")
+	}
+	return values
+}
+
+// stringValue 返回字符串指针的值，为空时返回空字符串
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// csvWriter 写出带UTF-8 BOM的CSV，便于Excel直接打开
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	if _, err := io.WriteString(w, "\xef\xbb\xbf"); err != nil {
+		return nil, err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportColumns); err != nil {
+		return nil, err
+	}
+	return &csvWriter{w: cw}, nil
+}
+
+func (c *csvWriter) Write(qa *models.QuestionAnswer) error {
+	return c.w.Write(exportValues(qa))
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonlWriter 每行写出一条题目的JSON
+type jsonlWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func newJSONLWriter(w io.Writer) *jsonlWriter {
+	buf := bufio.NewWriter(w)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	return &jsonlWriter{buf: buf, enc: enc}
+}
+
+func (j *jsonlWriter) Write(qa *models.QuestionAnswer) error {
+	return j.enc.Encode(qa)
+}
+
+func (j *jsonlWriter) Close() error {
+	return j.buf.Flush()
+}
+
+// xlsxWriter 使用流式写入生成工作表，行数据超过内存阈值时由excelize暂存到临时文件
+type xlsxWriter struct {
+	w      io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+func newXLSXWriter(w io.Writer) (*xlsxWriter, error) {
+	f := excelize.NewFile()
+	sheet := f.GetSheetName(0)
+	stream, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	x := &xlsxWriter{w: w, file: f, stream: stream, row: 1}
+	if err := x.writeRow(exportColumns); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return x, nil
+}
+
+func (x *xlsxWriter) writeRow(values []string) error {
+	cells := make([]interface{}, len(values))
+	for i, v := range values {
+		cells[i] = v
+	}
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+	x.row++
+	return x.stream.SetRow(cell, cells)
+}
+
+func (x *xlsxWriter) Write(qa *models.QuestionAnswer) error {
+	return x.writeRow(exportValues(qa))
+}
+
+func (x *xlsxWriter) Close() error {
+	defer x.file.Close()
+	if err := x.stream.Flush(); err != nil {
+		return err
+	}
+	return x.file.Write(x.w)
+}
+
+// markdownWriter 每道题写成一个小节，便于阅读和打印
+type markdownWriter struct {
+	buf *bufio.Writer
+	err error
+}
+
+func newMarkdownWriter(w io.Writer) *markdownWriter {
+	buf := bufio.NewWriter(w)
+	m := &markdownWriter{buf: buf}
+	m.printf("# 题库导出\n\n导出时间：%s\n", time.Now().Format("2006-01-02 15:04:05"))
+	return m
+}
+
+func (m *markdownWriter) printf(format string, args ...interface{}) {
+	if m.err == nil {
+		_, m.err = fmt.Fprintf(m.buf, format, args...)
+	}
+}
+
+func (m *markdownWriter) Write(qa *models.QuestionAnswer) error {
+	title := qa.Question
+	if qa.Type != nil && *qa.Type != "" {
+		title = "【" + *qa.Type + "】" + title
+	}
+	m.printf("\n## %d. %s\n\n", qa.ID, markdownLine(title))
+	if qa.Options != nil && *qa.Options != "" {
+		for _, opt := range strings.Split(*qa.Options, "\n") {
+			if opt = strings.TrimSpace(opt); opt != "" {
+				m.printf("- %s\n", markdownLine(opt))
+			}
+		}
+		m.printf("\n")
+	}
+	m.printf("**答案：** %s\n", markdownLine(qa.Answer))
+	return m.err
+}
+
+func (m *markdownWriter) Close() error {
+	if m.err != nil {
+		return m.err
+	}
+	return m.buf.Flush()
+}
+
+// markdownLine 将多行文本合并为一行，避免破坏Markdown结构
+func markdownLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
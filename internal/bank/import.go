@@ -0,0 +1,228 @@
+package bank
+
+import (
+	"ai-ocs/internal/database"
+	"ai-ocs/internal/models"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// 重复题目的处理方式
+const (
+	OnDuplicateSkip   = "skip"   // 跳过题库中已存在的题目
+	OnDuplicateUpdate = "update" // 用导入的答案覆盖题库中的答案
+)
+
+// 每行的处理结果
+const (
+	ActionInsert  = "insert"
+	ActionUpdate  = "update"
+	ActionSkip    = "skip"
+	ActionInvalid = "invalid"
+)
+
+// importBatchSize 每批写入数据库的题目数
+const importBatchSize = 500
+
+// maxReportRows 导入报告中保留的明细行数
+const maxReportRows = 500
+
+// ImportOptions 导入选项
+type ImportOptions struct {
+	Format      string  // 文件格式，见 Format* 常量
+	Sheet       string  // XLSX工作表名，为空时使用默认工作表
+	Mapping     Mapping // 列映射
+	DryRun      bool    // 只预览不写入
+	OnDuplicate string  // 题库中已存在相同题目时的处理方式
+	Operator    string  // 执行导入的管理员
+	Reason      string  // 记录到答案历史中的说明，如来源文件名
+}
+
+// RowResult 单行的处理结果
+type RowResult struct {
+	Line       int    `json:"line"`
+	Question   string `json:"question"`
+	Answer     string `json:"answer,omitempty"`
+	Action     string `json:"action"`
+	Reason     string `json:"reason,omitempty"`
+	ExistingID int64  `json:"existing_id,omitempty"`
+}
+
+// Report 导入报告
+type Report struct {
+	DryRun     bool              `json:"dry_run"`
+	Mapping    map[string]string `json:"mapping"`
+	Total      int               `json:"total"`
+	Inserted   int               `json:"inserted"`
+	Updated    int               `json:"updated"`
+	Duplicates int               `json:"duplicates"`
+	Invalid    int               `json:"invalid"`
+	Rows       []RowResult       `json:"rows"`
+	Truncated  bool              `json:"truncated"` // 明细超过上限未全部列出
+}
+
+// existing 题库中已有题目的ID和答案
+type existing struct {
+	id     int64
+	answer string
+}
+
+// NormalizeQuestion 归一化题目用于判重：统一全角半角和大小写，去掉空白和标点
+func NormalizeQuestion(question string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKC.String(question) {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) {
+			continue
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// Import 读取导入文件并写入题库，返回导入报告
+func Import(r io.Reader, opts ImportOptions) (*Report, error) {
+	if opts.OnDuplicate == "" {
+		opts.OnDuplicate = OnDuplicateSkip
+	}
+	if opts.OnDuplicate != OnDuplicateSkip && opts.OnDuplicate != OnDuplicateUpdate {
+		return nil, fmt.Errorf("未知的重复处理方式: %s", opts.OnDuplicate)
+	}
+
+	reader, err := newRowReader(r, opts.Format, opts.Sheet)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	columns := opts.Mapping.resolve(reader.Header())
+	if columns["question"] == "" || columns["answer"] == "" {
+		return nil, fmt.Errorf("无法识别题目或答案所在的列，请指定列映射")
+	}
+
+	// 加载题库中已有题目的归一化文本用于判重
+	known := make(map[string]existing)
+	err = database.IterateQuestions(database.QuestionFilter{}, func(qa *models.QuestionAnswer) error {
+		known[NormalizeQuestion(qa.Question)] = existing{id: qa.ID, answer: qa.Answer}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("加载题库失败: %v", err)
+	}
+
+	report := &Report{DryRun: opts.DryRun, Mapping: columns}
+	source := models.AnswerSource{
+		Source:   models.AnswerSourceImport,
+		Operator: opts.Operator,
+		Reason:   opts.Reason,
+	}
+	seen := make(map[string]int) // 文件中已出现的题目及其行号
+	var batch []*models.QuestionAnswer
+
+	flush := func() error {
+		if len(batch) == 0 || opts.DryRun {
+			batch = batch[:0]
+			return nil
+		}
+		if err := database.ImportQuestions(batch, source); err != nil {
+			return fmt.Errorf("写入题库失败: %v", err)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		row, line, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取第 %d 行失败: %v", line, err)
+		}
+
+		qa := &models.QuestionAnswer{
+			Question: strings.TrimSpace(row[columns["question"]]),
+			Answer:   strings.TrimSpace(row[columns["answer"]]),
+		}
+		if qa.Question == "" && qa.Answer == "" {
+			continue // 空行
+		}
+		report.Total++
+		if v := strings.TrimSpace(row[columns["options"]]); columns["options"] != "" && v != "" {
+			qa.Options = &v
+		}
+		if v := strings.TrimSpace(row[columns["type"]]); columns["type"] != "" && v != "" {
+			qa.Type = &v
+		}
+
+		result := RowResult{Line: line, Question: qa.Question, Answer: qa.Answer}
+		key := NormalizeQuestion(qa.Question)
+
+		switch old, found := known[key]; {
+		case qa.Question == "" || qa.Answer == "":
+			result.Action, result.Reason = ActionInvalid, "题目或答案为空"
+			report.Invalid++
+		case seen[key] > 0:
+			result.Action, result.Reason = ActionSkip, fmt.Sprintf("与文件第 %d 行重复", seen[key])
+			report.Duplicates++
+		case found && old.answer == qa.Answer:
+			result.Action, result.Reason, result.ExistingID = ActionSkip, "题库中已存在且答案相同", old.id
+			report.Duplicates++
+		case found && opts.OnDuplicate == OnDuplicateSkip:
+			result.Action, result.Reason, result.ExistingID = ActionSkip, "题库中已存在", old.id
+			report.Duplicates++
+		case found:
+			qa.ID = old.id
+			result.Action, result.ExistingID = ActionUpdate, old.id
+			report.Updated++
+		default:
+			result.Action = ActionInsert
+			report.Inserted++
+		}
+
+		if result.Action != ActionInvalid && seen[key] == 0 {
+			seen[key] = line
+		}
+		if result.Action == ActionInsert || result.Action == ActionUpdate {
+			batch = append(batch, qa)
+			if len(batch) >= importBatchSize {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if len(report.Rows) < maxReportRows {
+			report.Rows = append(report.Rows, result)
+		} else {
+			report.Truncated = true
+		}
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// ImportFile 从文件导入题库，未指定格式时按扩展名判断
+func ImportFile(path string, opts ImportOptions) (*Report, error) {
+	if opts.Format == "" {
+		format, err := DetectFormat(path)
+		if err != nil {
+			return nil, err
+		}
+		opts.Format = format
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Import(f, opts)
+}
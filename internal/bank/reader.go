@@ -0,0 +1,335 @@
+package bank
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// 支持的文件格式
+const (
+	FormatCSV      = "csv"
+	FormatJSONL    = "jsonl"
+	FormatXLSX     = "xlsx"
+	FormatMarkdown = "md" // 仅支持导出
+)
+
+// Mapping 题库字段对应的文件列名（JSON Lines 中为键名），为空时按常用列名自动识别
+type Mapping struct {
+	Question string `json:"question"`
+	Options  string `json:"options"`
+	Type     string `json:"type"`
+	Answer   string `json:"answer"`
+}
+
+// columnAliases 自动识别时各字段可用的列名（忽略大小写）
+var columnAliases = map[string][]string{
+	"question": {"question", "title", "题目", "问题", "题干"},
+	"options":  {"options", "option", "选项"},
+	"type":     {"type", "题型", "类型"},
+	"answer":   {"answer", "答案"},
+}
+
+// ParseMapping 解析 "question=题目,answer=答案" 形式的列映射
+func ParseMapping(s string) (Mapping, error) {
+	var m Mapping
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		field, column, ok := strings.Cut(pair, "=")
+		if !ok {
+			return m, fmt.Errorf("列映射格式不正确: %s", pair)
+		}
+		column = strings.TrimSpace(column)
+		switch strings.TrimSpace(field) {
+		case "question":
+			m.Question = column
+		case "options":
+			m.Options = column
+		case "type":
+			m.Type = column
+		case "answer":
+			m.Answer = column
+		default:
+			return m, fmt.Errorf("未知的字段: %s", field)
+		}
+	}
+	return m, nil
+}
+
+// resolve 根据表头补全未指定的列名，返回字段到列名的映射
+func (m Mapping) resolve(header []string) map[string]string {
+	resolved := map[string]string{
+		"question": m.Question,
+		"options":  m.Options,
+		"type":     m.Type,
+		"answer":   m.Answer,
+	}
+	for field, column := range resolved {
+		if column != "" {
+			continue
+		}
+		for _, alias := range columnAliases[field] {
+			for _, h := range header {
+				if strings.EqualFold(strings.TrimSpace(h), alias) {
+					resolved[field] = h
+					break
+				}
+			}
+			if resolved[field] != "" {
+				break
+			}
+		}
+	}
+	return resolved
+}
+
+// DetectFormat 根据文件扩展名判断格式
+func DetectFormat(filename string) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".jsonl", ".ndjson", ".json":
+		return FormatJSONL, nil
+	case ".xlsx":
+		return FormatXLSX, nil
+	case ".md", ".markdown":
+		return FormatMarkdown, nil
+	}
+	return "", fmt.Errorf("无法识别文件格式: %s", filename)
+}
+
+// rowReader 逐行读取导入文件，每行为列名到值的映射，line 为文件中的行号
+type rowReader interface {
+	Header() []string
+	Next() (row map[string]string, line int, err error)
+	Close() error
+}
+
+// newRowReader 按格式创建读取器
+func newRowReader(r io.Reader, format, sheet string) (rowReader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatJSONL:
+		return newJSONLReader(r)
+	case FormatXLSX:
+		return newXLSXReader(r, sheet)
+	}
+	return nil, fmt.Errorf("不支持导入 %s 格式", format)
+}
+
+// csvReader 读取带表头的CSV文件
+type csvReader struct {
+	r      *csv.Reader
+	header []string
+	line   int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	br := bufio.NewReader(r)
+	// 跳过Excel导出CSV时常带的UTF-8 BOM
+	if bom, err := br.Peek(3); err == nil && string(bom) == "\xef\xbb\xbf" {
+		br.Discard(3)
+	}
+
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("读取表头失败: %v", err)
+	}
+	return &csvReader{r: cr, header: header, line: 1}, nil
+}
+
+func (c *csvReader) Header() []string { return c.header }
+
+func (c *csvReader) Next() (map[string]string, int, error) {
+	record, err := c.r.Read()
+	if err != nil {
+		return nil, c.line, err
+	}
+	c.line, _ = c.r.FieldPos(0)
+	return zipRow(c.header, record), c.line, nil
+}
+
+func (c *csvReader) Close() error { return nil }
+
+// jsonlReader 读取每行一个JSON对象的文件，也兼容整个文件为JSON数组的情况；
+// 选项可以是字符串或字符串数组，行号为第几条记录
+type jsonlReader struct {
+	dec     *json.Decoder
+	array   bool
+	line    int
+	pending map[string]string // 预读的第一条记录，用于识别键名
+	err     error
+}
+
+func newJSONLReader(r io.Reader) (*jsonlReader, error) {
+	br := bufio.NewReader(r)
+	j := &jsonlReader{}
+	for {
+		c, _, err := br.ReadRune()
+		if err != nil {
+			break
+		}
+		if !unicode.IsSpace(c) && c != '\ufeff' {
+			br.UnreadRune()
+			j.array = c == '['
+			break
+		}
+	}
+
+	j.dec = json.NewDecoder(br)
+	j.dec.UseNumber()
+	if j.array {
+		if _, err := j.dec.Token(); err != nil {
+			return nil, err
+		}
+	}
+	j.pending, _, j.err = j.read()
+	return j, nil
+}
+
+// Header 返回第一条记录的键名
+func (j *jsonlReader) Header() []string {
+	header := make([]string, 0, len(j.pending))
+	for k := range j.pending {
+		header = append(header, k)
+	}
+	sort.Strings(header)
+	return header
+}
+
+func (j *jsonlReader) Next() (map[string]string, int, error) {
+	if j.pending != nil || j.err != nil {
+		row, err := j.pending, j.err
+		j.pending, j.err = nil, nil
+		return row, 1, err
+	}
+	return j.read()
+}
+
+// read 解码下一条记录
+func (j *jsonlReader) read() (map[string]string, int, error) {
+	if j.array && !j.dec.More() {
+		return nil, j.line, io.EOF
+	}
+	j.line++
+
+	var obj map[string]interface{}
+	if err := j.dec.Decode(&obj); err != nil {
+		if err == io.EOF {
+			return nil, j.line, err
+		}
+		return nil, j.line, fmt.Errorf("第 %d 条记录不是有效的JSON对象: %v", j.line, err)
+	}
+	row := make(map[string]string, len(obj))
+	for k, v := range obj {
+		row[k] = jsonString(v)
+	}
+	return row, j.line, nil
+}
+
+func (j *jsonlReader) Close() error { return nil }
+
+// jsonString 将JSON值转换为字符串，数组按行拼接
+func jsonString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case []interface{}:
+		parts := make([]string, len(val))
+		for i, item := range val {
+			parts[i] = jsonString(item)
+		}
+		return strings.Join(parts, "\n")
+	default:
+		data, _ := json.Marshal(val)
+		return string(data)
+	}
+}
+
+// xlsxReader 流式读取Excel工作表，第一行为表头
+type xlsxReader struct {
+	file   *excelize.File
+	rows   *excelize.Rows
+	header []string
+	line   int
+}
+
+func newXLSXReader(r io.Reader, sheet string) (*xlsxReader, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("无法打开Excel文件: %v", err)
+	}
+	if sheet == "" {
+		sheet = f.GetSheetName(f.GetActiveSheetIndex())
+	}
+	rows, err := f.Rows(sheet)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("无法读取工作表 %s: %v", sheet, err)
+	}
+
+	x := &xlsxReader{file: f, rows: rows}
+	if !rows.Next() {
+		x.Close()
+		return nil, fmt.Errorf("工作表 %s 为空", sheet)
+	}
+	x.line = 1
+	if x.header, err = rows.Columns(); err != nil {
+		x.Close()
+		return nil, err
+	}
+	return x, nil
+}
+
+func (x *xlsxReader) Header() []string { return x.header }
+
+func (x *xlsxReader) Next() (map[string]string, int, error) {
+	if !x.rows.Next() {
+		if err := x.rows.Error(); err != nil {
+			return nil, x.line, err
+		}
+		return nil, x.line, io.EOF
+	}
+	x.line++
+	record, err := x.rows.Columns()
+	if err != nil {
+		return nil, x.line, err
+	}
+	return zipRow(x.header, record), x.line, nil
+}
+
+func (x *xlsxReader) Close() error {
+	x.rows.Close()
+	return x.file.Close()
+}
+
+// zipRow 将表头和一行数据组合为映射
+func zipRow(header, record []string) map[string]string {
+	row := make(map[string]string, len(header))
+	for i, h := range header {
+		if i < len(record) {
+			row[h] = record[i]
+		}
+	}
+	return row
+}
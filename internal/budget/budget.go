@@ -0,0 +1,275 @@
+package budget
+
+import (
+	"ai-ocs/internal/ai"
+	"ai-ocs/internal/database"
+	"ai-ocs/internal/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Status 全局每日费用预算的当前状态，供管理后台展示
+type Status struct {
+	Enabled          bool       `json:"enabled"`
+	DailyLimit       float64    `json:"daily_limit"`
+	WarnRatio        float64    `json:"warn_ratio"`
+	Action           string     `json:"action"`
+	FallbackPlatform string     `json:"fallback_platform,omitempty"`
+	FallbackModel    string     `json:"fallback_model,omitempty"`
+	Day              string     `json:"day"`
+	SpentToday       float64    `json:"spent_today"`
+	Warned           bool       `json:"warned"`
+	Tripped          bool       `json:"tripped"`
+	TrippedAt        *time.Time `json:"tripped_at,omitempty"`
+	Manual           bool       `json:"manual"`     // 管理员手动熔断，直到手动恢复
+	Overridden       bool       `json:"overridden"` // 费用超过上限后管理员手动恢复，当日不再自动熔断
+}
+
+// alert 预警或熔断通知，同时作为 Webhook 的请求体
+type alert struct {
+	Event   string  `json:"event"` // budget_warning 或 budget_tripped
+	Day     string  `json:"day"`
+	Spent   float64 `json:"spent"`
+	Limit   float64 `json:"limit"`
+	Action  string  `json:"action"`
+	Message string  `json:"message"`
+}
+
+// refreshInterval 从数据库重新统计当日费用的间隔，期间按每次调用的费用累加
+const refreshInterval = 30 * time.Second
+
+var (
+	mu          sync.Mutex
+	status      Status
+	config      *models.Config
+	refreshedAt time.Time
+	refreshing  bool
+)
+
+// Start 按配置初始化预算状态；daily_limit 为0时不会自动熔断，但管理员仍可手动熔断
+func Start(cfg *models.Config) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	config = cfg
+	bc := cfg.Budget
+	status.Enabled = bc.DailyLimit > 0
+	status.DailyLimit = bc.DailyLimit
+	status.WarnRatio = bc.WarnRatio
+	status.Action = bc.Action
+	status.FallbackPlatform = bc.FallbackPlatform
+	status.FallbackModel = bc.FallbackModel
+
+	if status.Action == models.BudgetActionFallback {
+		if !ai.IsPlatform(bc.FallbackPlatform) {
+			log.Printf("费用预算的 fallback_platform 无效: %q，熔断后将只返回已有答案", bc.FallbackPlatform)
+			status.Action = models.BudgetActionCacheOnly
+		} else if status.FallbackModel == "" {
+			status.FallbackModel = cfg.Models[bc.FallbackPlatform]
+		}
+	} else if status.Action != models.BudgetActionCacheOnly {
+		log.Printf("未知的费用预算处理方式: %q，熔断后将只返回已有答案", status.Action)
+		status.Action = models.BudgetActionCacheOnly
+	}
+
+	if status.Enabled && len(cfg.Prices) == 0 {
+		log.Printf("警告: 已设置每日费用预算，但未配置 prices，所有AI调用的费用都按0计算，预算不会熔断")
+	}
+	if status.Enabled {
+		log.Printf("已启用每日费用预算 %.4f，达到后%s", status.DailyLimit, actionText(status))
+	}
+}
+
+// Check 返回当前是否已熔断；熔断且处理方式为 fallback 时同时返回应改用的平台和模型，否则应只返回已有答案
+func Check() (bool, *ai.Route) {
+	refreshIfStale(time.Now())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !status.Tripped {
+		return false, nil
+	}
+	if status.Action == models.BudgetActionFallback {
+		return true, &ai.Route{Platform: status.FallbackPlatform, Model: status.FallbackModel}
+	}
+	return true, nil
+}
+
+// Record 累加一次AI调用的费用，达到预警或上限时发出通知
+func Record(cost float64) {
+	if cost <= 0 {
+		return
+	}
+	mu.Lock()
+	rollDayLocked(time.Now())
+	status.SpentToday += cost
+	alerts := evaluateLocked(time.Now())
+	mu.Unlock()
+
+	notify(alerts)
+}
+
+// Trip 管理员手动熔断，直到手动恢复
+func Trip() {
+	now := time.Now()
+	mu.Lock()
+	rollDayLocked(now)
+	status.Manual = true
+	status.Overridden = false
+	if !status.Tripped {
+		status.Tripped = true
+		status.TrippedAt = &now
+	}
+	mu.Unlock()
+	log.Printf("管理员手动触发费用熔断")
+}
+
+// Resume 管理员恢复AI调用；当日费用已达到上限时，当日不再自动熔断
+func Resume() {
+	mu.Lock()
+	rollDayLocked(time.Now())
+	status.Tripped = false
+	status.TrippedAt = nil
+	status.Manual = false
+	status.Overridden = status.Enabled && status.SpentToday >= status.DailyLimit
+	mu.Unlock()
+	log.Printf("管理员已恢复AI调用")
+}
+
+// GetStatus 返回当前状态
+func GetStatus() Status {
+	refreshIfStale(time.Now())
+
+	mu.Lock()
+	defer mu.Unlock()
+	return status
+}
+
+// rollDayLocked 跨天时重置当日费用和熔断状态，手动熔断保持不变；需持有 mu
+func rollDayLocked(now time.Time) {
+	day := now.Format("2006-01-02")
+	if status.Day == day {
+		return
+	}
+	status.Day = day
+	status.SpentToday = 0
+	status.Warned = false
+	status.Overridden = false
+	if !status.Manual {
+		status.Tripped = false
+		status.TrippedAt = nil
+	}
+	refreshedAt = time.Time{}
+}
+
+// evaluateLocked 检查当日费用是否达到预警线或上限，返回需要发送的通知；需持有 mu
+func evaluateLocked(now time.Time) []alert {
+	if !status.Enabled {
+		return nil
+	}
+
+	var alerts []alert
+	spent, limit := status.SpentToday, status.DailyLimit
+	if !status.Warned && spent >= limit*status.WarnRatio {
+		status.Warned = true
+		alerts = append(alerts, alert{
+			Event: "budget_warning", Day: status.Day, Spent: spent, Limit: limit, Action: status.Action,
+			Message: fmt.Sprintf("今日AI费用 %.4f 已达到预算 %.4f 的 %.0f%%", spent, limit, status.WarnRatio*100),
+		})
+	}
+	if !status.Tripped && !status.Overridden && spent >= limit {
+		status.Tripped = true
+		status.TrippedAt = &now
+		alerts = append(alerts, alert{
+			Event: "budget_tripped", Day: status.Day, Spent: spent, Limit: limit, Action: status.Action,
+			Message: fmt.Sprintf("今日AI费用 %.4f 已达到预算 %.4f，%s", spent, limit, actionText(status)),
+		})
+	}
+	return alerts
+}
+
+// refreshIfStale 距上次统计超过 refreshInterval 时从数据库重新统计当日费用
+func refreshIfStale(now time.Time) {
+	mu.Lock()
+	rollDayLocked(now)
+	if config == nil || refreshing || now.Sub(refreshedAt) < refreshInterval {
+		mu.Unlock()
+		return
+	}
+	refreshing = true
+	day := status.Day
+	mu.Unlock()
+
+	spent, err := spentOn(day, now)
+
+	mu.Lock()
+	refreshing = false
+	var alerts []alert
+	if err != nil {
+		log.Printf("统计今日AI费用失败: %v", err)
+	} else if status.Day == day {
+		refreshedAt = now
+		status.SpentToday = spent
+		alerts = evaluateLocked(now)
+	}
+	mu.Unlock()
+
+	notify(alerts)
+}
+
+// spentOn 统计当日所有API密钥的费用，以及按价格表计算的后台复核费用
+func spentOn(day string, now time.Time) (float64, error) {
+	total, err := database.DailyCost(day)
+	if err != nil {
+		return 0, err
+	}
+
+	y, m, d := now.Date()
+	usage, err := database.VerificationUsageSince(time.Date(y, m, d, 0, 0, 0, 0, now.Location()))
+	if err != nil {
+		return 0, err
+	}
+	for _, u := range usage {
+		total += config.Cost(u.Platform, u.Model, int(u.PromptTokens), int(u.CompletionTokens))
+	}
+	return total, nil
+}
+
+// notify 记录日志并发送 Webhook 通知
+func notify(alerts []alert) {
+	for _, a := range alerts {
+		log.Print(a.Message)
+		if config.Budget.AlertWebhook != "" {
+			go sendWebhook(config.Budget.AlertWebhook, a)
+		}
+	}
+}
+
+func sendWebhook(url string, a alert) {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("发送费用预算通知失败: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		log.Printf("发送费用预算通知失败，状态码: %d", resp.StatusCode)
+	}
+}
+
+func actionText(s Status) string {
+	if s.Action == models.BudgetActionFallback {
+		return fmt.Sprintf("改用 %s/%s", s.FallbackPlatform, s.FallbackModel)
+	}
+	return "只返回题库中已有的答案"
+}
@@ -0,0 +1,228 @@
+package database
+
+import (
+	"ai-ocs/internal/models"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"log"
+	"strings"
+	"time"
+)
+
+// apiKeyPrefixLen 密钥前缀的长度，前缀以明文保存，用于识别和查找密钥
+const apiKeyPrefixLen = 8
+
+// generateKeySalt 生成随机盐
+func generateKeySalt() (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(salt), nil
+}
+
+// hashAPIKey 计算加盐后的密钥哈希，保存在 api_keys.api_key 字段中。
+// key_salt 为空的记录是旧版本保存的明文密钥，由 HashPlaintextAPIKeys 转换
+func hashAPIKey(key, salt string) string {
+	sum := sha256.Sum256([]byte(salt + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// apiKeyMatches 以常数时间比较密钥的哈希值
+func apiKeyMatches(key, salt, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashAPIKey(key, salt)), []byte(hash)) == 1
+}
+
+// HashPlaintextAPIKeys 将明文保存的密钥转换为前缀加哈希，原有密钥仍然可以继续使用。
+// 数据库升级时执行一次；恢复旧版本的备份或从旧版本的数据库迁移数据后也需要执行
+func HashPlaintextAPIKeys() error {
+	rows, err := db.Query("SELECT id, api_key FROM api_keys WHERE key_salt IS NULL OR key_salt = ''")
+	if err != nil {
+		return err
+	}
+	plaintext := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var key string
+		if err := rows.Scan(&id, &key); err != nil {
+			rows.Close()
+			return err
+		}
+		plaintext[id] = key
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(plaintext) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for id, key := range plaintext {
+		salt, err := generateKeySalt()
+		if err != nil {
+			return err
+		}
+		prefix := key
+		if len(prefix) > apiKeyPrefixLen {
+			prefix = prefix[:apiKeyPrefixLen]
+		}
+		_, err = tx.Exec("UPDATE api_keys SET api_key = ?, key_prefix = ?, key_salt = ? WHERE id = ?",
+			hashAPIKey(key, salt), prefix, salt, id)
+		if err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("已将 %d 个明文API密钥转换为哈希存储", len(plaintext))
+	return nil
+}
+
+// splitScopes 解析逗号分隔的权限范围
+func splitScopes(s string) []string {
+	scopes := []string{}
+	for _, scope := range strings.Split(s, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// formatProviders 将路由保存为逗号分隔的 平台[:模型] 列表
+func formatProviders(routes []models.ProviderRoute) string {
+	parts := make([]string, 0, len(routes))
+	for _, r := range routes {
+		if r.Model != "" {
+			parts = append(parts, r.Platform+":"+r.Model)
+		} else {
+			parts = append(parts, r.Platform)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseProviders 解析 formatProviders 保存的路由，模型名中可以包含冒号
+func parseProviders(s string) []models.ProviderRoute {
+	routes := []models.ProviderRoute{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		platform, model, _ := strings.Cut(part, ":")
+		routes = append(routes, models.ProviderRoute{Platform: platform, Model: model})
+	}
+	return routes
+}
+
+// UpdateAPIKeyRouting 修改API密钥未命中缓存时使用的平台、模型和提示词模板
+func UpdateAPIKeyRouting(id int64, routing models.APIKeyRouting) error {
+	result, err := db.Exec("UPDATE api_keys SET providers = ?, prompt_template = ? WHERE id = ?",
+		formatProviders(routing.Providers), routing.PromptTemplate, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return apiKeyExists(id)
+	}
+	return nil
+}
+
+// UpdateAPIKey 修改API密钥的描述、启用状态、过期时间和权限范围
+func UpdateAPIKey(key *models.APIKey) error {
+	result, err := db.Exec(`
+		UPDATE api_keys
+		SET description = ?, enabled = ?, expires_at = ?, scopes = ?
+		WHERE id = ?
+	`, key.Description, key.Enabled, key.ExpiresAt, strings.Join(key.Scopes, ","), key.ID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return apiKeyExists(key.ID)
+	}
+	return nil
+}
+
+// apiKeyExists 密钥不存在时返回 sql.ErrNoRows。
+// MySQL 在更新的值未变化时影响行数为0，需要据此确认密钥是否存在
+func apiKeyExists(id int64) error {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM api_keys WHERE id = ?", id).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RotateAPIKey 为密钥生成新的密文，保留密钥记录的描述、权限、调用限制和用量统计。
+// 旧密文在 grace 时长内仍然有效，grace 为0时立即失效；上一次轮换遗留的旧密文会被替换
+func RotateAPIKey(id int64, grace time.Duration) (*models.APIKey, error) {
+	key, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	salt, err := generateKeySalt()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var oldHash, oldSalt, oldPrefix string
+	err = tx.QueryRow("SELECT api_key, key_salt, key_prefix FROM api_keys WHERE id = ?", id).Scan(&oldHash, &oldSalt, &oldPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	rotated := &models.APIKey{ID: id, APIKey: key, Prefix: key[:apiKeyPrefixLen]}
+	var prevHash, prevSalt, prevPrefix interface{}
+	var prevExpiresAt *time.Time
+	if grace > 0 {
+		expires := time.Now().Add(grace)
+		prevHash, prevSalt, prevPrefix, prevExpiresAt = oldHash, oldSalt, oldPrefix, &expires
+		rotated.PreviousPrefix = oldPrefix
+		rotated.PreviousExpiresAt = prevExpiresAt
+	}
+
+	_, err = tx.Exec(`
+		UPDATE api_keys
+		SET api_key = ?, key_salt = ?, key_prefix = ?,
+			previous_key_hash = ?, previous_key_salt = ?, previous_key_prefix = ?, previous_expires_at = ?
+		WHERE id = ?
+	`, hashAPIKey(key, salt), salt, rotated.Prefix, prevHash, prevSalt, prevPrefix, prevExpiresAt, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return rotated, tx.Commit()
+}
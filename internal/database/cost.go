@@ -0,0 +1,117 @@
+package database
+
+import (
+	"ai-ocs/internal/models"
+	"time"
+)
+
+// RecordAICost 按日期、平台和模型累计API密钥一次AI调用的token用量和费用
+func RecordAICost(keyID int64, now time.Time, platform, model string, promptTokens, completionTokens int, cost float64) error {
+	var query string
+	if dbType == "sqlite" {
+		query = `INSERT INTO api_key_daily_cost (api_key_id, day, platform, model, requests, prompt_tokens, completion_tokens, cost)
+			VALUES (?, ?, ?, ?, 1, ?, ?, ?)
+			ON CONFLICT (api_key_id, day, platform, model) DO UPDATE SET
+				requests = requests + 1,
+				prompt_tokens = prompt_tokens + excluded.prompt_tokens,
+				completion_tokens = completion_tokens + excluded.completion_tokens,
+				cost = cost + excluded.cost`
+	} else {
+		query = `INSERT INTO api_key_daily_cost (api_key_id, day, platform, model, requests, prompt_tokens, completion_tokens, cost)
+			VALUES (?, ?, ?, ?, 1, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE
+				requests = requests + 1,
+				prompt_tokens = prompt_tokens + VALUES(prompt_tokens),
+				completion_tokens = completion_tokens + VALUES(completion_tokens),
+				cost = cost + VALUES(cost)`
+	}
+	_, err := db.Exec(query, keyID, quotaDay(now), platform, model, promptTokens, completionTokens, cost)
+	return err
+}
+
+// GetCostReport 统计 from 到 to（均包含，格式为 YYYY-MM-DD）之间的AI调用费用，按API密钥、平台和模型、日期分别汇总
+func GetCostReport(from, to string) (*models.CostReport, error) {
+	report := &models.CostReport{From: from, To: to}
+
+	// 已删除的密钥仍保留费用记录，LEFT JOIN 取不到描述时为空
+	byKey, err := queryCostRollups(`
+		SELECT c.api_key_id, COALESCE(k.description, ''),
+			SUM(c.requests), SUM(c.prompt_tokens), SUM(c.completion_tokens), SUM(c.cost)
+		FROM api_key_daily_cost c
+		LEFT JOIN api_keys k ON k.id = c.api_key_id
+		WHERE c.day >= ? AND c.day <= ?
+		GROUP BY c.api_key_id, k.description
+		ORDER BY SUM(c.cost) DESC, SUM(c.requests) DESC
+	`, from, to, func(r *models.CostRollup) []interface{} {
+		return []interface{}{&r.APIKeyID, &r.Description}
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.ByKey = byKey
+
+	byProvider, err := queryCostRollups(`
+		SELECT platform, model,
+			SUM(requests), SUM(prompt_tokens), SUM(completion_tokens), SUM(cost)
+		FROM api_key_daily_cost
+		WHERE day >= ? AND day <= ?
+		GROUP BY platform, model
+		ORDER BY SUM(cost) DESC, SUM(requests) DESC
+	`, from, to, func(r *models.CostRollup) []interface{} {
+		return []interface{}{&r.Platform, &r.Model}
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.ByProvider = byProvider
+
+	daily, err := queryCostRollups(`
+		SELECT day,
+			SUM(requests), SUM(prompt_tokens), SUM(completion_tokens), SUM(cost)
+		FROM api_key_daily_cost
+		WHERE day >= ? AND day <= ?
+		GROUP BY day
+		ORDER BY day
+	`, from, to, func(r *models.CostRollup) []interface{} {
+		return []interface{}{&r.Day}
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.Daily = daily
+
+	for _, d := range daily {
+		report.Total.Requests += d.Requests
+		report.Total.PromptTokens += d.PromptTokens
+		report.Total.CompletionTokens += d.CompletionTokens
+		report.Total.Cost += d.Cost
+	}
+	return report, nil
+}
+
+// queryCostRollups 执行费用汇总查询，groupBy 返回分组字段的扫描目标，其后依次为请求数、token用量和费用
+func queryCostRollups(query, from, to string, groupBy func(*models.CostRollup) []interface{}) ([]*models.CostRollup, error) {
+	rows, err := db.Query(query, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rollups := []*models.CostRollup{}
+	for rows.Next() {
+		r := &models.CostRollup{}
+		dest := append(groupBy(r), &r.Requests, &r.PromptTokens, &r.CompletionTokens, &r.Cost)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		rollups = append(rollups, r)
+	}
+	return rollups, rows.Err()
+}
+
+// DailyCost 统计某日所有API密钥的AI调用费用
+func DailyCost(day string) (float64, error) {
+	var total float64
+	err := db.QueryRow("SELECT COALESCE(SUM(cost), 0) FROM api_key_daily_cost WHERE day = ?", day).Scan(&total)
+	return total, err
+}
@@ -7,6 +7,7 @@ import (
 	"ai-ocs/internal/models"
 	"crypto/rand"
 	"encoding/hex"
+	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -18,27 +19,25 @@ var dbType string
 
 // InitDB 初始化数据库连接
 func InitDB(config *models.Config) error {
-	var err error
-	
-	// 根据配置选择数据库类型
-	switch config.DatabaseType {
-	case "sqlite":
-		db, err = initSQLite(config.SQLiteConfig)
-		dbType = "sqlite"
-	case "mysql":
-		fallthrough
-	default:
-		db, err = initMySQL(config.MySQLConfig)
-		dbType = "mysql"
+	if err := Connect(config); err != nil {
+		return err
 	}
-	
-	if err != nil {
-		return fmt.Errorf("无法初始化数据库: %v", err)
+
+	// 确保至少有一个API密钥存在
+	if err := ensureAPIKey(); err != nil {
+		return fmt.Errorf("初始化API密钥失败: %v", err)
 	}
 
-	// 测试连接
-	if err := db.Ping(); err != nil {
-		return fmt.Errorf("无法连接到数据库: %v", err)
+	return nil
+}
+
+// Connect 连接数据库并初始化表结构，但不生成默认API密钥
+// 用于恢复备份、迁移数据等需要保留原有密钥的场景
+func Connect(config *models.Config) error {
+	var err error
+	db, dbType, err = Open(config)
+	if err != nil {
+		return err
 	}
 
 	// 初始化表结构
@@ -51,15 +50,49 @@ func InitDB(config *models.Config) error {
 		return fmt.Errorf("检查并修复api_keys表失败: %v", err)
 	}
 
-	// 确保至少有一个API密钥存在
-	if err := ensureAPIKey(); err != nil {
-		return fmt.Errorf("初始化API密钥失败: %v", err)
+	// 补齐新版本增加的字段
+	if err := migrateSchema(); err != nil {
+		return fmt.Errorf("升级数据库表结构失败: %v", err)
 	}
 
+	// 创建题目全文索引
+	initFullText()
+
 	log.Printf("数据库连接成功，使用 %s 数据库", dbType)
 	return nil
 }
 
+// Open 按配置打开数据库连接并测试连通性，不修改表结构，返回连接和数据库类型
+func Open(config *models.Config) (*sql.DB, string, error) {
+	var conn *sql.DB
+	var connType string
+	var err error
+	
+	// 根据配置选择数据库类型
+	switch config.DatabaseType {
+	case "sqlite":
+		conn, err = initSQLite(config.SQLiteConfig)
+		connType = "sqlite"
+	case "mysql":
+		fallthrough
+	default:
+		conn, err = initMySQL(config.MySQLConfig)
+		connType = "mysql"
+	}
+	
+	if err != nil {
+		return nil, "", fmt.Errorf("无法初始化数据库: %v", err)
+	}
+
+	// 测试连接
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, "", fmt.Errorf("无法连接到数据库: %v", err)
+	}
+
+	return conn, connType, nil
+}
+
 // initMySQL 初始化MySQL数据库连接
 func initMySQL(config models.MySQLConfig) (*sql.DB, error) {
 	// 构建MySQL连接字符串
@@ -85,8 +118,14 @@ func initMySQL(config models.MySQLConfig) (*sql.DB, error) {
 
 // initSQLite 初始化SQLite数据库连接
 func initSQLite(config models.SQLiteConfig) (*sql.DB, error) {
-	// 构建SQLite连接字符串
+	// 构建SQLite连接字符串：写锁被占用时等待而不是立即返回 database is locked，
+	// 事务开始时即获取写锁，避免并发事务在升级为写锁时失败
 	dsn := config.Path
+	if strings.Contains(dsn, "?") {
+		dsn += "&_busy_timeout=5000&_txlock=immediate"
+	} else {
+		dsn += "?_busy_timeout=5000&_txlock=immediate"
+	}
 
 	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
@@ -107,6 +146,11 @@ func initSchema() error {
 	var createIndexSQL string
 	var createAPIKeyTableSQL string
 	var createAPIKeyUsageTableSQL string
+	var createQueryLogTableSQL []string
+	var createRevisionTableSQL []string
+	var createVerificationTableSQL []string
+	var createFeedbackTableSQL []string
+	var createQuotaTableSQL []string
 	
 	// 根据数据库类型选择合适的SQL语法
 	if dbType == "sqlite" {
@@ -139,6 +183,99 @@ func initSchema() error {
 			last_used_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE CASCADE
 		);`
+		
+		createQueryLogTableSQL = []string{`
+		CREATE TABLE IF NOT EXISTS query_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			api_key_id INTEGER NOT NULL DEFAULT 0,
+			question TEXT NOT NULL,
+			options TEXT,
+			type TEXT,
+			cache_hit INTEGER NOT NULL DEFAULT 0,
+			platform TEXT,
+			model TEXT,
+			latency_ms INTEGER NOT NULL DEFAULT 0,
+			prompt_tokens INTEGER NOT NULL DEFAULT 0,
+			completion_tokens INTEGER NOT NULL DEFAULT 0,
+			status TEXT NOT NULL,
+			error TEXT,
+			client_ip TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+			`CREATE INDEX IF NOT EXISTS idx_query_log_created_at ON query_log(created_at);`,
+			`CREATE INDEX IF NOT EXISTS idx_query_log_api_key ON query_log(api_key_id, created_at);`,
+		}
+		
+		createRevisionTableSQL = []string{`
+		CREATE TABLE IF NOT EXISTS answer_revisions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			question_id INTEGER NOT NULL,
+			answer TEXT NOT NULL,
+			previous_answer TEXT,
+			source TEXT NOT NULL,
+			platform TEXT,
+			model TEXT,
+			operator TEXT,
+			reason TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+			`CREATE INDEX IF NOT EXISTS idx_answer_revisions_question ON answer_revisions(question_id);`,
+		}
+
+		createVerificationTableSQL = []string{`
+		CREATE TABLE IF NOT EXISTS answer_verifications (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			question_id INTEGER NOT NULL,
+			answer TEXT NOT NULL,
+			verifier_answer TEXT,
+			status TEXT NOT NULL,
+			platform TEXT,
+			model TEXT,
+			prompt_tokens INTEGER NOT NULL DEFAULT 0,
+			completion_tokens INTEGER NOT NULL DEFAULT 0,
+			error TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+			`CREATE INDEX IF NOT EXISTS idx_answer_verifications_question ON answer_verifications(question_id);`,
+			`CREATE INDEX IF NOT EXISTS idx_answer_verifications_created ON answer_verifications(created_at);`,
+		}
+
+		createFeedbackTableSQL = []string{`
+		CREATE TABLE IF NOT EXISTS answer_feedback (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			question_id INTEGER NOT NULL,
+			api_key_id INTEGER NOT NULL DEFAULT 0,
+			answer TEXT NOT NULL,
+			correct BOOLEAN NOT NULL,
+			suggested_answer TEXT,
+			client_ip TEXT,
+			resolved BOOLEAN NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+			`CREATE INDEX IF NOT EXISTS idx_answer_feedback_question ON answer_feedback(question_id, resolved);`,
+		}
+
+		createQuotaTableSQL = []string{`
+		CREATE TABLE IF NOT EXISTS api_key_daily_usage (
+			api_key_id INTEGER NOT NULL,
+			day TEXT NOT NULL,
+			requests INTEGER NOT NULL DEFAULT 0,
+			ai_requests INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (api_key_id, day)
+		);`, `
+		CREATE TABLE IF NOT EXISTS api_key_daily_cost (
+			api_key_id INTEGER NOT NULL,
+			day TEXT NOT NULL,
+			platform TEXT NOT NULL,
+			model TEXT NOT NULL,
+			requests INTEGER NOT NULL DEFAULT 0,
+			prompt_tokens INTEGER NOT NULL DEFAULT 0,
+			completion_tokens INTEGER NOT NULL DEFAULT 0,
+			cost REAL NOT NULL DEFAULT 0,
+			PRIMARY KEY (api_key_id, day, platform, model)
+		);`,
+			`CREATE INDEX IF NOT EXISTS idx_api_key_daily_cost_day ON api_key_daily_cost(day);`,
+		}
 	} else {
 		// MySQL语法
 		createTableSQL = `
@@ -170,6 +307,94 @@ func initSchema() error {
 			last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE CASCADE
 		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+		
+		createQueryLogTableSQL = []string{`
+		CREATE TABLE IF NOT EXISTS query_log (
+			id BIGINT PRIMARY KEY AUTO_INCREMENT,
+			api_key_id INTEGER NOT NULL DEFAULT 0,
+			question TEXT NOT NULL,
+			options TEXT,
+			type VARCHAR(32),
+			cache_hit TINYINT NOT NULL DEFAULT 0,
+			platform VARCHAR(32),
+			model VARCHAR(128),
+			latency_ms INTEGER NOT NULL DEFAULT 0,
+			prompt_tokens INTEGER NOT NULL DEFAULT 0,
+			completion_tokens INTEGER NOT NULL DEFAULT 0,
+			status VARCHAR(16) NOT NULL,
+			error TEXT,
+			client_ip VARCHAR(64),
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_query_log_created_at (created_at),
+			INDEX idx_query_log_api_key (api_key_id, created_at)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`}
+		
+		createRevisionTableSQL = []string{`
+		CREATE TABLE IF NOT EXISTS answer_revisions (
+			id BIGINT PRIMARY KEY AUTO_INCREMENT,
+			question_id INTEGER NOT NULL,
+			answer TEXT NOT NULL,
+			previous_answer TEXT,
+			source VARCHAR(16) NOT NULL,
+			platform VARCHAR(32),
+			model VARCHAR(128),
+			operator VARCHAR(64),
+			reason TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_answer_revisions_question (question_id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`}
+
+		createVerificationTableSQL = []string{`
+		CREATE TABLE IF NOT EXISTS answer_verifications (
+			id BIGINT PRIMARY KEY AUTO_INCREMENT,
+			question_id INTEGER NOT NULL,
+			answer TEXT NOT NULL,
+			verifier_answer TEXT,
+			status VARCHAR(16) NOT NULL,
+			platform VARCHAR(32),
+			model VARCHAR(128),
+			prompt_tokens INTEGER NOT NULL DEFAULT 0,
+			completion_tokens INTEGER NOT NULL DEFAULT 0,
+			error TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_answer_verifications_question (question_id),
+			INDEX idx_answer_verifications_created (created_at)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`}
+
+		createFeedbackTableSQL = []string{`
+		CREATE TABLE IF NOT EXISTS answer_feedback (
+			id BIGINT PRIMARY KEY AUTO_INCREMENT,
+			question_id INTEGER NOT NULL,
+			api_key_id INTEGER NOT NULL DEFAULT 0,
+			answer TEXT NOT NULL,
+			correct BOOLEAN NOT NULL,
+			suggested_answer TEXT,
+			client_ip VARCHAR(64),
+			resolved BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_answer_feedback_question (question_id, resolved)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`}
+
+		createQuotaTableSQL = []string{`
+		CREATE TABLE IF NOT EXISTS api_key_daily_usage (
+			api_key_id INTEGER NOT NULL,
+			day VARCHAR(10) NOT NULL,
+			requests BIGINT NOT NULL DEFAULT 0,
+			ai_requests BIGINT NOT NULL DEFAULT 0,
+			PRIMARY KEY (api_key_id, day)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`, `
+		CREATE TABLE IF NOT EXISTS api_key_daily_cost (
+			api_key_id INTEGER NOT NULL,
+			day VARCHAR(10) NOT NULL,
+			platform VARCHAR(32) NOT NULL,
+			model VARCHAR(128) NOT NULL,
+			requests BIGINT NOT NULL DEFAULT 0,
+			prompt_tokens BIGINT NOT NULL DEFAULT 0,
+			completion_tokens BIGINT NOT NULL DEFAULT 0,
+			cost DOUBLE NOT NULL DEFAULT 0,
+			PRIMARY KEY (api_key_id, day, platform, model),
+			INDEX idx_api_key_daily_cost_day (day)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`}
 	}
 
 	_, err := db.Exec(createTableSQL)
@@ -194,6 +419,41 @@ func initSchema() error {
 	if err != nil {
 		return err
 	}
+	
+	// 创建查询日志表
+	for _, stmt := range createQueryLogTableSQL {
+		if _, err = db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	
+	// 创建答案历史版本表
+	for _, stmt := range createRevisionTableSQL {
+		if _, err = db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	// 创建答案复核记录表
+	for _, stmt := range createVerificationTableSQL {
+		if _, err = db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	// 创建用户反馈表
+	for _, stmt := range createFeedbackTableSQL {
+		if _, err = db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	// 创建API密钥每日用量及费用表
+	for _, stmt := range createQuotaTableSQL {
+		if _, err = db.Exec(stmt); err != nil {
+			return err
+		}
+	}
 
 	log.Println("数据库表初始化成功")
 	return nil
@@ -273,17 +533,13 @@ func ensureAPIKey() error {
 
 	// 如果没有API密钥，则生成一个默认的
 	if count == 0 {
-		apiKey, err := generateAPIKey()
+		key, err := CreateAPIKey("默认API密钥", nil, nil)
 		if err != nil {
 			return err
 		}
 		
-		_, err = db.Exec("INSERT INTO api_keys (api_key, description) VALUES (?, ?)", apiKey, "默认API密钥")
-		if err != nil {
-			return err
-		}
-		
-		log.Printf("已生成默认API密钥: %s", apiKey)
+		// 数据库中只保存哈希值，完整密钥只在生成时显示这一次
+		log.Printf("已生成默认API密钥: %s（只显示这一次，请妥善保存）", key.APIKey)
 	}
 	
 	return nil
@@ -308,57 +564,128 @@ func GetDBType() string {
 	return dbType
 }
 
-// GetAnswer 根据问题查询答案
-func GetAnswer(question string) (string, error) {
-	var answer string
-	err := db.QueryRow("SELECT answer FROM question_answer WHERE question = ? LIMIT 1", question).Scan(&answer)
+// GetAnswer 根据问题查询题库记录，没有找到时返回nil
+func GetAnswer(question string) (*models.QuestionAnswer, error) {
+	row := db.QueryRow("SELECT "+questionColumns+" FROM question_answer WHERE question = ? LIMIT 1", question)
+	qa, err := scanQuestion(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return "", nil // 没有找到答案，返回空字符串而不是错误
+			return nil, nil // 没有找到答案，返回nil而不是错误
 		}
-		return "", err
+		return nil, err
 	}
-	return answer, nil
+	return qa, nil
 }
 
-// SaveAnswer 保存问题和答案到数据库
-func SaveAnswer(question, answer string) error {
-	// 检查问题是否已存在
-	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM question_answer WHERE question = ?", question).Scan(&count)
+// SaveAnswer 保存问题和答案到数据库，答案有变化时记录历史版本
+func SaveAnswer(question, answer string, source models.AnswerSource) error {
+	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	if count > 0 {
+	// 检查问题是否已存在
+	var id int64
+	var oldAnswer string
+	err = tx.QueryRow("SELECT id, answer FROM question_answer WHERE question = ? LIMIT 1", question).Scan(&id, &oldAnswer)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if err == nil {
 		// 如果问题已存在，则更新答案
-		_, err = db.Exec("UPDATE question_answer SET answer = ? WHERE question = ?", answer, question)
+		if err := updateAnswerTx(tx, id, oldAnswer, answer, source); err != nil {
+			return err
+		}
 	} else {
 		// 如果问题不存在，则插入新记录
-		_, err = db.Exec("INSERT INTO question_answer (question, answer) VALUES (?, ?)", question, answer)
+		result, err := tx.Exec(`
+			INSERT INTO question_answer (question, answer, source, platform, model, confidence, prompt_tokens, completion_tokens, updated_at, review_status)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, question, answer, source.Source, source.Platform, source.Model, source.Confidence,
+			source.PromptTokens, source.CompletionTokens, time.Now(), reviewStatusFor(source))
+		if err != nil {
+			return err
+		}
+		if id, err = result.LastInsertId(); err != nil {
+			return err
+		}
+		if err := insertRevisionTx(tx, id, "", answer, source); err != nil {
+			return err
+		}
 	}
 	
-	return err
+	return tx.Commit()
 }
 
-// ValidateAPIKey 验证API密钥是否有效
-func ValidateAPIKey(apiKey string) (bool, error) {
-	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM api_keys WHERE api_key = ?", apiKey).Scan(&count)
+// FindAPIKey 根据API密钥获取密钥的状态、权限范围及调用限制，密钥不存在时返回 nil
+func FindAPIKey(apiKey string) (*models.APIKey, error) {
+	if len(apiKey) < apiKeyPrefixLen {
+		return nil, nil
+	}
+
+	// 按前缀找出候选密钥（包括宽限期内的旧密钥），再逐个以常数时间比较哈希值
+	prefix := apiKey[:apiKeyPrefixLen]
+	now := time.Now()
+	rows, err := db.Query(`
+		SELECT id, api_key, key_salt, key_prefix,
+			COALESCE(previous_key_hash, ''), COALESCE(previous_key_salt, ''), COALESCE(previous_key_prefix, ''), previous_expires_at,
+			description, created_at, enabled, expires_at, scopes,
+			COALESCE(providers, ''), COALESCE(prompt_template, ''), `+apiKeyLimitColumns+`
+		FROM api_keys
+		WHERE key_prefix = ? OR (previous_key_prefix = ? AND previous_expires_at > ?)
+	`, prefix, prefix, now)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	return count > 0, nil
+	defer rows.Close()
+
+	for rows.Next() {
+		var key models.APIKey
+		var hash, salt, prevHash, prevSalt, scopes, providers string
+		var expiresAt, prevExpiresAt sql.NullTime
+		err := rows.Scan(&key.ID, &hash, &salt, &key.Prefix,
+			&prevHash, &prevSalt, &key.PreviousPrefix, &prevExpiresAt,
+			&key.Description, &key.CreatedAt, &key.Enabled, &expiresAt, &scopes,
+			&providers, &key.PromptTemplate,
+			&key.RatePerSecond, &key.RatePerMinute, &key.DailyQuota, &key.MonthlyQuota, &key.DailyAIQuota,
+			&key.DailyCostCap, &key.MonthlyCostCap)
+		if err != nil {
+			return nil, err
+		}
+
+		inGrace := prevExpiresAt.Valid && now.Before(prevExpiresAt.Time)
+		switch {
+		case key.Prefix == prefix && apiKeyMatches(apiKey, salt, hash):
+		case inGrace && key.PreviousPrefix == prefix && apiKeyMatches(apiKey, prevSalt, prevHash):
+			key.UsingPrevious = true
+		default:
+			continue
+		}
+
+		if expiresAt.Valid {
+			key.ExpiresAt = &expiresAt.Time
+		}
+		if inGrace {
+			key.PreviousExpiresAt = &prevExpiresAt.Time
+		} else {
+			key.PreviousPrefix = ""
+		}
+		key.Scopes = splitScopes(scopes)
+		key.Providers = parseProviders(providers)
+		return &key, nil
+	}
+	return nil, rows.Err()
 }
 
-// GetDefaultAPIKey 获取默认API密钥
-func GetDefaultAPIKey() (string, error) {
-	var apiKey string
-	err := db.QueryRow("SELECT api_key FROM api_keys ORDER BY created_at ASC LIMIT 1").Scan(&apiKey)
+// ValidateAPIKey 验证API密钥是否有效
+func ValidateAPIKey(apiKey string) (bool, error) {
+	key, err := FindAPIKey(apiKey)
 	if err != nil {
-		return "", err
+		return false, err
 	}
-	return apiKey, nil
+	return key != nil, nil
 }
 
 // GetAllAPIKeys 获取所有API密钥
@@ -366,11 +693,16 @@ func GetAllAPIKeys() ([]*models.APIKey, error) {
 	rows, err := db.Query(`
 		SELECT 
 			k.id, 
-			k.api_key, 
+			COALESCE(k.key_prefix, ''), 
 			k.description, 
 			k.created_at,
 			COALESCE(u.call_count, 0) as call_count,
-			u.last_used_at
+			u.last_used_at,
+			k.enabled, k.expires_at, k.scopes,
+			COALESCE(k.previous_key_prefix, ''), k.previous_expires_at,
+			COALESCE(k.providers, ''), COALESCE(k.prompt_template, ''),
+			k.rate_per_second, k.rate_per_minute, k.daily_quota, k.monthly_quota, k.daily_ai_quota,
+			k.daily_cost_cap, k.monthly_cost_cap
 		FROM api_keys k
 		LEFT JOIN api_key_usage u ON k.id = u.api_key_id
 		ORDER BY k.created_at DESC
@@ -383,8 +715,14 @@ func GetAllAPIKeys() ([]*models.APIKey, error) {
 	var apiKeys []*models.APIKey
 	for rows.Next() {
 		var apiKey models.APIKey
-		var lastUsedAt sql.NullTime
-		err := rows.Scan(&apiKey.ID, &apiKey.APIKey, &apiKey.Description, &apiKey.CreatedAt, &apiKey.CallCount, &lastUsedAt)
+		var lastUsedAt, expiresAt, prevExpiresAt sql.NullTime
+		var scopes, providers string
+		err := rows.Scan(&apiKey.ID, &apiKey.Prefix, &apiKey.Description, &apiKey.CreatedAt, &apiKey.CallCount, &lastUsedAt,
+			&apiKey.Enabled, &expiresAt, &scopes,
+			&apiKey.PreviousPrefix, &prevExpiresAt,
+			&providers, &apiKey.PromptTemplate,
+			&apiKey.RatePerSecond, &apiKey.RatePerMinute, &apiKey.DailyQuota, &apiKey.MonthlyQuota, &apiKey.DailyAIQuota,
+			&apiKey.DailyCostCap, &apiKey.MonthlyCostCap)
 		if err != nil {
 			return nil, err
 		}
@@ -394,6 +732,17 @@ func GetAllAPIKeys() ([]*models.APIKey, error) {
 		} else {
 			apiKey.LastUsedAt = apiKey.CreatedAt
 		}
+		if expiresAt.Valid {
+			apiKey.ExpiresAt = &expiresAt.Time
+		}
+		apiKey.Scopes = splitScopes(scopes)
+		apiKey.Providers = parseProviders(providers)
+		// 只显示仍在宽限期内的旧密钥
+		if prevExpiresAt.Valid && prevExpiresAt.Time.After(time.Now()) {
+			apiKey.PreviousExpiresAt = &prevExpiresAt.Time
+		} else {
+			apiKey.PreviousPrefix = ""
+		}
 		
 		apiKeys = append(apiKeys, &apiKey)
 	}
@@ -402,19 +751,40 @@ func GetAllAPIKeys() ([]*models.APIKey, error) {
 		return nil, err
 	}
 
+	// 附带当日及当月的用量
+	usage, err := getAllQuotaUsage(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	for _, apiKey := range apiKeys {
+		apiKey.Usage = usage[apiKey.ID]
+	}
+
 	return apiKeys, nil
 }
 
-// CreateAPIKey 创建新的API密钥
-func CreateAPIKey(description string) (*models.APIKey, error) {
+// CreateAPIKey 创建新的API密钥，scopes 为空时使用默认权限范围
+func CreateAPIKey(description string, scopes []string, expiresAt *time.Time) (*models.APIKey, error) {
 	// 生成新的API密钥
 	key, err := generateAPIKey()
 	if err != nil {
 		return nil, err
 	}
 
-	// 插入到数据库
-	result, err := db.Exec("INSERT INTO api_keys (api_key, description) VALUES (?, ?)", key, description)
+	if len(scopes) == 0 {
+		scopes = models.DefaultAPIScopes
+	}
+
+	salt, err := generateKeySalt()
+	if err != nil {
+		return nil, err
+	}
+
+	// 插入到数据库，只保存前缀和加盐哈希
+	result, err := db.Exec(`
+		INSERT INTO api_keys (api_key, key_prefix, key_salt, description, enabled, expires_at, scopes)
+		VALUES (?, ?, ?, ?, TRUE, ?, ?)
+	`, hashAPIKey(key, salt), key[:apiKeyPrefixLen], salt, description, expiresAt, strings.Join(scopes, ","))
 	if err != nil {
 		return nil, err
 	}
@@ -429,8 +799,12 @@ func CreateAPIKey(description string) (*models.APIKey, error) {
 	return &models.APIKey{
 		ID:          id,
 		APIKey:      key,
+		Prefix:      key[:apiKeyPrefixLen],
 		Description: description,
 		CreatedAt:   time.Now(),
+		Enabled:     true,
+		ExpiresAt:   expiresAt,
+		Scopes:      scopes,
 	}, nil
 }
 
@@ -450,45 +824,30 @@ func DeleteAPIKey(id int64) error {
 
 	// 删除API密钥
 	_, err = db.Exec("DELETE FROM api_keys WHERE id = ?", id)
-	return err
-}
-
-// IncrementAPIKeyUsage 增加API密钥调用次数
-func IncrementAPIKeyUsage(apiKey string) error {
-	// 获取API密钥ID
-	var keyID int64
-	err := db.QueryRow("SELECT id FROM api_keys WHERE api_key = ?", apiKey).Scan(&keyID)
 	if err != nil {
 		return err
 	}
 
-	// 检查是否已存在使用记录
-	var count int
-	err = db.QueryRow("SELECT COUNT(*) FROM api_key_usage WHERE api_key_id = ?", keyID).Scan(&count)
-	if err != nil {
-		return err
-	}
+	// 费用记录保留，删除密钥不影响历史费用统计和当日费用预算
+	_, err = db.Exec("DELETE FROM api_key_daily_usage WHERE api_key_id = ?", id)
+	return err
+}
 
-	// 根据数据库类型选择合适的时间函数
-	timeFunc := "NOW()"
-	if dbType == "sqlite" {
-		timeFunc = "DATETIME('now')"
-	}
+// IncrementAPIKeyUsage 增加API密钥调用次数
+func IncrementAPIKeyUsage(keyID int64) error {
+	return AddAPIKeyUsage(keyID, 1)
+}
 
-	if count > 0 {
-		// 如果已存在记录，则更新调用次数和最后使用时间
-		_, err = db.Exec(`
-			UPDATE api_key_usage 
-			SET call_count = call_count + 1, last_used_at = `+timeFunc+`
-			WHERE api_key_id = ?
-		`, keyID)
+// AddAPIKeyUsage 将API密钥的调用次数增加 n 并更新最后使用时间，批量查询按题目数一次累加
+func AddAPIKeyUsage(keyID int64, n int64) error {
+	var query string
+	if dbType == "sqlite" {
+		query = `INSERT INTO api_key_usage (api_key_id, call_count, last_used_at) VALUES (?, ?, DATETIME('now'))
+			ON CONFLICT (api_key_id) DO UPDATE SET call_count = call_count + excluded.call_count, last_used_at = excluded.last_used_at`
 	} else {
-		// 如果不存在记录，则插入新记录
-		_, err = db.Exec(`
-			INSERT INTO api_key_usage (api_key_id, call_count, last_used_at)
-			VALUES (?, 1, `+timeFunc+`)
-		`, keyID)
+		query = `INSERT INTO api_key_usage (api_key_id, call_count, last_used_at) VALUES (?, ?, NOW())
+			ON DUPLICATE KEY UPDATE call_count = call_count + VALUES(call_count), last_used_at = VALUES(last_used_at)`
 	}
-
+	_, err := db.Exec(query, keyID, n)
 	return err
 }
@@ -0,0 +1,245 @@
+package database
+
+import (
+	"ai-ocs/internal/models"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrNoAnswer 题目当前没有可反馈的答案
+var ErrNoAnswer = errors.New("题目暂无答案")
+
+// FeedbackResult 保存反馈后当前答案的票数，以及触发的自动处理
+type FeedbackResult struct {
+	CorrectVotes int64  `json:"correct_votes"`
+	WrongVotes   int64  `json:"wrong_votes"`
+	Action       string `json:"action,omitempty"`
+}
+
+// RecordFeedback 保存一次反馈并汇总当前答案的票数，错误票达到阈值时按配置自动处理。
+// 同一API密钥对同一答案重复反馈时只保留最后一次
+func RecordFeedback(fb *models.AnswerFeedback, config models.FeedbackConfig) (*FeedbackResult, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var reviewStatus string
+	err = tx.QueryRow("SELECT answer, review_status FROM question_answer WHERE id = ?", fb.QuestionID).Scan(&fb.Answer, &reviewStatus)
+	if err != nil {
+		return nil, err
+	}
+	if fb.Answer == "" {
+		return nil, ErrNoAnswer
+	}
+	if fb.CreatedAt.IsZero() {
+		fb.CreatedAt = time.Now()
+	}
+
+	var existingID int64
+	err = tx.QueryRow(`
+		SELECT id FROM answer_feedback
+		WHERE question_id = ? AND api_key_id = ? AND answer = ? AND resolved = FALSE
+		LIMIT 1
+	`, fb.QuestionID, fb.APIKeyID, fb.Answer).Scan(&existingID)
+	switch {
+	case err == nil:
+		fb.ID = existingID
+		_, err = tx.Exec("UPDATE answer_feedback SET correct = ?, suggested_answer = ?, client_ip = ?, created_at = ? WHERE id = ?",
+			fb.Correct, fb.SuggestedAnswer, fb.ClientIP, fb.CreatedAt, existingID)
+	case err == sql.ErrNoRows:
+		var result sql.Result
+		result, err = tx.Exec(`
+			INSERT INTO answer_feedback (question_id, api_key_id, answer, correct, suggested_answer, client_ip, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, fb.QuestionID, fb.APIKeyID, fb.Answer, fb.Correct, fb.SuggestedAnswer, fb.ClientIP, fb.CreatedAt)
+		if err == nil {
+			fb.ID, err = result.LastInsertId()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := &FeedbackResult{}
+	err = tx.QueryRow(`
+		SELECT COALESCE(SUM(CASE WHEN correct THEN 1 ELSE 0 END), 0), COALESCE(SUM(CASE WHEN correct THEN 0 ELSE 1 END), 0)
+		FROM answer_feedback
+		WHERE question_id = ? AND answer = ? AND resolved = FALSE
+	`, fb.QuestionID, fb.Answer).Scan(&res.CorrectVotes, &res.WrongVotes)
+	if err != nil {
+		return nil, err
+	}
+
+	// 已有争议或已驳回的答案等待人工处理，不再重复处理
+	total := res.CorrectVotes + res.WrongVotes
+	pending := reviewStatus == models.ReviewDisputed || reviewStatus == models.ReviewRejected
+	if !pending && config.Action != models.FeedbackActionNone &&
+		res.WrongVotes >= int64(config.WrongThreshold) && float64(res.WrongVotes) >= config.WrongRatio*float64(total) {
+		if err := applyFeedbackActionTx(tx, fb.QuestionID, fb.Answer, config.Action); err != nil {
+			return nil, err
+		}
+		res.Action = config.Action
+	}
+
+	return res, tx.Commit()
+}
+
+// applyFeedbackActionTx 对错误票达到阈值的答案执行自动处理
+func applyFeedbackActionTx(tx *sql.Tx, questionID int64, answer, action string) error {
+	var err error
+	switch action {
+	case models.FeedbackActionFlag:
+		_, err = tx.Exec("UPDATE question_answer SET review_status = ? WHERE id = ?", models.ReviewDisputed, questionID)
+	case models.FeedbackActionInvalidate:
+		err = updateAnswerTx(tx, questionID, answer, "", models.AnswerSource{
+			Source: models.AnswerSourceFeedback,
+			Reason: "用户反馈答案错误，已作废",
+		})
+	default:
+		_, err = tx.Exec("UPDATE question_answer SET review_status = ?, confidence = 0 WHERE id = ?", models.ReviewDisputed, questionID)
+	}
+	return err
+}
+
+// GetFeedbackQueue 按题目汇总反馈，resolved 为 false 时返回待处理的审核队列，错误票多的排在前面
+func GetFeedbackQueue(resolved bool, limit, offset int) ([]*models.FeedbackSummary, int64, error) {
+	var total int64
+	err := db.QueryRow("SELECT COUNT(DISTINCT question_id) FROM answer_feedback WHERE resolved = ?", resolved).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Query(`
+		SELECT q.id, q.question, q.answer, q.confidence, q.review_status,
+			SUM(CASE WHEN f.answer = q.answer AND f.correct THEN 1 ELSE 0 END) AS correct_votes,
+			SUM(CASE WHEN f.answer = q.answer AND NOT f.correct THEN 1 ELSE 0 END) AS wrong_votes,
+			SUM(CASE WHEN f.answer <> q.answer THEN 1 ELSE 0 END) AS stale_votes,
+			MAX(f.id) AS last_id
+		FROM answer_feedback f
+		JOIN question_answer q ON q.id = f.question_id
+		WHERE f.resolved = ?
+		GROUP BY q.id, q.question, q.answer, q.confidence, q.review_status
+		ORDER BY wrong_votes DESC, last_id DESC
+		LIMIT ? OFFSET ?
+	`, resolved, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var results []*models.FeedbackSummary
+	byQuestion := make(map[int64]*models.FeedbackSummary)
+	var questionIDs, lastIDs []interface{}
+	for rows.Next() {
+		var s models.FeedbackSummary
+		var confidence sql.NullFloat64
+		var lastID int64
+		err := rows.Scan(&s.QuestionID, &s.Question, &s.Answer, &confidence, &s.ReviewStatus,
+			&s.CorrectVotes, &s.WrongVotes, &s.StaleVotes, &lastID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if confidence.Valid {
+			s.Confidence = &confidence.Float64
+		}
+		s.Suggestions = []models.FeedbackSuggestion{}
+		results = append(results, &s)
+		byQuestion[s.QuestionID] = &s
+		questionIDs = append(questionIDs, s.QuestionID)
+		lastIDs = append(lastIDs, lastID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(results) == 0 {
+		return results, total, nil
+	}
+
+	// 最近一次反馈的时间
+	placeholders := "?" + strings.Repeat(", ?", len(lastIDs)-1)
+	timeRows, err := db.Query("SELECT question_id, created_at FROM answer_feedback WHERE id IN ("+placeholders+")", lastIDs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer timeRows.Close()
+	for timeRows.Next() {
+		var questionID int64
+		var createdAt time.Time
+		if err := timeRows.Scan(&questionID, &createdAt); err != nil {
+			return nil, 0, err
+		}
+		byQuestion[questionID].LastFeedbackAt = createdAt
+	}
+	if err = timeRows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// 调用方建议的答案，按建议次数排序
+	args := append([]interface{}{resolved}, questionIDs...)
+	suggestionRows, err := db.Query(`
+		SELECT question_id, suggested_answer, COUNT(*) AS cnt
+		FROM answer_feedback
+		WHERE resolved = ? AND suggested_answer IS NOT NULL AND suggested_answer <> '' AND question_id IN (`+placeholders+`)
+		GROUP BY question_id, suggested_answer
+		ORDER BY cnt DESC
+	`, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer suggestionRows.Close()
+	for suggestionRows.Next() {
+		var questionID int64
+		var sg models.FeedbackSuggestion
+		if err := suggestionRows.Scan(&questionID, &sg.Answer, &sg.Count); err != nil {
+			return nil, 0, err
+		}
+		s := byQuestion[questionID]
+		s.Suggestions = append(s.Suggestions, sg)
+	}
+	if err = suggestionRows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return results, total, nil
+}
+
+// ResolveFeedback 处理一道题的全部待处理反馈：answer 不为空时用它替换当前答案，
+// 为空时确认当前答案，审核状态和置信度按 source 设置
+func ResolveFeedback(questionID int64, answer string, source models.AnswerSource) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldAnswer string
+	err = tx.QueryRow("SELECT answer FROM question_answer WHERE id = ?", questionID).Scan(&oldAnswer)
+	if err != nil {
+		return err
+	}
+
+	if answer == "" && oldAnswer == "" {
+		return ErrNoAnswer
+	}
+
+	if answer != "" && answer != oldAnswer {
+		err = updateAnswerTx(tx, questionID, oldAnswer, answer, source)
+	} else {
+		_, err = tx.Exec("UPDATE question_answer SET review_status = ?, confidence = ? WHERE id = ?",
+			reviewStatusFor(source), source.Confidence, questionID)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE answer_feedback SET resolved = TRUE WHERE question_id = ? AND resolved = FALSE", questionID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
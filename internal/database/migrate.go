@@ -0,0 +1,214 @@
+package database
+
+import (
+	"ai-ocs/internal/models"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// columnDef 需要追加到已有表中的字段定义
+type columnDef struct {
+	Table  string
+	Name   string
+	SQLite string // SQLite字段类型及约束
+	MySQL  string // MySQL字段类型及约束
+}
+
+// schemaColumns 在基础表结构之后新增的字段，启动时自动补齐，旧数据库无需手动迁移
+var schemaColumns = []columnDef{
+	{"question_answer", "source", "TEXT", "VARCHAR(16)"},
+	{"question_answer", "platform", "TEXT", "VARCHAR(32)"},
+	{"question_answer", "model", "TEXT", "VARCHAR(128)"},
+	{"question_answer", "confidence", "REAL", "DOUBLE"},
+	{"question_answer", "prompt_tokens", "INTEGER NOT NULL DEFAULT 0", "INTEGER NOT NULL DEFAULT 0"},
+	{"question_answer", "completion_tokens", "INTEGER NOT NULL DEFAULT 0", "INTEGER NOT NULL DEFAULT 0"},
+	{"question_answer", "hit_count", "INTEGER NOT NULL DEFAULT 0", "INTEGER NOT NULL DEFAULT 0"},
+	{"question_answer", "updated_at", "DATETIME", "TIMESTAMP NULL"},
+	{"question_answer", "verified_at", "DATETIME", "TIMESTAMP NULL"},
+	{"question_answer", "verify_status", "TEXT", "VARCHAR(16)"},
+	{"question_answer", "review_status", "TEXT NOT NULL DEFAULT 'unverified'", "VARCHAR(16) NOT NULL DEFAULT 'unverified'"},
+	{"api_keys", "rate_per_second", "INTEGER NOT NULL DEFAULT 0", "INTEGER NOT NULL DEFAULT 0"},
+	{"api_keys", "rate_per_minute", "INTEGER NOT NULL DEFAULT 0", "INTEGER NOT NULL DEFAULT 0"},
+	{"api_keys", "daily_quota", "INTEGER NOT NULL DEFAULT 0", "BIGINT NOT NULL DEFAULT 0"},
+	{"api_keys", "monthly_quota", "INTEGER NOT NULL DEFAULT 0", "BIGINT NOT NULL DEFAULT 0"},
+	{"api_keys", "daily_ai_quota", "INTEGER NOT NULL DEFAULT 0", "BIGINT NOT NULL DEFAULT 0"},
+	{"api_keys", "daily_cost_cap", "REAL NOT NULL DEFAULT 0", "DOUBLE NOT NULL DEFAULT 0"},
+	{"api_keys", "monthly_cost_cap", "REAL NOT NULL DEFAULT 0", "DOUBLE NOT NULL DEFAULT 0"},
+	{"api_keys", "enabled", "BOOLEAN NOT NULL DEFAULT 1", "BOOLEAN NOT NULL DEFAULT TRUE"},
+	{"api_keys", "expires_at", "DATETIME", "TIMESTAMP NULL"},
+	{"api_keys", "scopes", "TEXT NOT NULL DEFAULT 'query,feedback'", "VARCHAR(255) NOT NULL DEFAULT 'query,feedback'"},
+	{"api_keys", "key_prefix", "TEXT", "VARCHAR(16)"},
+	{"api_keys", "key_salt", "TEXT", "VARCHAR(32)"},
+	{"api_keys", "previous_key_hash", "TEXT", "VARCHAR(64)"},
+	{"api_keys", "previous_key_salt", "TEXT", "VARCHAR(32)"},
+	{"api_keys", "previous_key_prefix", "TEXT", "VARCHAR(16)"},
+	{"api_keys", "previous_expires_at", "DATETIME", "TIMESTAMP NULL"},
+	{"api_keys", "providers", "TEXT", "VARCHAR(512)"},
+	{"api_keys", "prompt_template", "TEXT", "VARCHAR(64)"},
+	{"query_log", "metadata", "TEXT", "TEXT"},
+}
+
+// migrateSchema 补齐缺失的字段
+func migrateSchema() error {
+	for _, col := range schemaColumns {
+		added, err := ensureColumn(col)
+		if err != nil {
+			return fmt.Errorf("添加字段 %s.%s 失败: %v", col.Table, col.Name, err)
+		}
+		if !added {
+			continue
+		}
+
+		log.Printf("已为 %s 表添加字段 %s", col.Table, col.Name)
+
+		// 已有题目的更新时间以创建时间为准
+		if col.Table == "question_answer" && col.Name == "updated_at" {
+			if _, err := db.Exec("UPDATE question_answer SET updated_at = created_at WHERE updated_at IS NULL"); err != nil {
+				return err
+			}
+		}
+
+		if col.Table == "question_answer" && col.Name == "review_status" {
+			if err := initReviewStatus(); err != nil {
+				return err
+			}
+		}
+
+		// 旧版本以明文保存的API密钥改为保存哈希值，只需在添加 key_salt 字段时执行一次
+		if col.Table == "api_keys" && col.Name == "key_salt" {
+			if err := HashPlaintextAPIKeys(); err != nil {
+				return fmt.Errorf("转换API密钥失败: %v", err)
+			}
+		}
+	}
+
+	if err := ensureAPIKeyUsageUnique(); err != nil {
+		return fmt.Errorf("为 api_key_usage 表添加唯一索引失败: %v", err)
+	}
+
+	// 验证密钥时按前缀查找，轮换期间同时查找旧密钥的前缀
+	for _, idx := range []struct{ name, column string }{
+		{"idx_api_keys_key_prefix", "key_prefix"},
+		{"idx_api_keys_previous_key_prefix", "previous_key_prefix"},
+	} {
+		if err := ensureIndex("api_keys", idx.name, idx.column); err != nil {
+			return fmt.Errorf("为 api_keys 表添加索引 %s 失败: %v", idx.name, err)
+		}
+	}
+	return nil
+}
+
+// indexExists 检查表中是否存在指定索引
+func indexExists(table, index string) (bool, error) {
+	var count int
+	var err error
+	if dbType == "sqlite" {
+		err = db.QueryRow("SELECT COUNT(*) FROM pragma_index_list(?) WHERE name = ?", table, index).Scan(&count)
+	} else {
+		err = db.QueryRow(`SELECT COUNT(*) FROM information_schema.statistics
+			WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?`, table, index).Scan(&count)
+	}
+	return count > 0, err
+}
+
+// ensureIndex 索引不存在时创建，MySQL不支持 CREATE INDEX IF NOT EXISTS，因此先检查
+func ensureIndex(table, index, columns string) error {
+	exists, err := indexExists(table, index)
+	if err != nil || exists {
+		return err
+	}
+	_, err = db.Exec("CREATE INDEX " + index + " ON " + table + "(" + columns + ")")
+	return err
+}
+
+// ensureAPIKeyUsageUnique 为 api_key_usage.api_key_id 添加唯一索引，使调用次数可以原子地累加。
+// 旧版本并发请求可能为同一密钥写入多条记录，先合并为一条
+func ensureAPIKeyUsageUnique() error {
+	const index = "idx_api_key_usage_key"
+	exists, err := indexExists("api_key_usage", index)
+	if err != nil || exists {
+		return err
+	}
+
+	rows, err := db.Query(`
+		SELECT api_key_id, MIN(id), SUM(call_count), MAX(last_used_at)
+		FROM api_key_usage
+		GROUP BY api_key_id
+		HAVING COUNT(*) > 1
+	`)
+	if err != nil {
+		return err
+	}
+	type duplicate struct {
+		keyID, keepID, calls int64
+		lastUsed             interface{} // 聚合结果在SQLite中为字符串，原样写回
+	}
+	var duplicates []duplicate
+	for rows.Next() {
+		var d duplicate
+		var calls sql.NullInt64
+		if err := rows.Scan(&d.keyID, &d.keepID, &calls, &d.lastUsed); err != nil {
+			rows.Close()
+			return err
+		}
+		d.calls = calls.Int64
+		duplicates = append(duplicates, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, d := range duplicates {
+		if _, err := db.Exec("UPDATE api_key_usage SET call_count = ?, last_used_at = ? WHERE id = ?",
+			d.calls, d.lastUsed, d.keepID); err != nil {
+			return err
+		}
+		if _, err := db.Exec("DELETE FROM api_key_usage WHERE api_key_id = ? AND id <> ?", d.keyID, d.keepID); err != nil {
+			return err
+		}
+	}
+	if len(duplicates) > 0 {
+		log.Printf("已合并 %d 个API密钥的重复使用记录", len(duplicates))
+	}
+
+	_, err = db.Exec("CREATE UNIQUE INDEX " + index + " ON api_key_usage(api_key_id)")
+	return err
+}
+
+// initReviewStatus 初始化已有题目的审核状态：管理员确认过的答案视为已审核
+func initReviewStatus() error {
+	_, err := db.Exec("UPDATE question_answer SET review_status = ? WHERE source = ? OR confidence >= 1",
+		models.ReviewVerified, models.AnswerSourceAdmin)
+	return err
+}
+
+// columnExists 检查表中是否存在指定字段
+func columnExists(table, column string) (bool, error) {
+	var count int
+	var err error
+	if dbType == "sqlite" {
+		err = db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count)
+	} else {
+		err = db.QueryRow(`SELECT COUNT(*) FROM information_schema.columns
+			WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?`, table, column).Scan(&count)
+	}
+	return count > 0, err
+}
+
+// ensureColumn 字段不存在时添加，返回是否新添加了字段
+func ensureColumn(col columnDef) (bool, error) {
+	exists, err := columnExists(col.Table, col.Name)
+	if err != nil || exists {
+		return false, err
+	}
+
+	definition := col.MySQL
+	if dbType == "sqlite" {
+		definition = col.SQLite
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", col.Table, col.Name, definition))
+	return err == nil, err
+}
@@ -0,0 +1,214 @@
+package database
+
+import (
+	"ai-ocs/internal/models"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+)
+
+// queryLogCh 查询日志异步写入队列
+var queryLogCh chan *models.QueryLog
+
+// QueryLogFilter 查询日志筛选条件
+type QueryLogFilter struct {
+	APIKeyID int64
+	CacheHit *bool
+	Platform string
+	Status   string
+	Keyword  string
+	From     time.Time
+	To       time.Time
+}
+
+// StartQueryLogger 启动查询日志的异步写入和过期清理
+func StartQueryLogger(config models.QueryLogConfig) {
+	if config.Disabled {
+		log.Println("查询日志已关闭")
+		return
+	}
+
+	queryLogCh = make(chan *models.QueryLog, config.BufferSize)
+	go runQueryLogWriter()
+	go runQueryLogCleaner(config.RetentionDays)
+}
+
+// LogQuery 将查询日志放入写入队列，不阻塞请求处理
+func LogQuery(entry *models.QueryLog) {
+	if queryLogCh == nil {
+		return
+	}
+
+	select {
+	case queryLogCh <- entry:
+	default:
+		log.Println("查询日志队列已满，丢弃一条日志")
+	}
+}
+
+// runQueryLogWriter 从队列中批量取出日志写入数据库
+func runQueryLogWriter() {
+	const batchSize = 100
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	batch := make([]*models.QueryLog, 0, batchSize)
+	for {
+		select {
+		case entry := <-queryLogCh:
+			batch = append(batch, entry)
+			if len(batch) < batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+
+		if err := insertQueryLogs(batch); err != nil {
+			log.Printf("写入查询日志失败: %v", err)
+		}
+		batch = batch[:0]
+	}
+}
+
+// insertQueryLogs 在一个事务中写入一批查询日志
+func insertQueryLogs(entries []*models.QueryLog) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO query_log (api_key_id, question, options, type, cache_hit, platform, model,
+			latency_ms, prompt_tokens, completion_tokens, status, error, client_ip, metadata, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, e := range entries {
+		var metadata sql.NullString
+		if e.Metadata != nil {
+			if data, err := json.Marshal(e.Metadata); err == nil {
+				metadata = sql.NullString{String: string(data), Valid: true}
+			}
+		}
+		_, err = stmt.Exec(e.APIKeyID, e.Question, e.Options, e.Type, e.CacheHit, e.Platform, e.Model,
+			e.LatencyMs, e.PromptTokens, e.CompletionTokens, e.Status, e.Error, e.ClientIP, metadata, e.CreatedAt)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// runQueryLogCleaner 定期删除超过保留天数的日志
+func runQueryLogCleaner(retentionDays int) {
+	for {
+		cutoff := time.Now().AddDate(0, 0, -retentionDays)
+		result, err := db.Exec("DELETE FROM query_log WHERE created_at < ?", cutoff)
+		if err != nil {
+			log.Printf("清理过期查询日志失败: %v", err)
+		} else if n, _ := result.RowsAffected(); n > 0 {
+			log.Printf("已清理 %d 条过期查询日志", n)
+		}
+
+		time.Sleep(time.Hour)
+	}
+}
+
+// GetQueryLogs 按条件分页查询日志，返回日志列表和总数
+func GetQueryLogs(filter QueryLogFilter, limit, offset int) ([]*models.QueryLog, int64, error) {
+	var conds []string
+	var args []interface{}
+
+	if filter.APIKeyID > 0 {
+		conds = append(conds, "l.api_key_id = ?")
+		args = append(args, filter.APIKeyID)
+	}
+	if filter.CacheHit != nil {
+		conds = append(conds, "l.cache_hit = ?")
+		args = append(args, *filter.CacheHit)
+	}
+	if filter.Platform != "" {
+		conds = append(conds, "l.platform = ?")
+		args = append(args, filter.Platform)
+	}
+	if filter.Status != "" {
+		conds = append(conds, "l.status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.Keyword != "" {
+		conds = append(conds, "l.question LIKE ?")
+		args = append(args, "%"+filter.Keyword+"%")
+	}
+	if !filter.From.IsZero() {
+		conds = append(conds, "l.created_at >= ?")
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		conds = append(conds, "l.created_at < ?")
+		args = append(args, filter.To)
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int64
+	err := db.QueryRow("SELECT COUNT(*) FROM query_log l"+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Query(`
+		SELECT l.id, l.api_key_id, COALESCE(k.description, ''), l.question, COALESCE(l.options, ''), COALESCE(l.type, ''),
+			l.cache_hit, COALESCE(l.platform, ''), COALESCE(l.model, ''), l.latency_ms, l.prompt_tokens, l.completion_tokens,
+			l.status, COALESCE(l.error, ''), COALESCE(l.client_ip, ''), l.metadata, l.created_at
+		FROM query_log l
+		LEFT JOIN api_keys k ON k.id = l.api_key_id`+where+`
+		ORDER BY l.id DESC LIMIT ? OFFSET ?`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var logs []*models.QueryLog
+	for rows.Next() {
+		var entry models.QueryLog
+		var metadata sql.NullString
+		var createdAt sql.NullTime
+		err := rows.Scan(&entry.ID, &entry.APIKeyID, &entry.APIKeyDesc, &entry.Question, &entry.Options, &entry.Type,
+			&entry.CacheHit, &entry.Platform, &entry.Model, &entry.LatencyMs, &entry.PromptTokens, &entry.CompletionTokens,
+			&entry.Status, &entry.Error, &entry.ClientIP, &metadata, &createdAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		if metadata.Valid && metadata.String != "" {
+			var m models.QueryMetadata
+			if json.Unmarshal([]byte(metadata.String), &m) == nil {
+				entry.Metadata = &m
+			}
+		}
+		if createdAt.Valid {
+			entry.CreatedAt = createdAt.Time
+		}
+		logs = append(logs, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return logs, total, nil
+}
@@ -0,0 +1,375 @@
+package database
+
+import (
+	"ai-ocs/internal/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// questionColumns 查询题库记录时使用的字段，顺序与 scanQuestion 一致
+const questionColumns = "id, question, answer, options, type, created_at, source, platform, model, confidence, prompt_tokens, completion_tokens, hit_count, updated_at, verified_at, verify_status, review_status"
+
+// rowScanner 兼容 *sql.Row 和 *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// prefixedQuestionColumns 返回带表别名前缀的题库字段
+func prefixedQuestionColumns(alias string) string {
+	cols := strings.Split(questionColumns, ", ")
+	for i, col := range cols {
+		cols[i] = alias + "." + col
+	}
+	return strings.Join(cols, ", ")
+}
+
+// scanQuestion 扫描一条题库记录，extra 为 questionColumns 之后追加的字段
+func scanQuestion(row rowScanner, extra ...interface{}) (*models.QuestionAnswer, error) {
+	var qa models.QuestionAnswer
+	var options, qtype, source, platform, model, verifyStatus sql.NullString
+	var confidence sql.NullFloat64
+	var updatedAt, verifiedAt sql.NullTime
+
+	dest := []interface{}{&qa.ID, &qa.Question, &qa.Answer, &options, &qtype, &qa.CreatedAt,
+		&source, &platform, &model, &confidence, &qa.PromptTokens, &qa.CompletionTokens, &qa.HitCount, &updatedAt,
+		&verifiedAt, &verifyStatus, &qa.ReviewStatus}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+
+	if options.Valid {
+		qa.Options = &options.String
+	}
+	if qtype.Valid {
+		qa.Type = &qtype.String
+	}
+	qa.Source = source.String
+	qa.Platform = platform.String
+	qa.Model = model.String
+	if confidence.Valid {
+		qa.Confidence = &confidence.Float64
+	}
+	if updatedAt.Valid {
+		qa.UpdatedAt = &updatedAt.Time
+	}
+	if verifiedAt.Valid {
+		qa.VerifiedAt = &verifiedAt.Time
+	}
+	qa.VerifyStatus = verifyStatus.String
+	return &qa, nil
+}
+
+// ListQuestions 分页获取题库记录，返回记录列表和总数
+func ListQuestions(limit, offset int) ([]*models.QuestionAnswer, int64, error) {
+	var total int64
+	if err := db.QueryRow("SELECT COUNT(*) FROM question_answer").Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Query("SELECT "+questionColumns+" FROM question_answer ORDER BY created_at DESC LIMIT ? OFFSET ?", limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var results []*models.QuestionAnswer
+	for rows.Next() {
+		qa, err := scanQuestion(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		results = append(results, qa)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return results, total, nil
+}
+
+// IncrementHitCount 增加题目的缓存命中次数
+func IncrementHitCount(id int64) error {
+	_, err := db.Exec("UPDATE question_answer SET hit_count = hit_count + 1 WHERE id = ?", id)
+	return err
+}
+
+// QuestionFilter 题库筛选条件，零值表示不筛选
+type QuestionFilter struct {
+	Keyword string // 题目、选项或答案包含的关键词
+	Type    string
+	Source  string // 答案来源，旧数据没有来源时视为 model
+	From    time.Time
+	To      time.Time
+
+	ReviewStatus string // 审核状态，多个状态用逗号分隔
+}
+
+// ParseDateRange 解析 YYYY-MM-DD 格式的起止日期，结束日期包含当天，因此返回的 to 为次日零点，
+// 筛选时使用 from <= t < to。参数为空时对应的时间为零值，表示不限制
+func ParseDateRange(fromDate, toDate string) (from, to time.Time, err error) {
+	if fromDate != "" {
+		from, err = time.ParseInLocation("2006-01-02", fromDate, time.Local)
+		if err != nil {
+			return from, to, fmt.Errorf("无效的开始日期: %s", fromDate)
+		}
+	}
+	if toDate != "" {
+		to, err = time.ParseInLocation("2006-01-02", toDate, time.Local)
+		if err != nil {
+			return from, to, fmt.Errorf("无效的结束日期: %s", toDate)
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	return from, to, nil
+}
+
+// where 构造筛选条件的SQL片段（包含 WHERE）及参数
+func (f QuestionFilter) where() (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+
+	if f.Keyword != "" {
+		like := "%" + f.Keyword + "%"
+		conds = append(conds, "(question LIKE ? OR options LIKE ? OR answer LIKE ?)")
+		args = append(args, like, like, like)
+	}
+	if f.Type != "" {
+		conds = append(conds, "type = ?")
+		args = append(args, f.Type)
+	}
+	if f.Source != "" {
+		conds = append(conds, "COALESCE(source, ?) = ?")
+		args = append(args, models.AnswerSourceModel, f.Source)
+	}
+	if !f.From.IsZero() {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, f.From)
+	}
+	if !f.To.IsZero() {
+		conds = append(conds, "created_at < ?")
+		args = append(args, f.To)
+	}
+	if f.ReviewStatus != "" {
+		statuses := strings.Split(f.ReviewStatus, ",")
+		conds = append(conds, "review_status IN (?"+strings.Repeat(", ?", len(statuses)-1)+")")
+		for _, s := range statuses {
+			args = append(args, strings.TrimSpace(s))
+		}
+	}
+
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// IterateQuestions 按ID顺序逐条遍历符合条件的题目，不会一次性把全部记录读入内存
+func IterateQuestions(filter QuestionFilter, fn func(qa *models.QuestionAnswer) error) error {
+	where, args := filter.where()
+	rows, err := db.Query("SELECT "+questionColumns+" FROM question_answer"+where+" ORDER BY id", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		qa, err := scanQuestion(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(qa); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ImportQuestions 在一个事务中批量写入题目：ID为0的新增，否则更新答案并记录历史版本
+func ImportQuestions(items []*models.QuestionAnswer, source models.AnswerSource) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insert, err := tx.Prepare(`
+		INSERT INTO question_answer (question, answer, options, type, source, platform, model, confidence, prompt_tokens, completion_tokens, updated_at, review_status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+
+	for _, qa := range items {
+		if qa.ID == 0 {
+			result, err := insert.Exec(qa.Question, qa.Answer, qa.Options, qa.Type, source.Source, source.Platform,
+				source.Model, source.Confidence, source.PromptTokens, source.CompletionTokens, time.Now(), reviewStatusFor(source))
+			if err != nil {
+				return err
+			}
+			if qa.ID, err = result.LastInsertId(); err != nil {
+				return err
+			}
+			if err := insertRevisionTx(tx, qa.ID, "", qa.Answer, source); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var oldAnswer string
+		if err := tx.QueryRow("SELECT answer FROM question_answer WHERE id = ?", qa.ID).Scan(&oldAnswer); err != nil {
+			return err
+		}
+		if err := updateAnswerTx(tx, qa.ID, oldAnswer, qa.Answer, source); err != nil {
+			return err
+		}
+		// 导入文件提供了选项或题型时一并更新
+		_, err := tx.Exec("UPDATE question_answer SET options = COALESCE(?, options), type = COALESCE(?, type) WHERE id = ?",
+			qa.Options, qa.Type, qa.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ErrQuestionExists 题库中已存在相同的题目
+var ErrQuestionExists = errors.New("题库中已存在相同的题目")
+
+// GetQuestion 按ID获取题目，不存在时返回 nil
+func GetQuestion(id int64) (*models.QuestionAnswer, error) {
+	row := db.QueryRow("SELECT "+questionColumns+" FROM question_answer WHERE id = ?", id)
+	qa, err := scanQuestion(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return qa, err
+}
+
+// CreateQuestion 手动添加题目并记录第一个答案版本，返回新题目的ID
+func CreateQuestion(qa *models.QuestionAnswer, source models.AnswerSource) (int64, error) {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM question_answer WHERE question = ?", qa.Question).Scan(&count); err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		return 0, ErrQuestionExists
+	}
+
+	item := *qa
+	item.ID = 0
+	if err := ImportQuestions([]*models.QuestionAnswer{&item}, source); err != nil {
+		return 0, err
+	}
+	return item.ID, nil
+}
+
+// UpdateQuestion 修改题目的题干、选项、题型和答案，答案变化时记录历史版本
+func UpdateQuestion(qa *models.QuestionAnswer, source models.AnswerSource) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldAnswer string
+	err = tx.QueryRow("SELECT answer FROM question_answer WHERE id = ?", qa.ID).Scan(&oldAnswer)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	err = tx.QueryRow("SELECT COUNT(*) FROM question_answer WHERE question = ? AND id <> ?", qa.Question, qa.ID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrQuestionExists
+	}
+
+	// 修改题目即视为重新审核，审核状态按来源设置
+	_, err = tx.Exec("UPDATE question_answer SET question = ?, options = ?, type = ?, updated_at = ?, review_status = ? WHERE id = ?",
+		qa.Question, qa.Options, qa.Type, time.Now(), reviewStatusFor(source), qa.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := updateAnswerTx(tx, qa.ID, oldAnswer, qa.Answer, source); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// questionChildTables 通过 question_id 关联题目的表，删除题目时一并删除
+var questionChildTables = []string{"answer_revisions", "answer_verifications", "answer_feedback"}
+
+// DeleteQuestion 删除题目及其答案历史、复核和反馈记录
+func DeleteQuestion(id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM question_answer WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	for _, table := range questionChildTables {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE question_id = ?", id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CountQuestions 统计符合条件的题目数
+func CountQuestions(filter QuestionFilter) (int64, error) {
+	where, args := filter.where()
+	var total int64
+	err := db.QueryRow("SELECT COUNT(*) FROM question_answer"+where, args...).Scan(&total)
+	return total, err
+}
+
+// DeleteQuestions 按条件批量删除题目及其关联记录，返回删除的题目数；筛选条件不能为空
+func DeleteQuestions(filter QuestionFilter) (int64, error) {
+	where, args := filter.where()
+	if where == "" {
+		return 0, errors.New("批量删除必须指定筛选条件")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// 先按条件删除关联记录，题目删除后就无法再找到对应的ID
+	for _, table := range questionChildTables {
+		_, err = tx.Exec("DELETE FROM "+table+" WHERE question_id IN (SELECT id FROM question_answer"+where+")", args...)
+		if err != nil {
+			return 0, err
+		}
+	}
+	result, err := tx.Exec("DELETE FROM question_answer"+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, tx.Commit()
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"ai-ocs/internal/models"
+	"time"
+)
+
+// apiKeyLimitColumns api_keys 表中调用限制字段，顺序与 models.APIKeyLimits 一致
+const apiKeyLimitColumns = "rate_per_second, rate_per_minute, daily_quota, monthly_quota, daily_ai_quota, daily_cost_cap, monthly_cost_cap"
+
+// quotaDay 用量统计的日期，按服务器本地时间划分
+func quotaDay(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+// quotaMonthStart 当月第一天的日期
+func quotaMonthStart(t time.Time) string {
+	return t.Format("2006-01") + "-01"
+}
+
+// GetQuotaUsage 获取API密钥当日及当月的用量
+func GetQuotaUsage(keyID int64, now time.Time) (models.APIKeyQuotaUsage, error) {
+	var usage models.APIKeyQuotaUsage
+	today := quotaDay(now)
+	err := db.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN day = ? THEN requests ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN day = ? THEN ai_requests ELSE 0 END), 0),
+			COALESCE(SUM(requests), 0)
+		FROM api_key_daily_usage
+		WHERE api_key_id = ? AND day >= ? AND day <= ?
+	`, today, today, keyID, quotaMonthStart(now), today).Scan(&usage.TodayRequests, &usage.TodayAIRequests, &usage.MonthRequests)
+	if err != nil {
+		return usage, err
+	}
+
+	err = db.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN day = ? THEN cost ELSE 0 END), 0),
+			COALESCE(SUM(cost), 0)
+		FROM api_key_daily_cost
+		WHERE api_key_id = ? AND day >= ? AND day <= ?
+	`, today, keyID, quotaMonthStart(now), today).Scan(&usage.TodayCost, &usage.MonthCost)
+	return usage, err
+}
+
+// getAllQuotaUsage 获取所有API密钥当日及当月的用量
+func getAllQuotaUsage(now time.Time) (map[int64]models.APIKeyQuotaUsage, error) {
+	today := quotaDay(now)
+	rows, err := db.Query(`
+		SELECT api_key_id,
+			SUM(CASE WHEN day = ? THEN requests ELSE 0 END),
+			SUM(CASE WHEN day = ? THEN ai_requests ELSE 0 END),
+			SUM(requests)
+		FROM api_key_daily_usage
+		WHERE day >= ? AND day <= ?
+		GROUP BY api_key_id
+	`, today, today, quotaMonthStart(now), today)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := make(map[int64]models.APIKeyQuotaUsage)
+	for rows.Next() {
+		var keyID int64
+		var u models.APIKeyQuotaUsage
+		if err := rows.Scan(&keyID, &u.TodayRequests, &u.TodayAIRequests, &u.MonthRequests); err != nil {
+			return nil, err
+		}
+		usage[keyID] = u
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	costRows, err := db.Query(`
+		SELECT api_key_id,
+			SUM(CASE WHEN day = ? THEN cost ELSE 0 END),
+			SUM(cost)
+		FROM api_key_daily_cost
+		WHERE day >= ? AND day <= ?
+		GROUP BY api_key_id
+	`, today, quotaMonthStart(now), today)
+	if err != nil {
+		return nil, err
+	}
+	defer costRows.Close()
+
+	for costRows.Next() {
+		var keyID int64
+		var todayCost, monthCost float64
+		if err := costRows.Scan(&keyID, &todayCost, &monthCost); err != nil {
+			return nil, err
+		}
+		u := usage[keyID]
+		u.TodayCost, u.MonthCost = todayCost, monthCost
+		usage[keyID] = u
+	}
+	return usage, costRows.Err()
+}
+
+// IncrementQuotaUsage 记录API密钥的一次请求
+func IncrementQuotaUsage(keyID int64, now time.Time) error {
+	return addQuotaUsage(keyID, now, "requests", 1)
+}
+
+// AddQuotaAIRequests 增加API密钥当日未命中缓存的AI调用次数，n 为负数时归还预留的次数
+func AddQuotaAIRequests(keyID int64, now time.Time, n int64) error {
+	return addQuotaUsage(keyID, now, "ai_requests", n)
+}
+
+// AddQuotaRequests 增加API密钥当日的调用次数，用于批量查询中的每道题目
+func AddQuotaRequests(keyID int64, now time.Time, n int64) error {
+	return addQuotaUsage(keyID, now, "requests", n)
+}
+
+func addQuotaUsage(keyID int64, now time.Time, column string, n int64) error {
+	var query string
+	if dbType == "sqlite" {
+		query = "INSERT INTO api_key_daily_usage (api_key_id, day, " + column + ") VALUES (?, ?, ?) " +
+			"ON CONFLICT (api_key_id, day) DO UPDATE SET " + column + " = " + column + " + ?"
+	} else {
+		query = "INSERT INTO api_key_daily_usage (api_key_id, day, " + column + ") VALUES (?, ?, ?) " +
+			"ON DUPLICATE KEY UPDATE " + column + " = " + column + " + ?"
+	}
+	_, err := db.Exec(query, keyID, quotaDay(now), n, n)
+	return err
+}
+
+// UpdateAPIKeyLimits 修改API密钥的调用限制
+func UpdateAPIKeyLimits(id int64, limits models.APIKeyLimits) error {
+	result, err := db.Exec(`
+		UPDATE api_keys
+		SET rate_per_second = ?, rate_per_minute = ?, daily_quota = ?, monthly_quota = ?, daily_ai_quota = ?,
+			daily_cost_cap = ?, monthly_cost_cap = ?
+		WHERE id = ?
+	`, limits.RatePerSecond, limits.RatePerMinute, limits.DailyQuota, limits.MonthlyQuota, limits.DailyAIQuota,
+		limits.DailyCostCap, limits.MonthlyCostCap, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return apiKeyExists(id)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"ai-ocs/internal/models"
+	"database/sql"
+	"strings"
+)
+
+// GetReviewQueue 分页获取审核队列，有争议的答案排在前面，其余按时间倒序
+func GetReviewQueue(filter QuestionFilter, limit, offset int) ([]*models.QuestionAnswer, int64, error) {
+	where, args := filter.where()
+
+	var total int64
+	if err := db.QueryRow("SELECT COUNT(*) FROM question_answer"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Query("SELECT "+questionColumns+" FROM question_answer"+where+
+		" ORDER BY CASE WHEN review_status = ? THEN 0 ELSE 1 END, id DESC LIMIT ? OFFSET ?",
+		append(args, models.ReviewDisputed, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var results []*models.QuestionAnswer
+	for rows.Next() {
+		qa, err := scanQuestion(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		results = append(results, qa)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return results, total, nil
+}
+
+// SetReviewStatus 批量设置题目的审核状态，返回实际修改的题目数。
+// 审核通过时置信度设为 confidence；与答案相关的待处理反馈随之视为已处理
+func SetReviewStatus(ids []int64, status string, confidence *float64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	updated, err := setReviewStatusTx(tx, ids, status, confidence)
+	if err != nil {
+		return 0, err
+	}
+	return updated, tx.Commit()
+}
+
+// setReviewStatusTx 在事务中设置题目的审核状态，含义同 SetReviewStatus
+func setReviewStatusTx(tx *sql.Tx, ids []int64, status string, confidence *float64) (int64, error) {
+	placeholders := "?" + strings.Repeat(", ?", len(ids)-1)
+	idArgs := make([]interface{}, len(ids))
+	for i, id := range ids {
+		idArgs[i] = id
+	}
+
+	query := "UPDATE question_answer SET review_status = ?"
+	args := []interface{}{status}
+	if status == models.ReviewVerified {
+		query += ", confidence = ?"
+		args = append(args, confidence)
+	}
+	result, err := tx.Exec(query+" WHERE id IN ("+placeholders+")", append(args, idArgs...)...)
+	if err != nil {
+		return 0, err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	// 审核通过或驳回后，用户反馈已经得到处理
+	if status == models.ReviewVerified || status == models.ReviewRejected {
+		_, err = tx.Exec("UPDATE answer_feedback SET resolved = TRUE WHERE resolved = FALSE AND question_id IN ("+placeholders+")", idArgs...)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return updated, nil
+}
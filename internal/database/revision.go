@@ -0,0 +1,166 @@
+package database
+
+import (
+	"ai-ocs/internal/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// reviewStatusFor 新答案的审核状态：管理员填写的答案视为已审核，其他来源的答案需要审核
+func reviewStatusFor(source models.AnswerSource) string {
+	if source.Source == models.AnswerSourceAdmin {
+		return models.ReviewVerified
+	}
+	return models.ReviewUnverified
+}
+
+// updateAnswerTx 在事务中更新答案并记录历史版本，答案未变化时不做任何操作。
+// 答案变化后之前的复核结果不再适用，会一并清除，审核状态按来源重新设置
+func updateAnswerTx(tx *sql.Tx, questionID int64, oldAnswer, newAnswer string, source models.AnswerSource) error {
+	if oldAnswer == newAnswer {
+		return nil
+	}
+
+	_, err := tx.Exec(`
+		UPDATE question_answer
+		SET answer = ?, source = ?, platform = ?, model = ?, confidence = ?, prompt_tokens = ?, completion_tokens = ?, updated_at = ?,
+			verified_at = NULL, verify_status = NULL, review_status = ?
+		WHERE id = ?
+	`, newAnswer, source.Source, source.Platform, source.Model, source.Confidence,
+		source.PromptTokens, source.CompletionTokens, time.Now(), reviewStatusFor(source), questionID)
+	if err != nil {
+		return err
+	}
+
+	return insertRevisionTx(tx, questionID, oldAnswer, newAnswer, source)
+}
+
+// insertRevisionTx 在事务中写入一条答案历史版本
+func insertRevisionTx(tx *sql.Tx, questionID int64, oldAnswer, newAnswer string, source models.AnswerSource) error {
+	_, err := tx.Exec(`
+		INSERT INTO answer_revisions (question_id, answer, previous_answer, source, platform, model, operator, reason)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, questionID, newAnswer, oldAnswer, source.Source, source.Platform, source.Model, source.Operator, source.Reason)
+	return err
+}
+
+// UpdateAnswer 按题目ID更新答案并记录历史版本
+func UpdateAnswer(questionID int64, answer string, source models.AnswerSource) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldAnswer string
+	err = tx.QueryRow("SELECT answer FROM question_answer WHERE id = ?", questionID).Scan(&oldAnswer)
+	if err != nil {
+		return err
+	}
+
+	if err := updateAnswerTx(tx, questionID, oldAnswer, answer, source); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateVerifiedAnswer 按题目ID更新答案并记录历史版本，同时将答案设为审核通过、置信度设为 confidence。
+// 用于管理员比较后选用的答案，答案和审核状态在同一事务中更新
+func UpdateVerifiedAnswer(questionID int64, answer string, source models.AnswerSource, confidence *float64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldAnswer string
+	err = tx.QueryRow("SELECT answer FROM question_answer WHERE id = ?", questionID).Scan(&oldAnswer)
+	if err != nil {
+		return err
+	}
+
+	if err := updateAnswerTx(tx, questionID, oldAnswer, answer, source); err != nil {
+		return err
+	}
+	if _, err := setReviewStatusTx(tx, []int64{questionID}, models.ReviewVerified, confidence); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetAnswerRevisions 获取题目的答案历史版本，最新的在前
+func GetAnswerRevisions(questionID int64) ([]*models.AnswerRevision, error) {
+	rows, err := db.Query(`
+		SELECT id, question_id, answer, COALESCE(previous_answer, ''), source, COALESCE(platform, ''),
+			COALESCE(model, ''), COALESCE(operator, ''), COALESCE(reason, ''), created_at
+		FROM answer_revisions
+		WHERE question_id = ?
+		ORDER BY id DESC
+	`, questionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []*models.AnswerRevision
+	for rows.Next() {
+		var r models.AnswerRevision
+		err := rows.Scan(&r.ID, &r.QuestionID, &r.Answer, &r.PreviousAnswer, &r.Source, &r.Platform,
+			&r.Model, &r.Operator, &r.Reason, &r.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, &r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// ErrNoPreviousAnswer 回滚到历史版本之前的答案时，该版本之前没有答案
+var ErrNoPreviousAnswer = errors.New("该版本之前没有答案")
+
+// RollbackAnswer 将答案回滚到指定历史版本，历史版本或题目不存在时返回 sql.ErrNoRows
+// restorePrevious 为 true 时回滚到该版本修改之前的答案，用于还原最早的原始答案
+func RollbackAnswer(questionID, revisionID int64, restorePrevious bool, operator, reason string) (string, error) {
+	var answer, previous string
+	err := db.QueryRow(`
+		SELECT answer, COALESCE(previous_answer, '') FROM answer_revisions WHERE id = ? AND question_id = ?
+	`, revisionID, questionID).Scan(&answer, &previous)
+	if err != nil {
+		return "", err
+	}
+
+	target := answer
+	if restorePrevious {
+		if previous == "" {
+			return "", ErrNoPreviousAnswer
+		}
+		target = previous
+	}
+
+	if reason == "" {
+		reason = fmt.Sprintf("回滚到版本 #%d", revisionID)
+		if restorePrevious {
+			reason = fmt.Sprintf("回滚到版本 #%d 之前的答案", revisionID)
+		}
+	}
+
+	err = UpdateAnswer(questionID, target, models.AnswerSource{
+		Source:   models.AnswerSourceAdmin,
+		Operator: operator,
+		Reason:   reason,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return target, nil
+}
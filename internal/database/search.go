@@ -0,0 +1,331 @@
+package database
+
+import (
+	"ai-ocs/internal/models"
+	"html"
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 全文检索模式
+const (
+	searchModeLike     = "like"     // 未启用全文索引，使用LIKE模糊匹配
+	searchModeFTS5     = "fts5"     // SQLite FTS5 trigram 索引
+	searchModeFulltext = "fulltext" // MySQL FULLTEXT ngram 索引
+)
+
+// searchMode 当前使用的检索模式
+var searchMode = searchModeLike
+
+// minTermLen 各检索模式下单个关键词的最小长度（字符数），更短的关键词回退到LIKE
+var minTermLen = map[string]int{
+	searchModeFTS5:     3,
+	searchModeFulltext: 2,
+}
+
+// ftsTriggers 保持FTS5索引与题库同步的触发器
+var ftsTriggers = []string{"question_fts_ai", "question_fts_ad", "question_fts_au"}
+
+// snippetRadius 摘要中关键词前后保留的字符数
+const snippetRadius = 30
+
+// initFullText 创建全文索引，失败时回退到LIKE检索
+func initFullText() {
+	var err error
+	if dbType == "sqlite" {
+		var fts5 bool
+		if err = db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5); err == nil && !fts5 {
+			// 数据库可能由启用FTS5的版本创建过，删除同步触发器以免写入题目失败
+			for _, name := range ftsTriggers {
+				db.Exec("DROP TRIGGER IF EXISTS " + name)
+			}
+			log.Println("当前SQLite未启用FTS5，题目搜索将使用LIKE匹配（编译时加上 -tags sqlite_fts5 可启用全文检索）")
+			return
+		}
+		if err == nil {
+			err = initSQLiteFTS()
+		}
+		if err == nil {
+			searchMode = searchModeFTS5
+		}
+	} else {
+		err = initMySQLFullText()
+		if err == nil {
+			searchMode = searchModeFulltext
+		}
+	}
+
+	if err != nil {
+		log.Printf("创建全文索引失败，题目搜索将使用LIKE匹配: %v", err)
+		return
+	}
+	log.Printf("题目全文检索已启用（%s）", searchMode)
+}
+
+// initSQLiteFTS 创建FTS5外部内容表及同步触发器
+func initSQLiteFTS() error {
+	// 索引表不存在或触发器缺失（曾被未启用FTS5的版本打开）时需要重建索引
+	var tables, triggers int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'question_fts'").Scan(&tables)
+	if err != nil {
+		return err
+	}
+	err = db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'question_fts_%'").Scan(&triggers)
+	if err != nil {
+		return err
+	}
+
+	stmts := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS question_fts USING fts5(
+			question, options, answer,
+			content='question_answer', content_rowid='id', tokenize='trigram'
+		);`,
+		`CREATE TRIGGER IF NOT EXISTS question_fts_ai AFTER INSERT ON question_answer BEGIN
+			INSERT INTO question_fts(rowid, question, options, answer) VALUES (new.id, new.question, new.options, new.answer);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS question_fts_ad AFTER DELETE ON question_answer BEGIN
+			INSERT INTO question_fts(question_fts, rowid, question, options, answer) VALUES ('delete', old.id, old.question, old.options, old.answer);
+		END;`,
+		// 只在题目、选项或答案变化时更新索引，命中次数等字段的更新不重写索引；
+		// 旧版本的触发器对任意字段的更新都会触发，启动时重新创建
+		`DROP TRIGGER IF EXISTS question_fts_au;`,
+		`CREATE TRIGGER question_fts_au AFTER UPDATE OF question, options, answer ON question_answer BEGIN
+			INSERT INTO question_fts(question_fts, rowid, question, options, answer) VALUES ('delete', old.id, old.question, old.options, old.answer);
+			INSERT INTO question_fts(rowid, question, options, answer) VALUES (new.id, new.question, new.options, new.answer);
+		END;`,
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	if tables == 0 || triggers < len(ftsTriggers) {
+		if _, err := db.Exec("INSERT INTO question_fts(question_fts) VALUES ('rebuild')"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// initMySQLFullText 为题目、选项和答案创建ngram全文索引
+func initMySQLFullText() error {
+	var exists int
+	err := db.QueryRow(`SELECT COUNT(*) FROM information_schema.statistics
+		WHERE table_schema = DATABASE() AND table_name = 'question_answer' AND index_name = 'ft_question_answer'`).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists > 0 {
+		return nil
+	}
+
+	log.Println("正在为题库创建全文索引，数据量较大时可能需要一些时间...")
+	_, err = db.Exec("ALTER TABLE question_answer ADD FULLTEXT INDEX ft_question_answer (question, options, answer) WITH PARSER ngram")
+	return err
+}
+
+// SearchQuestions 在题目、选项和答案中检索关键词，按相关度排序并返回高亮摘要
+func SearchQuestions(keyword string, limit, offset int) ([]*models.SearchResult, int64, error) {
+	terms := strings.Fields(keyword)
+	if len(terms) == 0 {
+		return nil, 0, nil
+	}
+
+	mode := searchMode
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) < minTermLen[mode] {
+			mode = searchModeLike
+			break
+		}
+	}
+
+	// whereArgs 用于筛选条件，scoreArgs 用于计算相关度的列
+	var where, score string
+	var whereArgs, scoreArgs []interface{}
+	from := "question_answer"
+	cols := questionColumns
+	order := "score DESC, id DESC"
+
+	switch mode {
+	case searchModeFTS5:
+		quoted := make([]string, len(terms))
+		for i, term := range terms {
+			quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+		}
+		from = "question_fts JOIN question_answer qa ON qa.id = question_fts.rowid"
+		cols = prefixedQuestionColumns("qa")
+		where = "question_fts MATCH ?"
+		whereArgs = []interface{}{strings.Join(quoted, " ")}
+		score = "-bm25(question_fts)"
+		order = "bm25(question_fts)"
+	case searchModeFulltext:
+		quoted := make([]string, len(terms))
+		for i, term := range terms {
+			quoted[i] = `+"` + strings.ReplaceAll(term, `"`, ``) + `"`
+		}
+		where = "MATCH(question, options, answer) AGAINST (? IN BOOLEAN MODE)"
+		whereArgs = []interface{}{strings.Join(quoted, " ")}
+		score = where
+		scoreArgs = whereArgs
+	default:
+		var conds []string
+		for _, term := range terms {
+			like := "%" + term + "%"
+			conds = append(conds, "(question LIKE ? OR options LIKE ? OR answer LIKE ?)")
+			whereArgs = append(whereArgs, like, like, like)
+		}
+		where = strings.Join(conds, " AND ")
+		// 题目本身命中的排在前面
+		score = "CASE WHEN question LIKE ? THEN 1 ELSE 0 END"
+		scoreArgs = []interface{}{"%" + terms[0] + "%"}
+	}
+
+	var total int64
+	if err := db.QueryRow("SELECT COUNT(*) FROM "+from+" WHERE "+where, whereArgs...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	querySQL := "SELECT " + cols + ", " + score + " AS score FROM " + from + " WHERE " + where + " ORDER BY " + order + " LIMIT ? OFFSET ?"
+	args := append(append(scoreArgs, whereArgs...), limit, offset)
+
+	rows, err := db.Query(querySQL, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var results []*models.SearchResult
+	for rows.Next() {
+		var score float64
+		qa, err := scanQuestion(rows, &score)
+		if err != nil {
+			return nil, 0, err
+		}
+		r := models.SearchResult{QuestionAnswer: *qa, Score: score}
+
+		r.HighlightedQuestion = highlight(r.Question, terms)
+		if !containsAny(r.Question, terms) {
+			// 题目未命中时，从选项或答案中截取摘要
+			if r.Options != nil && containsAny(*r.Options, terms) {
+				r.Snippet = "选项: " + snippet(*r.Options, terms)
+			} else if containsAny(r.Answer, terms) {
+				r.Snippet = "答案: " + snippet(r.Answer, terms)
+			}
+		}
+		results = append(results, &r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return results, total, nil
+}
+
+// containsAny 判断文本中是否包含任一关键词（忽略大小写）
+func containsAny(text string, terms []string) bool {
+	lower := string(lowerRunes(text))
+	for _, term := range terms {
+		if strings.Contains(lower, string(lowerRunes(term))) {
+			return true
+		}
+	}
+	return false
+}
+
+// snippet 截取第一个命中关键词附近的文本并高亮
+func snippet(text string, terms []string) string {
+	runes := []rune(text)
+	lower := lowerRunes(text)
+	pos := -1
+	for _, term := range terms {
+		if i := indexRunes(lower, lowerRunes(term)); i >= 0 && (pos < 0 || i < pos) {
+			pos = i
+		}
+	}
+	if pos < 0 {
+		pos = 0
+	}
+
+	start := pos - snippetRadius
+	if start < 0 {
+		start = 0
+	}
+	end := pos + snippetRadius*2
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	result := highlight(string(runes[start:end]), terms)
+	if start > 0 {
+		result = "…" + result
+	}
+	if end < len(runes) {
+		result += "…"
+	}
+	return result
+}
+
+// highlight 转义HTML后用<mark>标记所有命中的关键词
+func highlight(text string, terms []string) string {
+	runes := []rune(text)
+	lower := lowerRunes(text)
+	marked := make([]bool, len(runes))
+	for _, term := range terms {
+		t := lowerRunes(term)
+		if len(t) == 0 {
+			continue
+		}
+		for i := 0; i+len(t) <= len(lower); {
+			j := indexRunes(lower[i:], t)
+			if j < 0 {
+				break
+			}
+			for k := i + j; k < i+j+len(t); k++ {
+				marked[k] = true
+			}
+			i += j + len(t)
+		}
+	}
+
+	var b strings.Builder
+	for i, r := range runes {
+		if marked[i] && (i == 0 || !marked[i-1]) {
+			b.WriteString("<mark>")
+		}
+		b.WriteString(html.EscapeString(string(r)))
+		if marked[i] && (i == len(runes)-1 || !marked[i+1]) {
+			b.WriteString("</mark>")
+		}
+	}
+	return b.String()
+}
+
+// lowerRunes 逐字符转为小写，保证与原文本的字符位置一一对应
+func lowerRunes(s string) []rune {
+	runes := []rune(s)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+// indexRunes 返回子序列在rune切片中首次出现的位置
+func indexRunes(s, sub []rune) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		match := true
+		for j := range sub {
+			if s[i+j] != sub[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,216 @@
+package database
+
+import (
+	"ai-ocs/internal/models"
+	"strings"
+	"time"
+)
+
+// verifyRetryDelay 复核请求失败的答案在该时间内不再挑选，避免总是失败的答案挡住排在后面的答案
+const verifyRetryDelay = 6 * time.Hour
+
+// VerifyCriteria 挑选待复核答案的条件，含义见 models.VerifierConfig
+type VerifyCriteria struct {
+	MaxConfidence     float64
+	MinAgeDays        int
+	Models            []string
+	OnlyDisputed      bool
+	ReverifyAfterDays int
+}
+
+// where 构造挑选条件的SQL片段（包含 WHERE）及参数
+func (c VerifyCriteria) where(now time.Time) (string, []interface{}) {
+	conds := []string{"answer <> ''", "review_status <> ?", "COALESCE(confidence, 0) < ?"}
+	args := []interface{}{models.ReviewRejected, c.MaxConfidence}
+
+	if c.MinAgeDays > 0 {
+		conds = append(conds, "COALESCE(updated_at, created_at) < ?")
+		args = append(args, now.AddDate(0, 0, -c.MinAgeDays))
+	}
+	if len(c.Models) > 0 {
+		conds = append(conds, "model IN (?"+strings.Repeat(", ?", len(c.Models)-1)+")")
+		for _, m := range c.Models {
+			args = append(args, m)
+		}
+	}
+	if c.OnlyDisputed {
+		conds = append(conds, "review_status = ?")
+		args = append(args, models.ReviewDisputed)
+	}
+	if c.ReverifyAfterDays > 0 {
+		conds = append(conds, "(verified_at IS NULL OR verified_at < ?)")
+		args = append(args, now.AddDate(0, 0, -c.ReverifyAfterDays))
+	} else {
+		conds = append(conds, "verified_at IS NULL")
+	}
+
+	// 复核失败不更新 verified_at，按最近一次失败的复核记录跳过
+	conds = append(conds, `NOT EXISTS (SELECT 1 FROM answer_verifications v
+		WHERE v.question_id = question_answer.id AND v.status = ? AND v.created_at >= ?)`)
+	args = append(args, models.VerifyError, now.Add(-verifyRetryDelay))
+
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// ListVerifyCandidates 获取待复核的答案，从未复核过的优先
+func ListVerifyCandidates(criteria VerifyCriteria, limit int) ([]*models.QuestionAnswer, error) {
+	where, args := criteria.where(time.Now())
+	rows, err := db.Query("SELECT "+questionColumns+" FROM question_answer"+where+
+		" ORDER BY CASE WHEN verified_at IS NULL THEN 0 ELSE 1 END, verified_at, id LIMIT ?", append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*models.QuestionAnswer
+	for rows.Next() {
+		qa, err := scanQuestion(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, qa)
+	}
+	return results, rows.Err()
+}
+
+// CountVerifyCandidates 统计待复核的答案数
+func CountVerifyCandidates(criteria VerifyCriteria) (int64, error) {
+	where, args := criteria.where(time.Now())
+	var total int64
+	err := db.QueryRow("SELECT COUNT(*) FROM question_answer"+where, args...).Scan(&total)
+	return total, err
+}
+
+// RecordVerification 保存一次复核结果；复核成功时同时更新题目的复核状态，不一致的答案标记为有争议
+func RecordVerification(v *models.AnswerVerification) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if v.CreatedAt.IsZero() {
+		v.CreatedAt = time.Now()
+	}
+	result, err := tx.Exec(`
+		INSERT INTO answer_verifications (question_id, answer, verifier_answer, status, platform, model, prompt_tokens, completion_tokens, error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, v.QuestionID, v.Answer, v.VerifierAnswer, v.Status, v.Platform, v.Model, v.PromptTokens, v.CompletionTokens, v.Error, v.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if v.ID, err = result.LastInsertId(); err != nil {
+		return err
+	}
+
+	// 复核期间答案可能已被修改，只在答案未变化时更新复核状态。
+	// 模型生成的答案按复核结果设置置信度：一致为1，不一致为0；管理员确认过的答案保持原置信度
+	if v.Status != models.VerifyError {
+		confidence := 0.0
+		if v.Status == models.VerifyAgree {
+			confidence = 1
+		}
+		_, err = tx.Exec(`UPDATE question_answer SET verified_at = ?, verify_status = ?,
+			confidence = CASE WHEN source = ? OR review_status = ? THEN confidence ELSE ? END
+			WHERE id = ? AND answer = ?`,
+			v.CreatedAt, v.Status, models.AnswerSourceAdmin, models.ReviewVerified, confidence, v.QuestionID, v.Answer)
+		if err != nil {
+			return err
+		}
+	}
+	if v.Status == models.VerifyMismatch {
+		_, err = tx.Exec("UPDATE question_answer SET review_status = ? WHERE id = ? AND answer = ? AND review_status <> ?",
+			models.ReviewDisputed, v.QuestionID, v.Answer, models.ReviewRejected)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// VerificationTokensSince 统计指定时间之后复核消耗的token总数
+func VerificationTokensSince(since time.Time) (int64, error) {
+	var total int64
+	err := db.QueryRow("SELECT COALESCE(SUM(prompt_tokens + completion_tokens), 0) FROM answer_verifications WHERE created_at >= ?",
+		since).Scan(&total)
+	return total, err
+}
+
+// VerificationUsageSince 按平台和模型统计指定时间之后复核消耗的token
+func VerificationUsageSince(since time.Time) ([]*models.CostRollup, error) {
+	rows, err := db.Query(`
+		SELECT COALESCE(platform, ''), COALESCE(model, ''), COUNT(*),
+			COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0)
+		FROM answer_verifications
+		WHERE created_at >= ?
+		GROUP BY platform, model
+	`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []*models.CostRollup
+	for rows.Next() {
+		u := &models.CostRollup{}
+		if err := rows.Scan(&u.Platform, &u.Model, &u.Requests, &u.PromptTokens, &u.CompletionTokens); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// GetVerifications 分页获取复核记录，disputedOnly 为 true 时只返回题目仍有争议的记录
+func GetVerifications(status string, disputedOnly bool, limit, offset int) ([]*models.AnswerVerification, int64, error) {
+	var conds []string
+	var args []interface{}
+	if status != "" {
+		conds = append(conds, "v.status = ?")
+		args = append(args, status)
+	}
+	if disputedOnly {
+		conds = append(conds, "q.review_status = ?")
+		args = append(args, models.ReviewDisputed)
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	from := " FROM answer_verifications v LEFT JOIN question_answer q ON q.id = v.question_id"
+	var total int64
+	if err := db.QueryRow("SELECT COUNT(*)"+from+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Query(`
+		SELECT v.id, v.question_id, COALESCE(q.question, ''), COALESCE(q.answer, ''), v.answer, COALESCE(v.verifier_answer, ''),
+			v.status, COALESCE(v.platform, ''), COALESCE(v.model, ''), v.prompt_tokens, v.completion_tokens,
+			COALESCE(v.error, ''), COALESCE(q.review_status, ''), v.created_at`+from+where+`
+		ORDER BY v.id DESC
+		LIMIT ? OFFSET ?
+	`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var results []*models.AnswerVerification
+	for rows.Next() {
+		var v models.AnswerVerification
+		err := rows.Scan(&v.ID, &v.QuestionID, &v.Question, &v.CurrentAnswer, &v.Answer, &v.VerifierAnswer,
+			&v.Status, &v.Platform, &v.Model, &v.PromptTokens, &v.CompletionTokens,
+			&v.Error, &v.ReviewStatus, &v.CreatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		results = append(results, &v)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return results, total, nil
+}
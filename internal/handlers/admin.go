@@ -1,14 +1,20 @@
 package handlers
 
 import (
+	"ai-ocs/internal/ai"
 	"ai-ocs/internal/database"
 	"ai-ocs/internal/models"
 	"crypto/rand"
+	"database/sql"
 	"encoding/hex"
+	"fmt"
+	"io"
 	"golang.org/x/crypto/bcrypt"
 	"net/http"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -30,9 +36,28 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
-// APIKeyRequest API密钥请求结构
+// APIKeyRequest API密钥请求结构，scopes 为空时使用默认权限范围
 type APIKeyRequest struct {
-	Description string `json:"description" binding:"required"`
+	Description string     `json:"description" binding:"required"`
+	Scopes      []string   `json:"scopes"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	Enabled     *bool      `json:"enabled"`
+}
+
+// validScopes 校验并去重权限范围
+func (r *APIKeyRequest) validScopes() ([]string, error) {
+	seen := make(map[string]bool)
+	var scopes []string
+	for _, scope := range r.Scopes {
+		if !models.IsAPIScope(scope) {
+			return nil, fmt.Errorf("无效的权限范围: %s", scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
 }
 
 // Login 登录处理
@@ -114,8 +139,31 @@ func Logout(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "登出成功"})
 }
 
-// RequireAuth 中间件，验证是否已登录
+// adminOperatorKey 通过API密钥调用管理接口时，操作人在请求上下文中的键名
+const adminOperatorKey = "admin_operator"
+
+// RequireAuth 中间件，验证是否已登录；也可以在请求头（或开启 allow_query_key 时的 api-key 查询参数）中附带拥有 admin-api 权限的API密钥调用管理接口
 func RequireAuth(c *gin.Context) {
+	apiKey, queryRejected := requestAPIKey(c, false)
+	if queryRejected {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": errQueryKeyDisabled})
+		return
+	}
+	if apiKey != "" {
+		key, status, msg := authenticateAPIKey(apiKey)
+		if key == nil {
+			c.AbortWithStatusJSON(status, gin.H{"error": msg})
+			return
+		}
+		if !key.HasScope(models.APIScopeAdminAPI) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API密钥无权调用管理接口"})
+			return
+		}
+		c.Set(adminOperatorKey, "api-key:"+key.Description)
+		c.Next()
+		return
+	}
+
 	session, _ := store.Get(c.Request, "admin-session")
 	
 	if auth, ok := session.Values["authenticated"].(bool); !ok || !auth {
@@ -128,6 +176,16 @@ func RequireAuth(c *gin.Context) {
 	c.Next()
 }
 
+// currentAdmin 获取当前登录的管理员用户名，通过API密钥调用时返回密钥描述
+func currentAdmin(c *gin.Context) string {
+	if operator := c.GetString(adminOperatorKey); operator != "" {
+		return operator
+	}
+	session, _ := store.Get(c.Request, "admin-session")
+	username, _ := session.Values["username"].(string)
+	return username
+}
+
 // GetStats 获取统计数据
 func GetStats(c *gin.Context) {
 	db := database.GetDB()
@@ -154,8 +212,6 @@ func GetStats(c *gin.Context) {
 
 // GetQuestions 获取所有题目和答案（支持分页）
 func GetQuestions(c *gin.Context) {
-	db := database.GetDB()
-	
 	// 获取分页参数
 	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
 	if err != nil || page < 1 {
@@ -169,59 +225,16 @@ func GetQuestions(c *gin.Context) {
 	
 	offset := (page - 1) * limit
 	
-	// 查询总数
-	var total int64
-	err = db.QueryRow("SELECT COUNT(*) FROM question_answer").Scan(&total)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "无法获取题目总数: " + err.Error(),
-		})
-		return
-	}
-	
-	// 查询题目和答案（分页）
-	rows, err := db.Query("SELECT id, question, answer, options, type, created_at FROM question_answer ORDER BY created_at DESC LIMIT ? OFFSET ?", limit, offset)
+	results, total, err := database.ListQuestions(limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "无法获取题目数据: " + err.Error(),
 		})
 		return
 	}
-	defer rows.Close()
-	
-	type QuestionAnswer struct {
-		ID        int64     `json:"id"`
-		Question  string    `json:"question"`
-		Answer    string    `json:"answer"`
-		Options   *string   `json:"options,omitempty"`
-		Type      *string   `json:"type,omitempty"`
-		CreatedAt time.Time `json:"created_at"`
-	}
-	
-	var results []QuestionAnswer
-	
-	for rows.Next() {
-		var qa QuestionAnswer
-		var options, qtype *string
-		
-		err := rows.Scan(&qa.ID, &qa.Question, &qa.Answer, &options, &qtype, &qa.CreatedAt)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "扫描数据时出错: " + err.Error(),
-			})
-			return
-		}
-		
-		qa.Options = options
-		qa.Type = qtype
-		results = append(results, qa)
-	}
 	
-	if err = rows.Err(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "遍历数据时出错: " + err.Error(),
-		})
-		return
+	if results == nil {
+		results = []*models.QuestionAnswer{}
 	}
 	
 	c.JSON(http.StatusOK, gin.H{
@@ -232,7 +245,7 @@ func GetQuestions(c *gin.Context) {
 	})
 }
 
-// SearchQuestion 搜索特定题目
+// SearchQuestion 在题目、选项和答案中全文检索，按相关度排序
 func SearchQuestion(c *gin.Context) {
 	keyword := c.Query("keyword")
 	if keyword == "" {
@@ -242,8 +255,6 @@ func SearchQuestion(c *gin.Context) {
 		return
 	}
 	
-	db := database.GetDB()
-	
 	// 获取分页参数
 	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
 	if err != nil || page < 1 {
@@ -257,124 +268,295 @@ func SearchQuestion(c *gin.Context) {
 	
 	offset := (page - 1) * limit
 	
-	// 模糊搜索题目
-	rows, err := db.Query("SELECT id, question, answer, options, type, created_at FROM question_answer WHERE question LIKE ? ORDER BY created_at DESC LIMIT ? OFFSET ?", "%"+keyword+"%", limit, offset)
+	results, total, err := database.SearchQuestions(keyword, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "搜索时出错: " + err.Error(),
 		})
 		return
 	}
-	defer rows.Close()
 	
-	type QuestionAnswer struct {
-		ID        int64     `json:"id"`
-		Question  string    `json:"question"`
-		Answer    string    `json:"answer"`
-		Options   *string   `json:"options,omitempty"`
-		Type      *string   `json:"type,omitempty"`
-		CreatedAt time.Time `json:"created_at"`
+	if results == nil {
+		results = []*models.SearchResult{}
 	}
 	
-	var results []QuestionAnswer
-	
-	for rows.Next() {
-		var qa QuestionAnswer
-		var options, qtype *string
-		
-		err := rows.Scan(&qa.ID, &qa.Question, &qa.Answer, &options, &qtype, &qa.CreatedAt)
+	c.JSON(http.StatusOK, gin.H{
+		"data":  results,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// GetAPIKeys 获取所有API密钥，并返回可用于路由的平台和提示词模板
+func GetAPIKeys(config *models.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKeys, err := database.GetAllAPIKeys()
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "扫描数据时出错: " + err.Error(),
+				"error": "无法获取API密钥列表: " + err.Error(),
 			})
 			return
 		}
-		
-		qa.Options = options
-		qa.Type = qtype
-		results = append(results, qa)
-	}
-	
-	if err = rows.Err(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "遍历数据时出错: " + err.Error(),
+
+		templates := make([]string, 0, len(config.PromptTemplates))
+		for name := range config.PromptTemplates {
+			templates = append(templates, name)
+		}
+		sort.Strings(templates)
+
+		c.JSON(http.StatusOK, gin.H{
+			"data":             apiKeys,
+			"platforms":        ai.Platforms,
+			"default_platform": config.Platform,
+			"prompt_templates": templates,
 		})
+	}
+}
+
+// CreateAPIKey 创建新的API密钥
+func CreateAPIKey(c *gin.Context) {
+	var req APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
 		return
 	}
-	
-	// 获取搜索结果总数
-	var total int64
-	err = db.QueryRow("SELECT COUNT(*) FROM question_answer WHERE question LIKE ?", "%"+keyword+"%").Scan(&total)
+
+	scopes, err := req.validScopes()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	apiKey, err := database.CreateAPIKey(req.Description, scopes, req.ExpiresAt)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "无法获取搜索结果总数: " + err.Error(),
+			"error": "无法创建API密钥: " + err.Error(),
 		})
 		return
 	}
-	
+
 	c.JSON(http.StatusOK, gin.H{
-		"data":  results,
-		"total": total,
-		"page":  page,
-		"limit": limit,
+		"message": "API密钥创建成功",
+		"data":    apiKey,
 	})
 }
 
-// GetAPIKeys 获取所有API密钥
-func GetAPIKeys(c *gin.Context) {
-	apiKeys, err := database.GetAllAPIKeys()
+// DeleteAPIKey 删除API密钥
+func DeleteAPIKey(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的API密钥ID"})
+		return
+	}
+
+	err = database.DeleteAPIKey(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "无法获取API密钥列表: " + err.Error(),
+			"error": "无法删除API密钥: " + err.Error(),
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"data": apiKeys,
+		"message": "API密钥删除成功",
 	})
 }
 
-// CreateAPIKey 创建新的API密钥
-func CreateAPIKey(c *gin.Context) {
+// UpdateAPIKey 修改API密钥的描述、启用状态、过期时间和权限范围
+func UpdateAPIKey(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的API密钥ID"})
+		return
+	}
+
 	var req APIKeyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
 		return
 	}
 
-	apiKey, err := database.CreateAPIKey(req.Description)
+	scopes, err := req.validScopes()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(scopes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请至少选择一个权限范围"})
+		return
+	}
+
+	key := &models.APIKey{
+		ID:          id,
+		Description: req.Description,
+		Enabled:     req.Enabled == nil || *req.Enabled,
+		ExpiresAt:   req.ExpiresAt,
+		Scopes:      scopes,
+	}
+	err = database.UpdateAPIKey(key)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API密钥不存在"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "无法创建API密钥: " + err.Error(),
+			"error": "无法修改API密钥: " + err.Error(),
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "API密钥创建成功",
-		"data":    apiKey,
+		"message": "API密钥已更新",
 	})
 }
 
-// DeleteAPIKey 删除API密钥
-func DeleteAPIKey(c *gin.Context) {
+// defaultRotationGraceHours 轮换密钥时旧密钥默认的宽限时长
+const defaultRotationGraceHours = 24
+
+// RotateAPIKeyRequest 轮换API密钥的请求结构，grace_hours 为旧密钥继续有效的小时数，0表示立即失效
+type RotateAPIKeyRequest struct {
+	GraceHours *float64 `json:"grace_hours" binding:"omitempty,min=0,max=720"`
+}
+
+// RotateAPIKey 为API密钥生成新的密文，旧密文在宽限期内仍然有效
+func RotateAPIKey(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的API密钥ID"})
 		return
 	}
 
-	err = database.DeleteAPIKey(id)
+	// 请求体可以为空，此时使用默认宽限时长
+	var req RotateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	graceHours := float64(defaultRotationGraceHours)
+	if req.GraceHours != nil {
+		graceHours = *req.GraceHours
+	}
+
+	key, err := database.RotateAPIKey(id, time.Duration(graceHours*float64(time.Hour)))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API密钥不存在"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "无法删除API密钥: " + err.Error(),
+			"error": "无法轮换API密钥: " + err.Error(),
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "API密钥删除成功",
+		"message": "API密钥已轮换",
+		"data": gin.H{
+			"id":                  key.ID,
+			"api_key":             key.APIKey,
+			"prefix":              key.Prefix,
+			"previous_prefix":     key.PreviousPrefix,
+			"previous_expires_at": key.PreviousExpiresAt,
+		},
+	})
+}
+
+// maxProviderRoutes 每个API密钥最多配置的平台数
+const maxProviderRoutes = 5
+
+// UpdateAPIKeyRouting 修改API密钥未命中缓存时使用的平台、模型和提示词模板，providers 为空时使用全局配置
+func UpdateAPIKeyRouting(config *models.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的API密钥ID"})
+			return
+		}
+
+		var routing models.APIKeyRouting
+		if err := c.ShouldBindJSON(&routing); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+			return
+		}
+
+		if len(routing.Providers) > maxProviderRoutes {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("最多配置 %d 个平台", maxProviderRoutes)})
+			return
+		}
+		for i, r := range routing.Providers {
+			r.Platform = strings.TrimSpace(r.Platform)
+			r.Model = strings.TrimSpace(r.Model)
+			if !ai.IsPlatform(r.Platform) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的平台: " + r.Platform})
+				return
+			}
+			if strings.Contains(r.Model, ",") {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "模型名称不能包含逗号: " + r.Model})
+				return
+			}
+			routing.Providers[i] = r
+		}
+		if routing.PromptTemplate != "" {
+			tmpl, ok := config.PromptTemplates[routing.PromptTemplate]
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "提示词模板不存在: " + routing.PromptTemplate})
+				return
+			}
+			if _, err := ai.ParsePromptTemplate(tmpl); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "提示词模板有误: " + err.Error()})
+				return
+			}
+		}
+
+		err = database.UpdateAPIKeyRouting(id, routing)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API密钥不存在"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "无法修改路由: " + err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "路由已更新",
+			"data":    routing,
+		})
+	}
+}
+
+// UpdateAPIKeyLimits 修改API密钥的调用频率和配额限制，0表示不限制
+func UpdateAPIKeyLimits(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的API密钥ID"})
+		return
+	}
+
+	var limits models.APIKeyLimits
+	if err := c.ShouldBindJSON(&limits); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	err = database.UpdateAPIKeyLimits(id, limits)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API密钥不存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "无法修改调用限制: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "调用限制已更新",
+		"data":    limits,
 	})
 }
 
@@ -635,6 +817,12 @@ func AdminPage(c *gin.Context) {
             border-radius: 4px;
             font-size: 16px;
         }
+        select, input[type="date"] {
+            padding: 10px;
+            border: 1px solid #ddd;
+            border-radius: 4px;
+            font-size: 16px;
+        }
         button {
             background: #667eea;
             color: white;
@@ -671,6 +859,56 @@ func AdminPage(c *gin.Context) {
         .answer-text {
             color: #666;
         }
+        .snippet {
+            margin-top: 5px;
+            font-size: 0.9em;
+            color: #666;
+        }
+        tr.review-cursor td {
+            background-color: #eef6ff;
+        }
+        .revision-panel {
+            margin-top: 20px;
+            padding: 15px;
+            border: 1px solid #ddd;
+            border-radius: 4px;
+            background: #fafafa;
+        }
+        .revision-item {
+            padding: 10px;
+            border-bottom: 1px solid #eee;
+        }
+        .revision-actions {
+            display: flex;
+            gap: 10px;
+            margin-top: 5px;
+        }
+        .revision-actions button {
+            padding: 5px 10px;
+            font-size: 14px;
+        }
+        .candidate-grid {
+            display: flex;
+            gap: 15px;
+            margin-top: 15px;
+            overflow-x: auto;
+        }
+        .candidate {
+            flex: 1;
+            min-width: 220px;
+            padding: 10px;
+            border: 1px solid #ddd;
+            border-radius: 4px;
+            background: white;
+        }
+        .candidate .answer-text {
+            margin: 10px 0;
+            white-space: pre-wrap;
+        }
+        mark {
+            background: #fff3a3;
+            padding: 0 2px;
+        }
         .loading {
             text-align: center;
             padding: 20px;
@@ -785,6 +1023,26 @@ func AdminPage(c *gin.Context) {
             color: #007bff;
             font-weight: bold;
         }
+        .api-key-limits {
+            display: flex;
+            flex-wrap: wrap;
+            gap: 10px;
+            margin-top: 8px;
+        }
+        .api-key-limits label {
+            font-size: 0.9em;
+        }
+        .api-key-limits input[type="number"] {
+            width: 90px;
+        }
+        .api-key-status {
+            font-size: 0.8em;
+            font-weight: normal;
+            color: #28a745;
+        }
+        .api-key-status.disabled {
+            color: #dc3545;
+        }
         @media (max-width: 768px) {
             .stats-container {
                 flex-direction: column;
@@ -813,6 +1071,11 @@ func AdminPage(c *gin.Context) {
             <button class="tablinks active" onclick="openTab(event, 'dashboard')">仪表盘</button>
             <button class="tablinks" onclick="openTab(event, 'questions')">题目管理</button>
             <button class="tablinks" onclick="openTab(event, 'apikeys')">API密钥管理</button>
+            <button class="tablinks" onclick="openTab(event, 'querylogs')">查询日志</button>
+            <button class="tablinks" onclick="openTab(event, 'bank')">导入导出</button>
+            <button class="tablinks" onclick="openTab(event, 'review')">答案审核</button>
+            <button class="tablinks" onclick="openTab(event, 'verify')">答案复核</button>
+            <button class="tablinks" onclick="openTab(event, 'feedback')">用户反馈</button>
         </div>
 
         <div id="dashboard" class="tabcontent" style="display: block;">
@@ -826,16 +1089,82 @@ func AdminPage(c *gin.Context) {
                     <div class="stat-number" id="lastUpdated">-</div>
                     <div class="stat-label">最后更新</div>
                 </div>
+                <div class="stat-card">
+                    <div class="stat-number" id="budgetSpent">-</div>
+                    <div class="stat-label">今日AI费用</div>
+                </div>
             </div>
+
+            <h3>费用预算</h3>
+            <div id="budgetAlert" class="error hidden"></div>
+            <div id="budgetStatus" class="api-key-date"></div>
+            <button id="budgetTripButton" class="btn btn-danger" onclick="setBudget('trip')">立即熔断</button>
+            <button id="budgetResumeButton" class="btn hidden" onclick="setBudget('resume')">恢复AI调用</button>
+
+            <h3>AI平台状态</h3>
+            <table>
+                <thead>
+                    <tr>
+                        <th>平台 / 模型</th>
+                        <th>状态</th>
+                        <th>最近调用</th>
+                        <th>错误率</th>
+                        <th>平均 / P95延迟</th>
+                        <th>最近错误</th>
+                        <th>操作</th>
+                    </tr>
+                </thead>
+                <tbody id="providersBody"></tbody>
+            </table>
         </div>
 
         <div id="questions" class="tabcontent">
             <h2>题目查询</h2>
             <div class="search-container">
                 <div class="search-box">
-                    <input type="text" id="searchKeyword" placeholder="输入关键词搜索题目...">
+                    <input type="text" id="searchKeyword" placeholder="输入关键词搜索题目、选项或答案...">
                     <button onclick="searchQuestions()">搜索</button>
                     <button onclick="loadAllQuestions()">显示全部</button>
+                    <button onclick="toggleSection('questionForm')">新增题目</button>
+                    <button class="btn-danger" onclick="toggleSection('bulkDeleteForm')">批量删除</button>
+                </div>
+            </div>
+
+            <div id="questionForm" class="revision-panel hidden">
+                <h3>新增题目</h3>
+                <div class="form-group">
+                    <label for="newQuestion">题目</label>
+                    <textarea id="newQuestion" rows="2"></textarea>
+                </div>
+                <div class="form-group">
+                    <label for="newOptions">选项（可选）</label>
+                    <textarea id="newOptions" rows="3"></textarea>
+                </div>
+                <div class="form-group">
+                    <label for="newType">题型（可选）</label>
+                    <input type="text" id="newType">
+                </div>
+                <div class="form-group">
+                    <label for="newAnswer">答案</label>
+                    <textarea id="newAnswer" rows="2"></textarea>
+                </div>
+                <button onclick="createQuestion()">保存</button>
+            </div>
+
+            <div id="bulkDeleteForm" class="revision-panel hidden">
+                <h3>按条件批量删除</h3>
+                <div class="search-box">
+                    <input type="text" id="deleteKeyword" placeholder="关键词">
+                    <input type="text" id="deleteType" placeholder="题型">
+                    <select id="deleteSource">
+                        <option value="">全部来源</option>
+                        <option value="model">AI生成</option>
+                        <option value="admin">管理员修改</option>
+                        <option value="import">导入</option>
+                    </select>
+                    <input type="date" id="deleteFrom">
+                    <input type="date" id="deleteTo">
+                    <button class="btn-danger" onclick="bulkDeleteQuestions()">删除</button>
                 </div>
             </div>
 
@@ -848,7 +1177,9 @@ func AdminPage(c *gin.Context) {
                         <th>ID</th>
                         <th>题目</th>
                         <th>答案</th>
+                        <th>来源</th>
                         <th>创建时间</th>
+                        <th>操作</th>
                     </tr>
                 </thead>
                 <tbody id="questionsBody">
@@ -859,6 +1190,25 @@ func AdminPage(c *gin.Context) {
             <div class="pagination" id="pagination">
                 <!-- 分页控件将通过JavaScript动态加载 -->
             </div>
+
+            <div id="regeneratePanel" class="revision-panel hidden">
+                <h3>重新生成答案 <span id="regenerateTitle"></span></h3>
+                <div id="regeneratePlatforms" class="form-group"></div>
+                <div class="revision-actions">
+                    <button onclick="regenerateAnswer()">生成</button>
+                    <button onclick="closeRegenerate()">关闭</button>
+                </div>
+                <div id="regenerateLoading" class="loading hidden">正在请求各平台，请稍候...</div>
+                <div id="regenerateError" class="error hidden"></div>
+                <div id="regenerateResult" class="candidate-grid"></div>
+            </div>
+
+            <div id="revisionPanel" class="revision-panel hidden">
+                <h3>答案历史 <span id="revisionTitle"></span></h3>
+                <div id="revisionError" class="error hidden"></div>
+                <div id="revisionList"></div>
+                <button onclick="closeRevisions()">关闭</button>
+            </div>
         </div>
 
         <div id="apikeys" class="tabcontent">
@@ -867,25 +1217,325 @@ func AdminPage(c *gin.Context) {
                 <label for="apiKeyDescription">密钥描述</label>
                 <input type="text" id="apiKeyDescription" placeholder="请输入密钥描述">
             </div>
+            <div class="form-group">
+                <label>权限范围</label>
+                <div id="apiKeyScopes" class="api-key-limits"></div>
+            </div>
+            <div class="form-group">
+                <label for="apiKeyExpiresAt">过期时间（留空表示永不过期）</label>
+                <input type="datetime-local" id="apiKeyExpiresAt">
+            </div>
             <button onclick="createAPIKey()">创建新的API密钥</button>
             
+            <div id="apiKeyCreated" class="api-key-item hidden">
+                <div>
+                    <div class="api-key-description">新的API密钥（只显示这一次，请立即复制保存）</div>
+                    <div class="api-key-value" id="apiKeyCreatedValue"></div>
+                </div>
+                <div>
+                    <button class="btn" onclick="document.getElementById('apiKeyCreated').classList.add('hidden')">我已保存</button>
+                </div>
+            </div>
+            
             <div id="apiKeyLoading" class="loading hidden">加载中...</div>
             <div id="apiKeyError" class="error hidden"></div>
             
             <div id="apiKeysList">
                 <!-- API密钥列表将通过JavaScript动态加载 -->
             </div>
-        </div>
-    </div>
-
-    <script>
-        let currentPage = 1;
-        let currentKeyword = '';
-        const limit = 50;
 
-        // 页面加载完成后获取统计数据
+            <h3>AI调用费用</h3>
+            <div class="search-container">
+                <div class="search-box">
+                    <input type="date" id="costFrom">
+                    <input type="date" id="costTo">
+                    <button onclick="loadCostReport()">统计</button>
+                </div>
+            </div>
+            <div id="costError" class="error hidden"></div>
+            <div id="costSummary" class="api-key-date"></div>
+            <table>
+                <thead>
+                    <tr>
+                        <th>API密钥</th>
+                        <th>AI调用</th>
+                        <th>输入Token</th>
+                        <th>输出Token</th>
+                        <th>费用</th>
+                    </tr>
+                </thead>
+                <tbody id="costByKeyBody"></tbody>
+            </table>
+            <table>
+                <thead>
+                    <tr>
+                        <th>平台 / 模型</th>
+                        <th>AI调用</th>
+                        <th>输入Token</th>
+                        <th>输出Token</th>
+                        <th>费用</th>
+                    </tr>
+                </thead>
+                <tbody id="costByProviderBody"></tbody>
+            </table>
+        </div>
+
+        <div id="querylogs" class="tabcontent">
+            <h2>查询日志</h2>
+            <div class="search-container">
+                <div class="search-box">
+                    <input type="text" id="logKeyword" placeholder="题目关键词">
+                    <select id="logCacheHit">
+                        <option value="">全部来源</option>
+                        <option value="true">缓存命中</option>
+                        <option value="false">AI生成</option>
+                    </select>
+                    <select id="logStatus">
+                        <option value="">全部状态</option>
+                        <option value="ok">成功</option>
+                        <option value="pending">待审核</option>
+                        <option value="miss">未命中</option>
+                        <option value="error">失败</option>
+                    </select>
+                    <input type="date" id="logFrom">
+                    <input type="date" id="logTo">
+                    <button onclick="loadQueryLogs(1)">筛选</button>
+                </div>
+            </div>
+
+            <div id="logLoading" class="loading hidden">加载中...</div>
+            <div id="logError" class="error hidden"></div>
+
+            <table>
+                <thead>
+                    <tr>
+                        <th>时间</th>
+                        <th>API密钥</th>
+                        <th>题目</th>
+                        <th>来源</th>
+                        <th>耗时</th>
+                        <th>Token</th>
+                        <th>状态</th>
+                    </tr>
+                </thead>
+                <tbody id="queryLogsBody">
+                    <!-- 日志将通过JavaScript动态加载 -->
+                </tbody>
+            </table>
+
+            <div class="pagination" id="logPagination">
+                <!-- 分页控件将通过JavaScript动态加载 -->
+            </div>
+        </div>
+
+        <div id="bank" class="tabcontent">
+            <h2>导入题库</h2>
+            <div class="form-group">
+                <label for="importFile">题库文件（CSV、JSON Lines 或 XLSX）</label>
+                <input type="file" id="importFile" accept=".csv,.jsonl,.ndjson,.json,.xlsx">
+            </div>
+            <div class="form-group">
+                <label>列映射（留空时自动识别 题目/选项/题型/答案 等常用列名）</label>
+                <div class="search-box">
+                    <input type="text" id="mapQuestion" placeholder="题目列">
+                    <input type="text" id="mapOptions" placeholder="选项列">
+                    <input type="text" id="mapType" placeholder="题型列">
+                    <input type="text" id="mapAnswer" placeholder="答案列">
+                    <input type="text" id="importSheet" placeholder="工作表（XLSX）">
+                </div>
+            </div>
+            <div class="form-group">
+                <label for="importOnDuplicate">题库中已存在相同题目时</label>
+                <select id="importOnDuplicate">
+                    <option value="skip">跳过</option>
+                    <option value="update">用导入的答案覆盖</option>
+                </select>
+            </div>
+            <div class="search-box">
+                <button onclick="importQuestions(true)">预览</button>
+                <button onclick="importQuestions(false)">导入</button>
+            </div>
+
+            <div id="importLoading" class="loading hidden">处理中...</div>
+            <div id="importError" class="error hidden"></div>
+            <div id="importReport"></div>
+
+            <h2>导出题库</h2>
+            <div class="search-box">
+                <input type="text" id="exportKeyword" placeholder="关键词">
+                <input type="text" id="exportType" placeholder="题型">
+                <select id="exportSource">
+                    <option value="">全部来源</option>
+                    <option value="model">AI生成</option>
+                    <option value="admin">管理员修改</option>
+                    <option value="import">导入</option>
+                </select>
+                <input type="date" id="exportFrom">
+                <input type="date" id="exportTo">
+                <select id="exportFormat">
+                    <option value="csv">CSV</option>
+                    <option value="jsonl">JSON Lines</option>
+                    <option value="xlsx">Excel (XLSX)</option>
+                    <option value="md">Markdown</option>
+                </select>
+                <button onclick="exportQuestions()">导出</button>
+            </div>
+        </div>
+        <div id="review" class="tabcontent">
+            <h2>答案审核</h2>
+            <div class="search-box">
+                <select id="reviewStatus">
+                    <option value="disputed,unverified">待审核（有争议和未审核）</option>
+                    <option value="disputed">有争议</option>
+                    <option value="unverified">未审核</option>
+                    <option value="verified">已通过</option>
+                    <option value="rejected">已驳回</option>
+                </select>
+                <input type="text" id="reviewKeyword" placeholder="关键词">
+                <input type="text" id="reviewType" placeholder="题型">
+                <button onclick="loadReview(1)">筛选</button>
+            </div>
+            <div class="search-box">
+                <button onclick="reviewSelected('verified')">通过 (A)</button>
+                <button class="btn-danger" onclick="reviewSelected('rejected')">驳回 (R)</button>
+                <button onclick="reviewSelected('disputed')">标记争议 (D)</button>
+                <button onclick="reviewSelected('unverified')">撤回审核 (U)</button>
+                <span class="snippet" id="reviewSelection"></span>
+            </div>
+            <p class="snippet">快捷键：J/K 上下移动，X 选中当前题目，Shift+X 全选，E 编辑答案；A/R/D/U 作用于选中的题目，未选中时作用于当前题目</p>
+
+            <div id="reviewError" class="error hidden"></div>
+
+            <table>
+                <thead>
+                    <tr>
+                        <th><input type="checkbox" id="reviewSelectAll" onchange="selectAllReview(this.checked)"></th>
+                        <th>ID</th>
+                        <th>题目</th>
+                        <th>答案</th>
+                        <th>状态</th>
+                        <th>操作</th>
+                    </tr>
+                </thead>
+                <tbody id="reviewBody">
+                    <!-- 审核队列将通过JavaScript动态加载 -->
+                </tbody>
+            </table>
+
+            <div class="pagination" id="reviewPagination">
+                <!-- 分页控件将通过JavaScript动态加载 -->
+            </div>
+        </div>
+
+        <div id="verify" class="tabcontent">
+            <h2>后台复核</h2>
+            <div class="stats-container">
+                <div class="stat-card">
+                    <div class="stat-number" id="verifierState">-</div>
+                    <div class="stat-label" id="verifierModel">复核模型</div>
+                </div>
+                <div class="stat-card">
+                    <div class="stat-number" id="verifierPending">0</div>
+                    <div class="stat-label">待复核</div>
+                </div>
+                <div class="stat-card">
+                    <div class="stat-number" id="verifierTokens">0</div>
+                    <div class="stat-label">今日Token / 预算</div>
+                </div>
+                <div class="stat-card">
+                    <div class="stat-number" id="verifierCounts">0 / 0 / 0</div>
+                    <div class="stat-label">一致 / 不一致 / 失败（本次运行）</div>
+                </div>
+            </div>
+            <p class="snippet" id="verifierTimes"></p>
+            <div class="search-box">
+                <button onclick="verifierAction('run')">立即复核</button>
+                <button onclick="verifierAction('pause')">暂停</button>
+                <button onclick="verifierAction('resume')">恢复</button>
+                <button onclick="loadVerifier()">刷新</button>
+            </div>
+
+            <h2>复核记录</h2>
+            <div class="search-box">
+                <select id="verifyStatus">
+                    <option value="mismatch">不一致</option>
+                    <option value="">全部结果</option>
+                    <option value="agree">一致</option>
+                    <option value="error">失败</option>
+                </select>
+                <select id="verifyDisputed">
+                    <option value="1">仅待处理</option>
+                    <option value="">包括已处理</option>
+                </select>
+                <button onclick="loadVerifications(1)">筛选</button>
+            </div>
+
+            <div id="verifyError" class="error hidden"></div>
+
+            <table>
+                <thead>
+                    <tr>
+                        <th>时间</th>
+                        <th>题目</th>
+                        <th>现有答案</th>
+                        <th>复核答案</th>
+                        <th>结果</th>
+                        <th>操作</th>
+                    </tr>
+                </thead>
+                <tbody id="verificationsBody">
+                    <!-- 复核记录将通过JavaScript动态加载 -->
+                </tbody>
+            </table>
+
+            <div class="pagination" id="verifyPagination">
+                <!-- 分页控件将通过JavaScript动态加载 -->
+            </div>
+        </div>
+        <div id="feedback" class="tabcontent">
+            <h2>用户反馈</h2>
+            <div class="search-box">
+                <select id="feedbackResolved">
+                    <option value="">待处理</option>
+                    <option value="1">已处理</option>
+                </select>
+                <button onclick="loadFeedback(1)">筛选</button>
+            </div>
+
+            <div id="feedbackError" class="error hidden"></div>
+
+            <table>
+                <thead>
+                    <tr>
+                        <th>题目</th>
+                        <th>当前答案</th>
+                        <th>正确 / 错误</th>
+                        <th>建议答案</th>
+                        <th>最近反馈</th>
+                        <th>操作</th>
+                    </tr>
+                </thead>
+                <tbody id="feedbackBody">
+                    <!-- 反馈将通过JavaScript动态加载 -->
+                </tbody>
+            </table>
+
+            <div class="pagination" id="feedbackPagination">
+                <!-- 分页控件将通过JavaScript动态加载 -->
+            </div>
+        </div>
+    </div>
+
+    <script>
+        let currentPage = 1;
+        let currentKeyword = '';
+        const limit = 50;
+
+        // 页面加载完成后获取统计数据
         document.addEventListener('DOMContentLoaded', function() {
             loadStats();
+            loadBudget();
+            loadProviders();
             loadAllQuestions();
             loadAPIKeys();
         });
@@ -908,6 +1558,105 @@ func AdminPage(c *gin.Context) {
                 });
         }
 
+        // 全局费用预算状态，熔断时在仪表盘显示提醒
+        function loadBudget() {
+            fetch('/admin/budget')
+                .then(response => response.json())
+                .then(data => renderBudget(data.data))
+                .catch(error => {
+                    console.error('获取费用预算失败:', error);
+                });
+        }
+
+        function renderBudget(s) {
+            const action = s.action === 'fallback' ? '改用 ' + s.fallback_platform + '/' + s.fallback_model : '只返回题库中已有的答案';
+            document.getElementById('budgetSpent').textContent = formatCost(s.spent_today);
+            document.getElementById('budgetStatus').textContent = s.enabled
+                ? '每日预算 ' + formatCost(s.daily_limit) + ' · 已用 ' + formatCost(s.spent_today) +
+                  ' · 达到 ' + Math.round(s.warn_ratio * 100) + '% 时预警，达到上限后' + action +
+                  (s.overridden ? ' · 今日已手动恢复，不再自动熔断' : '')
+                : '未设置每日预算，可以手动熔断（熔断后' + action + '）';
+
+            const alertBox = document.getElementById('budgetAlert');
+            if (s.tripped) {
+                alertBox.textContent = (s.manual ? '已手动熔断' : '今日AI费用已达到预算') + '，' + action +
+                    (s.tripped_at ? '（' + new Date(s.tripped_at).toLocaleString('zh-CN') + ' 起）' : '');
+                alertBox.classList.remove('hidden');
+            } else if (s.enabled && s.warned) {
+                alertBox.textContent = '今日AI费用已达到预算的 ' + Math.round(s.warn_ratio * 100) + '%';
+                alertBox.classList.remove('hidden');
+            } else {
+                alertBox.classList.add('hidden');
+            }
+            document.getElementById('budgetTripButton').classList.toggle('hidden', s.tripped);
+            document.getElementById('budgetResumeButton').classList.toggle('hidden', !s.tripped);
+        }
+
+        function setBudget(op) {
+            if (op === 'trip' && !confirm('熔断后将停止调用AI，直到手动恢复。确定吗？')) {
+                return;
+            }
+            sendQuestion('POST', '/admin/budget/' + op)
+                .then(data => renderBudget(data.data))
+                .catch(err => alert('操作失败: ' + err.message));
+        }
+
+        const circuitStateNames = {
+            'closed': '正常',
+            'open': '熔断中',
+            'half-open': '试探中'
+        };
+
+        // AI平台的熔断状态和最近调用的错误率、延迟
+        function loadProviders() {
+            fetch('/admin/providers')
+                .then(response => response.json())
+                .then(data => renderProviders(data.data || [], data.default_platform))
+                .catch(error => {
+                    console.error('获取平台状态失败:', error);
+                });
+        }
+
+        let loadedProviders = [];
+
+        function renderProviders(providers, defaultPlatform) {
+            loadedProviders = providers;
+            const tbody = document.getElementById('providersBody');
+            tbody.innerHTML = '';
+            if (providers.length === 0) {
+                const cell = tbody.insertRow().insertCell(0);
+                cell.colSpan = 7;
+                cell.textContent = '没有已配置的平台';
+                cell.style.textAlign = 'center';
+                return;
+            }
+            providers.forEach((h, i) => {
+                const row = tbody.insertRow();
+                row.insertCell(0).textContent = h.platform + (h.model ? ' / ' + h.model : '') + (h.platform === defaultPlatform ? '（默认平台）' : '');
+                let state = circuitStateNames[h.state] || h.state;
+                if (h.state === 'open' && h.retry_at) {
+                    state += '，' + new Date(h.retry_at).toLocaleTimeString('zh-CN') + ' 后试探';
+                }
+                row.insertCell(1).textContent = state;
+                row.insertCell(2).textContent = h.requests + ' 次' + (h.skipped ? '，跳过 ' + h.skipped + ' 次' : '');
+                row.insertCell(3).textContent = h.requests ? Math.round(h.error_rate * 100) + '%' : '-';
+                row.insertCell(4).textContent = h.requests ? h.avg_latency_ms + ' / ' + h.p95_latency_ms + ' ms' : '-';
+                row.insertCell(5).innerHTML = h.last_error
+                    ? '<div class="question-text">' + escapeHtml(h.last_error) + '</div><div class="api-key-date">' + new Date(h.last_error_at).toLocaleString('zh-CN') + '</div>'
+                    : '-';
+                row.insertCell(6).innerHTML = h.state !== 'closed'
+                    ? '<button class="btn" onclick="resetProvider(' + i + ')">恢复</button>'
+                    : '';
+            });
+        }
+
+        function resetProvider(i) {
+            const h = loadedProviders[i];
+            sendQuestion('POST', '/admin/providers/reset', { platform: h.platform, model: h.model })
+                .then(() => loadProviders())
+                .catch(err => alert('操作失败: ' + err.message));
+        }
+
         // 加载所有题目
         function loadAllQuestions(page = 1) {
             currentKeyword = '';
@@ -962,7 +1711,7 @@ func AdminPage(c *gin.Context) {
             if (questions.length === 0) {
                 const row = tbody.insertRow();
                 const cell = row.insertCell(0);
-                cell.colSpan = 4;
+                cell.colSpan = 6;
                 cell.textContent = '未找到相关题目';
                 cell.style.textAlign = 'center';
                 cell.style.padding = '20px';
@@ -976,7 +1725,11 @@ func AdminPage(c *gin.Context) {
                 idCell.textContent = question.id;
                 
                 const questionCell = row.insertCell(1);
-                questionCell.innerHTML = '<div class="question-text">' + escapeHtml(question.question) + '</div>';
+                // 搜索结果中的高亮字段已由服务端转义
+                questionCell.innerHTML = '<div class="question-text">' + (question.highlighted_question || escapeHtml(question.question)) + '</div>';
+                if (question.snippet) {
+                    questionCell.innerHTML += '<div class="snippet">' + question.snippet + '</div>';
+                }
                 if (question.options) {
                     questionCell.innerHTML += '<div style="margin-top: 5px; font-size: 0.9em; color: #666;">选项: ' + escapeHtml(question.options) + '</div>';
                 }
@@ -987,118 +1740,493 @@ func AdminPage(c *gin.Context) {
                 const answerCell = row.insertCell(2);
                 answerCell.innerHTML = '<div class="answer-text">' + escapeHtml(question.answer) + '</div>';
                 
-                const dateCell = row.insertCell(3);
+                const sourceCell = row.insertCell(3);
+                sourceCell.innerHTML = renderAnswerSource(question);
+
+                const dateCell = row.insertCell(4);
                 if (question.created_at) {
                     const date = new Date(question.created_at);
                     dateCell.textContent = date.toLocaleString('zh-CN');
                 } else {
                     dateCell.textContent = '-';
                 }
+                if (question.updated_at && question.updated_at !== question.created_at) {
+                    dateCell.innerHTML += '<div class="api-key-date">更新: ' + new Date(question.updated_at).toLocaleString('zh-CN') + '</div>';
+                }
+
+                const actionCell = row.insertCell(5);
+                actionCell.innerHTML = '<div class="revision-actions">' +
+                    '<button onclick="editQuestion(' + question.id + ')">编辑</button>' +
+                    '<button onclick="showRevisions(' + question.id + ')">历史</button>' +
+                    '<button onclick="showRegenerate(' + question.id + ')">重新生成</button>' +
+                    '<button class="btn-danger" onclick="deleteQuestion(' + question.id + ')">删除</button>' +
+                    '</div>';
+                row.id = 'question-row-' + question.id;
+                loadedQuestions[question.id] = question;
             });
         }
 
-        // 渲染分页控件
-        function renderPagination(total, currentPage, limit) {
-            const totalPages = Math.ceil(total / limit);
-            const pagination = document.getElementById('pagination');
-            pagination.innerHTML = '';
-            
-            if (totalPages <= 1) {
-                return;
-            }
-            
-            // 上一页按钮
-            if (currentPage > 1) {
-                const prevButton = document.createElement('button');
-                prevButton.textContent = '上一页';
-                prevButton.onclick = () => {
-                    if (currentKeyword) {
-                        searchQuestions(currentPage - 1);
-                    } else {
-                        loadAllQuestions(currentPage - 1);
-                    }
-                };
-                pagination.appendChild(prevButton);
-            }
-            
-            // 页码按钮
-            const startPage = Math.max(1, currentPage - 2);
-            const endPage = Math.min(totalPages, currentPage + 2);
-            
-            for (let i = startPage; i <= endPage; i++) {
-                const pageButton = document.createElement('button');
-                pageButton.textContent = i;
-                if (i === currentPage) {
-                    pageButton.classList.add('current');
-                }
-                pageButton.onclick = () => {
-                    if (currentKeyword) {
-                        searchQuestions(i);
-                    } else {
-                        loadAllQuestions(i);
-                    }
-                };
-                pagination.appendChild(pageButton);
-            }
-            
-            // 下一页按钮
-            if (currentPage < totalPages) {
-                const nextButton = document.createElement('button');
-                nextButton.textContent = '下一页';
-                nextButton.onclick = () => {
-                    if (currentKeyword) {
-                        searchQuestions(currentPage + 1);
-                    } else {
-                        loadAllQuestions(currentPage + 1);
-                    }
-                };
-                pagination.appendChild(nextButton);
-            }
-        }
+        // 题目增删改
+        let loadedQuestions = {};
 
-        // 显示加载状态
-        function showLoading() {
-            document.getElementById('loading').classList.remove('hidden');
+        function toggleSection(id) {
+            document.getElementById(id).classList.toggle('hidden');
         }
 
-        // 隐藏加载状态
-        function hideLoading() {
-            document.getElementById('loading').classList.add('hidden');
+        function sendQuestion(method, url, body) {
+            return fetch(url, {
+                method: method,
+                headers: {
+                    'Content-Type': 'application/json'
+                },
+                body: body ? JSON.stringify(body) : undefined
+            })
+            .then(response => response.json())
+            .then(data => {
+                if (data.error) {
+                    throw new Error(data.error);
+                }
+                return data;
+            });
         }
 
-        // 显示错误信息
-        function showError(message) {
-            const errorElement = document.getElementById('error');
-            errorElement.textContent = message;
-            errorElement.classList.remove('hidden');
+        function createQuestion() {
+            const body = {
+                question: document.getElementById('newQuestion').value.trim(),
+                options: document.getElementById('newOptions').value.trim(),
+                type: document.getElementById('newType').value.trim(),
+                answer: document.getElementById('newAnswer').value.trim()
+            };
+            if (!body.question || !body.answer) {
+                alert('题目和答案不能为空');
+                return;
+            }
+            sendQuestion('POST', '/admin/questions', body)
+                .then(() => {
+                    ['newQuestion', 'newOptions', 'newType', 'newAnswer'].forEach(id => {
+                        document.getElementById(id).value = '';
+                    });
+                    toggleSection('questionForm');
+                    loadStats();
+                    reloadQuestions();
+                })
+                .catch(err => alert('添加失败: ' + err.message));
         }
 
-        // 隐藏错误信息
-        function hideError() {
-            document.getElementById('error').classList.add('hidden');
+        // 在表格中直接编辑题目
+        function editQuestion(id) {
+            const question = loadedQuestions[id];
+            const row = document.getElementById('question-row-' + id);
+            if (!question || !row) {
+                return;
+            }
+            row.cells[1].innerHTML =
+                '<textarea id="edit-question-' + id + '" rows="2" style="width: 100%;">' + escapeHtml(question.question) + '</textarea>' +
+                '<textarea id="edit-options-' + id + '" rows="3" style="width: 100%;" placeholder="选项">' + escapeHtml(question.options || '') + '</textarea>' +
+                '<input type="text" id="edit-type-' + id + '" placeholder="题型" value="' + escapeHtml(question.type || '') + '">';
+            row.cells[2].innerHTML =
+                '<textarea id="edit-answer-' + id + '" rows="3" style="width: 100%;">' + escapeHtml(question.answer) + '</textarea>' +
+                '<input type="text" id="edit-reason-' + id + '" placeholder="修改原因（可选）">';
+            row.cells[5].innerHTML = '<div class="revision-actions">' +
+                '<button onclick="saveQuestion(' + id + ')">保存</button>' +
+                '<button onclick="reloadQuestions()">取消</button>' +
+                '</div>';
         }
 
-        // 转义HTML特殊字符
-        function escapeHtml(text) {
-            if (!text) return '';
-            const map = {
-                '&': '&amp;',
-                '<': '&lt;',
-                '>': '&gt;',
-                '"': '&quot;',
-                "'": '&#039;'
+        function saveQuestion(id) {
+            const body = {
+                question: document.getElementById('edit-question-' + id).value.trim(),
+                options: document.getElementById('edit-options-' + id).value.trim(),
+                type: document.getElementById('edit-type-' + id).value.trim(),
+                answer: document.getElementById('edit-answer-' + id).value.trim(),
+                reason: document.getElementById('edit-reason-' + id).value.trim()
             };
-            return text.replace(/[&<>"']/g, function(m) { return map[m]; });
+            if (!body.question || !body.answer) {
+                alert('题目和答案不能为空');
+                return;
+            }
+            sendQuestion('PUT', '/admin/questions/' + id, body)
+                .then(() => reloadQuestions())
+                .catch(err => alert('保存失败: ' + err.message));
         }
 
-        // 回车键触发搜索
-        document.getElementById('searchKeyword').addEventListener('keypress', function(e) {
-            if (e.key === 'Enter') {
-                searchQuestions(1);
+        function deleteQuestion(id) {
+            if (!confirm('确定要删除这道题目及其答案历史吗？此操作不可恢复！')) {
+                return;
             }
-        });
+            sendQuestion('DELETE', '/admin/questions/' + id)
+                .then(() => {
+                    loadStats();
+                    reloadQuestions();
+                })
+                .catch(err => alert('删除失败: ' + err.message));
+        }
 
-        // 退出登录
+        // 先统计匹配的题目数量，确认后再删除
+        function bulkDeleteQuestions() {
+            const params = new URLSearchParams();
+            const filters = {
+                keyword: document.getElementById('deleteKeyword').value.trim(),
+                type: document.getElementById('deleteType').value.trim(),
+                source: document.getElementById('deleteSource').value,
+                from: document.getElementById('deleteFrom').value,
+                to: document.getElementById('deleteTo').value
+            };
+            Object.keys(filters).forEach(k => {
+                if (filters[k]) {
+                    params.append(k, filters[k]);
+                }
+            });
+            if (!params.toString()) {
+                alert('请至少填写一个筛选条件');
+                return;
+            }
+
+            sendQuestion('DELETE', '/admin/questions?dry_run=1&' + params.toString())
+                .then(data => {
+                    if (data.data.count === 0) {
+                        alert('没有符合条件的题目');
+                        return;
+                    }
+                    if (!confirm('将删除 ' + data.data.count + ' 道题目及其答案历史，此操作不可恢复！确定继续吗？')) {
+                        return;
+                    }
+                    return sendQuestion('DELETE', '/admin/questions?' + params.toString())
+                        .then(result => {
+                            alert('已删除 ' + result.data.count + ' 道题目');
+                            loadStats();
+                            reloadQuestions();
+                        });
+                })
+                .catch(err => alert('批量删除失败: ' + err.message));
+        }
+
+        // 渲染答案来源、置信度、Token用量和命中次数
+        function renderAnswerSource(question) {
+            const sourceNames = { model: 'AI模型', admin: '管理员', import: '导入', feedback: '用户反馈' };
+            let html = '<div>' + escapeHtml(sourceNames[question.source] || question.source || '未知') + '</div>';
+            if (question.platform || question.model) {
+                html += '<div class="api-key-date">' + escapeHtml([question.platform, question.model].filter(Boolean).join(' / ')) + '</div>';
+            }
+            const details = [];
+            if (question.confidence !== undefined && question.confidence !== null) {
+                details.push('置信度 ' + Math.round(question.confidence * 100) + '%');
+            }
+            if (question.prompt_tokens || question.completion_tokens) {
+                details.push('Token ' + (question.prompt_tokens + question.completion_tokens));
+            }
+            details.push('命中 ' + (question.hit_count || 0));
+            html += '<div class="api-key-date">' + details.join(' · ') + '</div>';
+            return html;
+        }
+
+        // 重新生成答案，多个平台的候选答案与当前答案并排比较
+        let currentRegenerateQuestion = 0;
+        let regenerateCandidates = [];
+
+        function showRegenerate(id) {
+            currentRegenerateQuestion = id;
+            const question = loadedQuestions[id];
+            document.getElementById('regenerateTitle').textContent = question ? '#' + id + ' ' + question.question : '#' + id;
+            document.getElementById('regenerateResult').innerHTML = '';
+            document.getElementById('regenerateError').classList.add('hidden');
+            document.getElementById('regeneratePanel').classList.remove('hidden');
+
+            fetch('/admin/platforms')
+                .then(response => response.json())
+                .then(data => {
+                    const container = document.getElementById('regeneratePlatforms');
+                    container.innerHTML = '<label>选择平台（可修改模型）</label>';
+                    (data.data || []).forEach(p => {
+                        const item = document.createElement('div');
+                        item.className = 'revision-actions';
+                        item.innerHTML = '<label><input type="checkbox" class="regen-platform" value="' + escapeHtml(p.platform) + '"' +
+                            (p.default ? ' checked' : '') + (p.configured ? '' : ' disabled') + '> ' + escapeHtml(p.platform) +
+                            (p.configured ? '' : '（未配置密钥）') + '</label>' +
+                            '<input type="text" class="regen-model" data-platform="' + escapeHtml(p.platform) + '" value="' + escapeHtml(p.model || '') + '" placeholder="模型">';
+                        container.appendChild(item);
+                    });
+                })
+                .catch(err => alert('加载平台列表失败: ' + err.message));
+        }
+
+        function regenerateAnswer() {
+            const candidates = [];
+            document.querySelectorAll('.regen-platform:checked').forEach(box => {
+                const model = document.querySelector('.regen-model[data-platform="' + box.value + '"]').value.trim();
+                candidates.push({ platform: box.value, model: model });
+            });
+            if (candidates.length === 0) {
+                alert('请至少选择一个平台');
+                return;
+            }
+
+            const loading = document.getElementById('regenerateLoading');
+            const error = document.getElementById('regenerateError');
+            loading.classList.remove('hidden');
+            error.classList.add('hidden');
+            document.getElementById('regenerateResult').innerHTML = '';
+
+            sendQuestion('POST', '/admin/questions/' + currentRegenerateQuestion + '/regenerate', { candidates: candidates })
+                .then(data => {
+                    loading.classList.add('hidden');
+                    renderCandidates(data.data.question, data.data.candidates);
+                })
+                .catch(err => {
+                    loading.classList.add('hidden');
+                    error.textContent = '重新生成失败: ' + err.message;
+                    error.classList.remove('hidden');
+                });
+        }
+
+        function renderCandidates(question, candidates) {
+            regenerateCandidates = candidates;
+            let html = '<div class="candidate"><h4>当前答案</h4>' +
+                '<div class="api-key-date">' + escapeHtml([question.platform, question.model].filter(Boolean).join(' / ') || '-') + '</div>' +
+                '<div class="answer-text">' + escapeHtml(question.answer) + '</div></div>';
+            candidates.forEach((cand, i) => {
+                html += '<div class="candidate"><h4>' + escapeHtml(cand.platform) + '</h4>' +
+                    '<div class="api-key-date">' + escapeHtml(cand.model || '-') + ' · ' + cand.latency_ms + 'ms';
+                if (!cand.error) {
+                    html += ' · Token ' + (cand.prompt_tokens + cand.completion_tokens);
+                }
+                html += '</div>';
+                if (cand.error) {
+                    html += '<div class="error">' + escapeHtml(cand.error) + '</div>';
+                } else {
+                    html += '<div class="answer-text">' + escapeHtml(cand.answer) + '</div>' +
+                        '<button onclick="acceptCandidate(' + i + ')">采用此答案</button>';
+                }
+                html += '</div>';
+            });
+            document.getElementById('regenerateResult').innerHTML = html;
+        }
+
+        function acceptCandidate(index) {
+            const cand = regenerateCandidates[index];
+            if (!cand || !confirm('确定用 ' + cand.platform + ' 的答案替换当前答案吗？')) {
+                return;
+            }
+            sendQuestion('POST', '/admin/questions/' + currentRegenerateQuestion + '/accept', {
+                answer: cand.answer,
+                platform: cand.platform,
+                model: cand.model,
+                prompt_tokens: cand.prompt_tokens,
+                completion_tokens: cand.completion_tokens
+            })
+                .then(() => {
+                    closeRegenerate();
+                    reloadQuestions();
+                })
+                .catch(err => alert('采用答案失败: ' + err.message));
+        }
+
+        function closeRegenerate() {
+            document.getElementById('regeneratePanel').classList.add('hidden');
+        }
+
+        // 答案历史版本
+        let currentRevisionQuestion = 0;
+
+        function showRevisions(id) {
+            currentRevisionQuestion = id;
+            const panel = document.getElementById('revisionPanel');
+            const list = document.getElementById('revisionList');
+            const error = document.getElementById('revisionError');
+            document.getElementById('revisionTitle').textContent = '#' + id;
+            panel.classList.remove('hidden');
+            error.classList.add('hidden');
+            list.innerHTML = '加载中...';
+
+            fetch('/admin/questions/' + id + '/revisions')
+                .then(response => response.json())
+                .then(data => {
+                    if (data.error) {
+                        list.innerHTML = '';
+                        error.textContent = data.error;
+                        error.classList.remove('hidden');
+                        return;
+                    }
+                    renderRevisions(data.data);
+                })
+                .catch(err => {
+                    list.innerHTML = '';
+                    error.textContent = '加载历史版本失败: ' + err.message;
+                    error.classList.remove('hidden');
+                });
+            panel.scrollIntoView({ behavior: 'smooth' });
+        }
+
+        function renderRevisions(revisions) {
+            const list = document.getElementById('revisionList');
+            list.innerHTML = '';
+            if (revisions.length === 0) {
+                list.innerHTML = '<p>暂无历史版本</p>';
+                return;
+            }
+
+            const sourceNames = { model: 'AI模型', admin: '管理员', import: '导入' };
+            revisions.forEach(rev => {
+                const item = document.createElement('div');
+                item.className = 'revision-item';
+                let origin = sourceNames[rev.source] || rev.source;
+                if (rev.platform) {
+                    origin += ' (' + rev.platform + (rev.model ? ' / ' + rev.model : '') + ')';
+                }
+                if (rev.operator) {
+                    origin += ' (' + rev.operator + ')';
+                }
+                item.innerHTML =
+                    '<div class="api-key-date">#' + rev.id + ' · ' + new Date(rev.created_at).toLocaleString('zh-CN') + ' · ' + escapeHtml(origin) + '</div>' +
+                    (rev.reason ? '<div>原因: ' + escapeHtml(rev.reason) + '</div>' : '') +
+                    '<div class="answer-text">答案: ' + escapeHtml(rev.answer) + '</div>' +
+                    (rev.previous_answer ? '<div class="answer-text">修改前: ' + escapeHtml(rev.previous_answer) + '</div>' : '') +
+                    '<div class="revision-actions">' +
+                        '<button onclick="rollbackAnswer(' + rev.id + ', false)">回滚到此版本</button>' +
+                        (rev.previous_answer ? '<button onclick="rollbackAnswer(' + rev.id + ', true)">还原为修改前</button>' : '') +
+                    '</div>';
+                list.appendChild(item);
+            });
+        }
+
+        function rollbackAnswer(revisionId, restorePrevious) {
+            if (!confirm('确定要回滚答案吗？')) {
+                return;
+            }
+            fetch('/admin/questions/' + currentRevisionQuestion + '/rollback', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json'
+                },
+                body: JSON.stringify({ revision_id: revisionId, restore_previous: restorePrevious })
+            })
+            .then(response => response.json())
+            .then(data => {
+                if (data.error) {
+                    alert(data.error);
+                    return;
+                }
+                showRevisions(currentRevisionQuestion);
+                reloadQuestions();
+            })
+            .catch(err => alert('回滚失败: ' + err.message));
+        }
+
+        function closeRevisions() {
+            document.getElementById('revisionPanel').classList.add('hidden');
+        }
+
+        // 按当前的搜索状态刷新题目列表
+        function reloadQuestions() {
+            if (currentKeyword) {
+                searchQuestions(currentPage);
+            } else {
+                loadAllQuestions(currentPage);
+            }
+        }
+
+        // 渲染分页控件
+        function renderPagination(total, currentPage, limit) {
+            const totalPages = Math.ceil(total / limit);
+            const pagination = document.getElementById('pagination');
+            pagination.innerHTML = '';
+            
+            if (totalPages <= 1) {
+                return;
+            }
+            
+            // 上一页按钮
+            if (currentPage > 1) {
+                const prevButton = document.createElement('button');
+                prevButton.textContent = '上一页';
+                prevButton.onclick = () => {
+                    if (currentKeyword) {
+                        searchQuestions(currentPage - 1);
+                    } else {
+                        loadAllQuestions(currentPage - 1);
+                    }
+                };
+                pagination.appendChild(prevButton);
+            }
+            
+            // 页码按钮
+            const startPage = Math.max(1, currentPage - 2);
+            const endPage = Math.min(totalPages, currentPage + 2);
+            
+            for (let i = startPage; i <= endPage; i++) {
+                const pageButton = document.createElement('button');
+                pageButton.textContent = i;
+                if (i === currentPage) {
+                    pageButton.classList.add('current');
+                }
+                pageButton.onclick = () => {
+                    if (currentKeyword) {
+                        searchQuestions(i);
+                    } else {
+                        loadAllQuestions(i);
+                    }
+                };
+                pagination.appendChild(pageButton);
+            }
+            
+            // 下一页按钮
+            if (currentPage < totalPages) {
+                const nextButton = document.createElement('button');
+                nextButton.textContent = '下一页';
+                nextButton.onclick = () => {
+                    if (currentKeyword) {
+                        searchQuestions(currentPage + 1);
+                    } else {
+                        loadAllQuestions(currentPage + 1);
+                    }
+                };
+                pagination.appendChild(nextButton);
+            }
+        }
+
+        // 显示加载状态
+        function showLoading() {
+            document.getElementById('loading').classList.remove('hidden');
+        }
+
+        // 隐藏加载状态
+        function hideLoading() {
+            document.getElementById('loading').classList.add('hidden');
+        }
+
+        // 显示错误信息
+        function showError(message) {
+            const errorElement = document.getElementById('error');
+            errorElement.textContent = message;
+            errorElement.classList.remove('hidden');
+        }
+
+        // 隐藏错误信息
+        function hideError() {
+            document.getElementById('error').classList.add('hidden');
+        }
+
+        // 转义HTML特殊字符
+        function escapeHtml(text) {
+            if (!text) return '';
+            const map = {
+                '&': '&amp;',
+                '<': '&lt;',
+                '>': '&gt;',
+                '"': '&quot;',
+                "'": '&#039;'
+            };
+            return text.replace(/[&<>"']/g, function(m) { return map[m]; });
+        }
+
+        // 回车键触发搜索
+        document.getElementById('searchKeyword').addEventListener('keypress', function(e) {
+            if (e.key === 'Enter') {
+                searchQuestions(1);
+            }
+        });
+
+        // 退出登录
         function logout() {
             fetch('/admin/logout', {
                 method: 'POST'
@@ -1106,34 +2234,715 @@ func AdminPage(c *gin.Context) {
             .then(() => {
                 window.location.href = '/admin/login';
             })
-            .catch(error => {
-                console.error('登出失败:', error);
-                window.location.href = '/admin/login';
+            .catch(error => {
+                console.error('登出失败:', error);
+                window.location.href = '/admin/login';
+            });
+        }
+
+        // Tab切换功能
+        function openTab(evt, tabName) {
+            var i, tabcontent, tablinks;
+            tabcontent = document.getElementsByClassName("tabcontent");
+            for (i = 0; i < tabcontent.length; i++) {
+                tabcontent[i].style.display = "none";
+            }
+            tablinks = document.getElementsByClassName("tablinks");
+            for (i = 0; i < tablinks.length; i++) {
+                tablinks[i].className = tablinks[i].className.replace(" active", "");
+            }
+            document.getElementById(tabName).style.display = "block";
+            evt.currentTarget.className += " active";
+            
+            if (tabName === 'dashboard') {
+                loadBudget();
+                loadProviders();
+            }
+            // 如果切换到API密钥管理标签，重新加载数据
+            if (tabName === 'apikeys') {
+                loadAPIKeys();
+                loadCostReport();
+            }
+            if (tabName === 'querylogs') {
+                loadQueryLogs(1);
+            }
+            if (tabName === 'review') {
+                loadReview(1);
+            }
+            if (tabName === 'verify') {
+                loadVerifier();
+                loadVerifications(1);
+            }
+            if (tabName === 'feedback') {
+                loadFeedback(1);
+            }
+        }
+
+        // 题库导入功能
+        function importQuestions(dryRun) {
+            const fileInput = document.getElementById('importFile');
+            const loading = document.getElementById('importLoading');
+            const error = document.getElementById('importError');
+            if (!fileInput.files.length) {
+                alert('请选择要导入的文件');
+                return;
+            }
+            if (!dryRun && !confirm('确定要将文件导入题库吗？')) {
+                return;
+            }
+
+            const form = new FormData();
+            form.append('file', fileInput.files[0]);
+            form.append('dry_run', dryRun ? '1' : '0');
+            form.append('on_duplicate', document.getElementById('importOnDuplicate').value);
+            form.append('sheet', document.getElementById('importSheet').value.trim());
+            form.append('question', document.getElementById('mapQuestion').value.trim());
+            form.append('options', document.getElementById('mapOptions').value.trim());
+            form.append('type', document.getElementById('mapType').value.trim());
+            form.append('answer', document.getElementById('mapAnswer').value.trim());
+
+            loading.classList.remove('hidden');
+            error.classList.add('hidden');
+            document.getElementById('importReport').innerHTML = '';
+
+            fetch('/admin/questions/import', { method: 'POST', body: form })
+                .then(response => response.json())
+                .then(data => {
+                    loading.classList.add('hidden');
+                    if (data.error) {
+                        error.textContent = data.error;
+                        error.classList.remove('hidden');
+                        return;
+                    }
+                    renderImportReport(data.message, data.data);
+                    if (!dryRun) {
+                        loadStats();
+                    }
+                })
+                .catch(err => {
+                    loading.classList.add('hidden');
+                    error.textContent = '导入失败: ' + err.message;
+                    error.classList.remove('hidden');
+                });
+        }
+
+        function renderImportReport(message, report) {
+            const actionNames = { insert: '新增', update: '更新', skip: '跳过', invalid: '无效' };
+            const mapping = Object.keys(report.mapping)
+                .filter(k => report.mapping[k])
+                .map(k => escapeHtml(k) + ' ← ' + escapeHtml(report.mapping[k]))
+                .join('，');
+
+            let html = '<h3>' + escapeHtml(message) + '</h3>' +
+                '<p>共 ' + report.total + ' 行：新增 ' + report.inserted + '，更新 ' + report.updated +
+                '，重复 ' + report.duplicates + '，无效 ' + report.invalid + '</p>' +
+                '<p class="snippet">列映射：' + mapping + '</p>' +
+                '<table><thead><tr><th>行号</th><th>题目</th><th>答案</th><th>处理</th><th>说明</th></tr></thead><tbody>';
+            (report.rows || []).forEach(row => {
+                let reason = row.reason || '';
+                if (row.existing_id) {
+                    reason += '（#' + row.existing_id + '）';
+                }
+                html += '<tr><td>' + row.line + '</td>' +
+                    '<td class="question-text">' + escapeHtml(row.question) + '</td>' +
+                    '<td class="answer-text">' + escapeHtml(row.answer || '') + '</td>' +
+                    '<td>' + (actionNames[row.action] || row.action) + '</td>' +
+                    '<td>' + escapeHtml(reason) + '</td></tr>';
+            });
+            html += '</tbody></table>';
+            if (report.truncated) {
+                html += '<p class="snippet">仅显示前 ' + report.rows.length + ' 行明细</p>';
+            }
+            document.getElementById('importReport').innerHTML = html;
+        }
+
+        // 题库导出，由浏览器直接下载
+        function exportQuestions() {
+            const params = new URLSearchParams({ format: document.getElementById('exportFormat').value });
+            const filters = {
+                keyword: document.getElementById('exportKeyword').value.trim(),
+                type: document.getElementById('exportType').value.trim(),
+                source: document.getElementById('exportSource').value,
+                from: document.getElementById('exportFrom').value,
+                to: document.getElementById('exportTo').value
+            };
+            Object.keys(filters).forEach(k => {
+                if (filters[k]) {
+                    params.append(k, filters[k]);
+                }
+            });
+            window.location.href = '/admin/questions/export?' + params.toString();
+        }
+
+        // 答案审核功能
+        const reviewStatusNames = { unverified: '未审核', verified: '已通过', disputed: '有争议', rejected: '已驳回' };
+        let reviewPage = 1;
+        let reviewItems = [];
+        let reviewCursor = 0;
+
+        function loadReview(page) {
+            reviewPage = page;
+            const error = document.getElementById('reviewError');
+            const params = new URLSearchParams({ page: page, status: document.getElementById('reviewStatus').value });
+            const keyword = document.getElementById('reviewKeyword').value.trim();
+            const type = document.getElementById('reviewType').value.trim();
+            if (keyword) {
+                params.append('keyword', keyword);
+            }
+            if (type) {
+                params.append('type', type);
+            }
+            error.classList.add('hidden');
+
+            fetch('/admin/review?' + params.toString())
+                .then(response => response.json())
+                .then(data => {
+                    if (data.error) {
+                        error.textContent = data.error;
+                        error.classList.remove('hidden');
+                        return;
+                    }
+                    reviewItems = data.data || [];
+                    reviewCursor = Math.min(reviewCursor, Math.max(reviewItems.length - 1, 0));
+                    renderReview();
+                    renderReviewPagination(data.total, data.page, data.limit);
+                })
+                .catch(err => {
+                    error.textContent = '加载审核队列失败: ' + err.message;
+                    error.classList.remove('hidden');
+                });
+        }
+
+        function renderReview() {
+            const tbody = document.getElementById('reviewBody');
+            tbody.innerHTML = '';
+            document.getElementById('reviewSelectAll').checked = false;
+            updateReviewSelection();
+
+            if (reviewItems.length === 0) {
+                const row = tbody.insertRow();
+                const cell = row.insertCell(0);
+                cell.colSpan = 6;
+                cell.textContent = '没有需要审核的答案';
+                cell.style.textAlign = 'center';
+                return;
+            }
+
+            reviewItems.forEach((q, i) => {
+                loadedQuestions[q.id] = q;
+                const row = tbody.insertRow();
+                row.id = 'review-row-' + i;
+                if (i === reviewCursor) {
+                    row.classList.add('review-cursor');
+                }
+                row.onclick = () => moveReviewCursor(i);
+                row.insertCell(0).innerHTML = '<input type="checkbox" class="review-check" data-id="' + q.id + '" onclick="event.stopPropagation()" onchange="updateReviewSelection()">';
+                row.insertCell(1).textContent = q.id;
+                let questionHtml = '<div class="question-text">' + escapeHtml(q.question) + '</div>';
+                if (q.options) {
+                    questionHtml += '<div class="snippet">选项: ' + escapeHtml(q.options) + '</div>';
+                }
+                if (q.type) {
+                    questionHtml += '<div class="snippet">类型: ' + escapeHtml(q.type) + '</div>';
+                }
+                row.insertCell(2).innerHTML = questionHtml;
+                row.insertCell(3).innerHTML = '<div class="answer-text">' + escapeHtml(q.answer) + '</div>';
+                row.insertCell(4).innerHTML = '<div>' + (reviewStatusNames[q.review_status] || q.review_status) + '</div>' + renderAnswerSource(q);
+                row.insertCell(5).innerHTML = '<div class="revision-actions">' +
+                    '<button onclick="reviewQuestions([' + q.id + '], \'verified\')">通过</button>' +
+                    '<button class="btn-danger" onclick="reviewQuestions([' + q.id + '], \'rejected\')">驳回</button>' +
+                    '<button onclick="editReviewAnswer(' + i + ')">编辑</button>' +
+                    '<button onclick="showRevisions(' + q.id + ')">历史</button>' +
+                    '</div>';
+            });
+        }
+
+        function moveReviewCursor(i) {
+            if (i < 0 || i >= reviewItems.length) {
+                return;
+            }
+            const old = document.getElementById('review-row-' + reviewCursor);
+            if (old) {
+                old.classList.remove('review-cursor');
+            }
+            reviewCursor = i;
+            const row = document.getElementById('review-row-' + i);
+            row.classList.add('review-cursor');
+            row.scrollIntoView({ block: 'nearest' });
+        }
+
+        function selectedReviewIds() {
+            return Array.from(document.querySelectorAll('.review-check:checked')).map(el => parseInt(el.dataset.id, 10));
+        }
+
+        function selectAllReview(checked) {
+            document.querySelectorAll('.review-check').forEach(el => { el.checked = checked; });
+            updateReviewSelection();
+        }
+
+        function updateReviewSelection() {
+            const count = selectedReviewIds().length;
+            document.getElementById('reviewSelection').textContent = count ? '已选中 ' + count + ' 道题目' : '';
+        }
+
+        // 批量操作作用于选中的题目，没有选中时作用于当前题目
+        function reviewSelected(status) {
+            let ids = selectedReviewIds();
+            if (ids.length === 0 && reviewItems[reviewCursor]) {
+                ids = [reviewItems[reviewCursor].id];
+            }
+            if (ids.length === 0) {
+                return;
+            }
+            if (status === 'rejected' && ids.length > 1 && !confirm('确定驳回选中的 ' + ids.length + ' 个答案吗？')) {
+                return;
+            }
+            reviewQuestions(ids, status);
+        }
+
+        function reviewQuestions(ids, status) {
+            sendQuestion('POST', '/admin/review', { ids: ids, status: status })
+                .then(() => loadReview(reviewPage))
+                .catch(err => alert('审核失败: ' + err.message));
+        }
+
+        function editReviewAnswer(i) {
+            const q = reviewItems[i];
+            const answer = prompt('修改答案（保存后视为审核通过）', q.answer);
+            if (answer === null || !answer.trim()) {
+                return;
+            }
+            sendQuestion('PUT', '/admin/questions/' + q.id, {
+                question: q.question,
+                options: q.options || '',
+                type: q.type || '',
+                answer: answer.trim(),
+                reason: '审核时修改'
+            })
+                .then(() => loadReview(reviewPage))
+                .catch(err => alert('保存失败: ' + err.message));
+        }
+
+        document.addEventListener('keydown', function(e) {
+            if (document.getElementById('review').style.display !== 'block') {
+                return;
+            }
+            const tag = e.target.tagName;
+            if (tag === 'INPUT' || tag === 'TEXTAREA' || tag === 'SELECT' || e.ctrlKey || e.metaKey || e.altKey) {
+                return;
+            }
+            const actions = { a: 'verified', r: 'rejected', d: 'disputed', u: 'unverified' };
+            const key = e.key.toLowerCase();
+            if (key === 'j') {
+                moveReviewCursor(reviewCursor + 1);
+            } else if (key === 'k') {
+                moveReviewCursor(reviewCursor - 1);
+            } else if (key === 'x' && e.shiftKey) {
+                const all = document.getElementById('reviewSelectAll');
+                all.checked = !all.checked;
+                selectAllReview(all.checked);
+            } else if (key === 'x') {
+                const check = document.querySelectorAll('.review-check')[reviewCursor];
+                if (check) {
+                    check.checked = !check.checked;
+                    updateReviewSelection();
+                }
+            } else if (key === 'e') {
+                editReviewAnswer(reviewCursor);
+            } else if (actions[key]) {
+                reviewSelected(actions[key]);
+            } else {
+                return;
+            }
+            e.preventDefault();
+        });
+
+        function renderReviewPagination(total, page, pageSize) {
+            const totalPages = Math.ceil(total / pageSize);
+            const pagination = document.getElementById('reviewPagination');
+            pagination.innerHTML = '';
+
+            if (totalPages <= 1) {
+                return;
+            }
+
+            const startPage = Math.max(1, page - 2);
+            const endPage = Math.min(totalPages, page + 2);
+            for (let i = startPage; i <= endPage; i++) {
+                const pageButton = document.createElement('button');
+                pageButton.textContent = i;
+                if (i === page) {
+                    pageButton.classList.add('current');
+                }
+                pageButton.onclick = () => loadReview(i);
+                pagination.appendChild(pageButton);
+            }
+        }
+
+        // 答案复核功能
+        function loadVerifier() {
+            fetch('/admin/verifier')
+                .then(response => response.json())
+                .then(data => {
+                    const s = data.data;
+                    let state = s.enabled ? (s.paused ? '已暂停' : (s.running ? '复核中' : '运行中')) : '未启用';
+                    if (s.enabled && s.budget_exhausted) {
+                        state = '今日预算已用完';
+                    }
+                    document.getElementById('verifierState').textContent = state;
+                    document.getElementById('verifierModel').textContent = '复核模型 ' + s.platform + ' / ' + s.model;
+                    document.getElementById('verifierPending').textContent = s.pending;
+                    document.getElementById('verifierTokens').textContent = s.tokens_today + ' / ' + (s.daily_token_budget || '不限');
+                    document.getElementById('verifierCounts').textContent = s.agreed + ' / ' + s.mismatched + ' / ' + s.errors;
+                    const times = [];
+                    if (s.last_run_at) {
+                        times.push('上次复核：' + new Date(s.last_run_at).toLocaleString('zh-CN'));
+                    }
+                    if (s.enabled && s.next_run_at) {
+                        times.push('下次复核：' + new Date(s.next_run_at).toLocaleString('zh-CN'));
+                    }
+                    times.push('每 ' + s.interval_minutes + ' 分钟最多 ' + s.batch_size + ' 条，每分钟最多 ' + s.requests_per_minute + ' 次请求');
+                    if (s.last_error) {
+                        times.push('最近错误：' + s.last_error);
+                    }
+                    document.getElementById('verifierTimes').textContent = times.join('；');
+                })
+                .catch(error => {
+                    console.error('获取复核状态失败:', error);
+                });
+        }
+
+        function verifierAction(action) {
+            sendQuestion('POST', '/admin/verifier/' + action)
+                .then(data => {
+                    alert(data.message);
+                    loadVerifier();
+                })
+                .catch(err => alert('操作失败: ' + err.message));
+        }
+
+        let verifyPage = 1;
+        const loadedVerifications = {};
+
+        function loadVerifications(page) {
+            verifyPage = page;
+            const error = document.getElementById('verifyError');
+            const params = new URLSearchParams({ page: page });
+            const status = document.getElementById('verifyStatus').value;
+            if (status) {
+                params.append('status', status);
+            }
+            if (document.getElementById('verifyDisputed').value) {
+                params.append('disputed', '1');
+            }
+            error.classList.add('hidden');
+
+            fetch('/admin/verifications?' + params.toString())
+                .then(response => response.json())
+                .then(data => {
+                    if (data.error) {
+                        error.textContent = data.error;
+                        error.classList.remove('hidden');
+                        return;
+                    }
+                    renderVerifications(data.data || []);
+                    renderVerifyPagination(data.total, data.page, data.limit);
+                })
+                .catch(err => {
+                    error.textContent = '加载复核记录失败: ' + err.message;
+                    error.classList.remove('hidden');
+                });
+        }
+
+        function renderVerifications(records) {
+            const statusNames = { agree: '一致', mismatch: '不一致', error: '失败' };
+            const tbody = document.getElementById('verificationsBody');
+            tbody.innerHTML = '';
+
+            if (records.length === 0) {
+                const row = tbody.insertRow();
+                const cell = row.insertCell(0);
+                cell.colSpan = 6;
+                cell.textContent = '暂无复核记录';
+                cell.style.textAlign = 'center';
+                return;
+            }
+
+            records.forEach(v => {
+                loadedVerifications[v.id] = v;
+                const row = tbody.insertRow();
+                row.insertCell(0).textContent = new Date(v.created_at).toLocaleString('zh-CN');
+                row.insertCell(1).innerHTML = '<div class="question-text">' + escapeHtml(v.question) + '</div>';
+                row.insertCell(2).innerHTML = '<div class="answer-text">' + escapeHtml(v.current_answer) + '</div>';
+                row.insertCell(3).innerHTML = '<div class="answer-text">' + escapeHtml(v.verifier_answer || v.error || '') + '</div>' +
+                    '<div class="snippet">' + escapeHtml(v.platform + ' / ' + v.model) + '</div>';
+                row.insertCell(4).textContent = (statusNames[v.status] || v.status) + (v.review_status === 'disputed' ? '（待处理）' : '');
+                const actions = row.insertCell(5);
+                if (v.review_status === 'disputed' && v.verifier_answer) {
+                    actions.innerHTML = '<button onclick="acceptVerification(' + v.id + ')">采用复核答案</button> ' +
+                        '<button onclick="keepCurrentAnswer(' + v.question_id + ')">保留现有答案</button>';
+                }
+            });
+        }
+
+        function acceptVerification(id) {
+            const v = loadedVerifications[id];
+            if (!confirm('确定用复核答案替换现有答案吗？')) {
+                return;
+            }
+            sendQuestion('POST', '/admin/questions/' + v.question_id + '/accept', {
+                answer: v.verifier_answer,
+                platform: v.platform,
+                model: v.model,
+                prompt_tokens: v.prompt_tokens,
+                completion_tokens: v.completion_tokens,
+                reason: '后台复核后选用'
+            })
+                .then(() => loadVerifications(verifyPage))
+                .catch(err => alert('采用失败: ' + err.message));
+        }
+
+        function keepCurrentAnswer(questionId) {
+            sendQuestion('POST', '/admin/review', { ids: [questionId], status: 'verified' })
+                .then(() => loadVerifications(verifyPage))
+                .catch(err => alert('操作失败: ' + err.message));
+        }
+
+        function renderVerifyPagination(total, page, pageSize) {
+            const totalPages = Math.ceil(total / pageSize);
+            const pagination = document.getElementById('verifyPagination');
+            pagination.innerHTML = '';
+
+            if (totalPages <= 1) {
+                return;
+            }
+
+            const startPage = Math.max(1, page - 2);
+            const endPage = Math.min(totalPages, page + 2);
+            for (let i = startPage; i <= endPage; i++) {
+                const pageButton = document.createElement('button');
+                pageButton.textContent = i;
+                if (i === page) {
+                    pageButton.classList.add('current');
+                }
+                pageButton.onclick = () => loadVerifications(i);
+                pagination.appendChild(pageButton);
+            }
+        }
+
+        // 用户反馈功能
+        let feedbackPage = 1;
+        const loadedFeedback = {};
+
+        function loadFeedback(page) {
+            feedbackPage = page;
+            const error = document.getElementById('feedbackError');
+            const params = new URLSearchParams({ page: page });
+            if (document.getElementById('feedbackResolved').value) {
+                params.append('resolved', '1');
+            }
+            error.classList.add('hidden');
+
+            fetch('/admin/feedback?' + params.toString())
+                .then(response => response.json())
+                .then(data => {
+                    if (data.error) {
+                        error.textContent = data.error;
+                        error.classList.remove('hidden');
+                        return;
+                    }
+                    renderFeedback(data.data || []);
+                    renderFeedbackPagination(data.total, data.page, data.limit);
+                })
+                .catch(err => {
+                    error.textContent = '加载反馈失败: ' + err.message;
+                    error.classList.remove('hidden');
+                });
+        }
+
+        function renderFeedback(items) {
+            const pending = !document.getElementById('feedbackResolved').value;
+            const tbody = document.getElementById('feedbackBody');
+            tbody.innerHTML = '';
+
+            if (items.length === 0) {
+                const row = tbody.insertRow();
+                const cell = row.insertCell(0);
+                cell.colSpan = 6;
+                cell.textContent = pending ? '暂无待处理的反馈' : '暂无反馈';
+                cell.style.textAlign = 'center';
+                return;
+            }
+
+            items.forEach(item => {
+                loadedFeedback[item.question_id] = item;
+                const row = tbody.insertRow();
+                row.insertCell(0).innerHTML = '<div class="question-text">' + escapeHtml(item.question) + '</div>';
+                row.insertCell(1).innerHTML = '<div class="answer-text">' + (item.answer ? escapeHtml(item.answer) : '（已作废）') + '</div>' +
+                    '<div class="snippet">' + (reviewStatusNames[item.review_status] || item.review_status) + '</div>';
+                let votes = item.correct_votes + ' / ' + item.wrong_votes;
+                if (item.stale_votes) {
+                    votes += '（旧答案 ' + item.stale_votes + '）';
+                }
+                row.insertCell(2).textContent = votes;
+                const suggestions = row.insertCell(3);
+                item.suggestions.forEach((sg, i) => {
+                    const div = document.createElement('div');
+                    div.innerHTML = '<span class="answer-text">' + escapeHtml(sg.answer) + '</span> ×' + sg.count +
+                        (pending ? ' <button onclick="resolveFeedback(' + item.question_id + ', ' + i + ')">采用</button>' : '');
+                    suggestions.appendChild(div);
+                });
+                row.insertCell(4).textContent = new Date(item.last_feedback_at).toLocaleString('zh-CN');
+                const actions = row.insertCell(5);
+                if (pending) {
+                    actions.innerHTML = (item.answer ? '<button onclick="resolveFeedback(' + item.question_id + ')">答案正确</button> ' : '') +
+                        '<button onclick="resolveFeedback(' + item.question_id + ', -1)">修改答案</button>';
+                }
             });
         }
 
-        // Tab切换功能
-        function openTab(evt, tabName) {
-            var i, tabcontent, tablinks;
-            tabcontent = document.getElementsByClassName("tabcontent");
-            for (i = 0; i < tabcontent.length; i++) {
-                tabcontent[i].style.display = "none";
+        // 处理反馈：不传 index 表示确认当前答案，-1 表示手动填写答案，其他为采用对应的建议答案
+        function resolveFeedback(questionId, index) {
+            const item = loadedFeedback[questionId];
+            let answer = '';
+            if (index === -1) {
+                answer = prompt('请输入正确答案', item.answer);
+                if (answer === null || !answer.trim()) {
+                    return;
+                }
+            } else if (index !== undefined) {
+                answer = item.suggestions[index].answer;
+                if (!confirm('确定采用建议答案“' + answer + '”吗？')) {
+                    return;
+                }
             }
-            tablinks = document.getElementsByClassName("tablinks");
-            for (i = 0; i < tablinks.length; i++) {
-                tablinks[i].className = tablinks[i].className.replace(" active", "");
+            sendQuestion('POST', '/admin/feedback/' + questionId + '/resolve', { answer: answer.trim() })
+                .then(() => loadFeedback(feedbackPage))
+                .catch(err => alert('处理失败: ' + err.message));
+        }
+
+        function renderFeedbackPagination(total, page, pageSize) {
+            const totalPages = Math.ceil(total / pageSize);
+            const pagination = document.getElementById('feedbackPagination');
+            pagination.innerHTML = '';
+
+            if (totalPages <= 1) {
+                return;
             }
-            document.getElementById(tabName).style.display = "block";
-            evt.currentTarget.className += " active";
-            
-            // 如果切换到API密钥管理标签，重新加载数据
-            if (tabName === 'apikeys') {
-                loadAPIKeys();
+
+            const startPage = Math.max(1, page - 2);
+            const endPage = Math.min(totalPages, page + 2);
+            for (let i = startPage; i <= endPage; i++) {
+                const pageButton = document.createElement('button');
+                pageButton.textContent = i;
+                if (i === page) {
+                    pageButton.classList.add('current');
+                }
+                pageButton.onclick = () => loadFeedback(i);
+                pagination.appendChild(pageButton);
+            }
+        }
+
+        // 查询日志功能
+        function loadQueryLogs(page) {
+            const loading = document.getElementById('logLoading');
+            const error = document.getElementById('logError');
+            const params = new URLSearchParams({ page: page, limit: limit });
+            const filters = {
+                keyword: document.getElementById('logKeyword').value.trim(),
+                cache_hit: document.getElementById('logCacheHit').value,
+                status: document.getElementById('logStatus').value,
+                from: document.getElementById('logFrom').value,
+                to: document.getElementById('logTo').value
+            };
+            Object.keys(filters).forEach(k => {
+                if (filters[k]) {
+                    params.append(k, filters[k]);
+                }
+            });
+
+            loading.classList.remove('hidden');
+            error.classList.add('hidden');
+
+            fetch('/admin/querylogs?' + params.toString())
+                .then(response => response.json())
+                .then(data => {
+                    loading.classList.add('hidden');
+                    if (data.error) {
+                        error.textContent = data.error;
+                        error.classList.remove('hidden');
+                        return;
+                    }
+                    renderQueryLogs(data.data || []);
+                    renderLogPagination(data.total, data.page, data.limit);
+                })
+                .catch(err => {
+                    loading.classList.add('hidden');
+                    error.textContent = '加载查询日志失败: ' + err.message;
+                    error.classList.remove('hidden');
+                });
+        }
+
+        function renderQueryLogs(logs) {
+            const tbody = document.getElementById('queryLogsBody');
+            tbody.innerHTML = '';
+
+            if (logs.length === 0) {
+                const row = tbody.insertRow();
+                const cell = row.insertCell(0);
+                cell.colSpan = 7;
+                cell.textContent = '暂无日志';
+                cell.style.textAlign = 'center';
+                return;
+            }
+
+            logs.forEach(entry => {
+                const row = tbody.insertRow();
+                row.insertCell(0).textContent = new Date(entry.created_at).toLocaleString('zh-CN');
+                row.insertCell(1).textContent = entry.api_key_description || (entry.api_key_id ? '#' + entry.api_key_id : '-');
+                let questionHtml = '<div class="question-text">' + escapeHtml(entry.question) + '</div>';
+                const metadata = entry.metadata || {};
+                const metadataText = [metadata.platform, metadata.course].filter(Boolean).join(' / ');
+                if (metadataText || (metadata.images || []).length > 0) {
+                    questionHtml += '<div class="snippet">' + escapeHtml(metadataText) +
+                        ((metadata.images || []).length > 0 ? ' 图片 ' + metadata.images.length + ' 张' : '') + '</div>';
+                }
+                row.insertCell(2).innerHTML = questionHtml;
+                row.insertCell(3).textContent = entry.cache_hit ? '缓存' : (entry.platform ? entry.platform + ' / ' + entry.model : '-');
+                row.insertCell(4).textContent = entry.latency_ms + ' ms';
+                row.insertCell(5).textContent = entry.prompt_tokens + entry.completion_tokens;
+                const logStatusNames = { ok: '成功', pending: '待审核', miss: '未命中' };
+                row.insertCell(6).textContent = logStatusNames[entry.status] || '失败: ' + (entry.error || '');
+            });
+        }
+
+        function renderLogPagination(total, page, pageSize) {
+            const totalPages = Math.ceil(total / pageSize);
+            const pagination = document.getElementById('logPagination');
+            pagination.innerHTML = '';
+
+            if (totalPages <= 1) {
+                return;
+            }
+
+            const startPage = Math.max(1, page - 2);
+            const endPage = Math.min(totalPages, page + 2);
+            for (let i = startPage; i <= endPage; i++) {
+                const pageButton = document.createElement('button');
+                pageButton.textContent = i;
+                if (i === page) {
+                    pageButton.classList.add('current');
+                }
+                pageButton.onclick = () => loadQueryLogs(i);
+                pagination.appendChild(pageButton);
             }
         }
 
         // API密钥管理功能
+        const loadedAPIKeys = {};
+
         function loadAPIKeys() {
+            document.getElementById('apiKeyScopes').innerHTML = renderScopeCheckboxes('new', ['query', 'feedback']);
             const loading = document.getElementById('apiKeyLoading');
             const error = document.getElementById('apiKeyError');
             const list = document.getElementById('apiKeysList');
@@ -1146,6 +2955,11 @@ func AdminPage(c *gin.Context) {
                 .then(response => response.json())
                 .then(data => {
                     loading.classList.add('hidden');
+                    apiKeyRoutingOptions = {
+                        platforms: data.platforms || [],
+                        defaultPlatform: data.default_platform || '',
+                        templates: data.prompt_templates || []
+                    };
                     if (data.data && data.data.length > 0) {
                         renderAPIKeys(data.data);
                     } else {
@@ -1159,11 +2973,238 @@ func AdminPage(c *gin.Context) {
                 });
         }
 
+        const apiKeyLimitFields = [
+            ['rate_per_second', '每秒'],
+            ['rate_per_minute', '每分钟'],
+            ['daily_quota', '每日'],
+            ['monthly_quota', '每月'],
+            ['daily_ai_quota', '每日AI调用'],
+            ['daily_cost_cap', '每日费用', true],
+            ['monthly_cost_cap', '每月费用', true]
+        ];
+
+        function formatCost(cost) {
+            return (cost || 0).toFixed(4);
+        }
+
+        // 调用限制及当前用量，0表示不限制
+        function formatAPIKeyLimits(key) {
+            const usage = key.usage || {};
+            const used = {
+                daily_quota: usage.today_requests || 0,
+                monthly_quota: usage.month_requests || 0,
+                daily_ai_quota: usage.today_ai_requests || 0,
+                daily_cost_cap: formatCost(usage.today_cost),
+                monthly_cost_cap: formatCost(usage.month_cost)
+            };
+            return apiKeyLimitFields.map(field => {
+                const limit = key[field[0]] || 0;
+                let text = field[1] + ': ' + (limit > 0 ? limit : '不限');
+                if (field[0] in used) {
+                    text = field[1] + ': ' + used[field[0]] + ' / ' + (limit > 0 ? limit : '不限');
+                }
+                return text;
+            }).join(' · ');
+        }
+
+        const apiKeyScopeNames = {
+            'query': '查询（可调用AI）',
+            'cache-only': '仅查询已有答案',
+            'feedback': '反馈答案',
+            'admin-api': '管理接口'
+        };
+
+        // 权限范围复选框，prefix 区分创建表单和各个密钥的设置面板
+        function renderScopeCheckboxes(prefix, scopes) {
+            return Object.keys(apiKeyScopeNames).map(scope =>
+                '<label><input type="checkbox" class="' + prefix + '-scope" value="' + scope + '"' +
+                (scopes.indexOf(scope) >= 0 ? ' checked' : '') + '> ' + apiKeyScopeNames[scope] + '</label>'
+            ).join('');
+        }
+
+        function checkedScopes(prefix) {
+            return Array.from(document.querySelectorAll('.' + prefix + '-scope:checked')).map(el => el.value);
+        }
+
+        // datetime-local 输入框与ISO时间互相转换，均按浏览器本地时间
+        function toLocalInput(iso) {
+            if (!iso) {
+                return '';
+            }
+            const d = new Date(iso);
+            const pad = n => String(n).padStart(2, '0');
+            return d.getFullYear() + '-' + pad(d.getMonth() + 1) + '-' + pad(d.getDate()) + 'T' + pad(d.getHours()) + ':' + pad(d.getMinutes());
+        }
+
+        function fromLocalInput(value) {
+            return value ? new Date(value).toISOString() : null;
+        }
+
+        function apiKeyStatus(key) {
+            if (!key.enabled) {
+                return '<span class="api-key-status disabled">已停用</span>';
+            }
+            if (key.expires_at && new Date(key.expires_at) <= new Date()) {
+                return '<span class="api-key-status disabled">已过期</span>';
+            }
+            return '<span class="api-key-status">启用</span>';
+        }
+
+        function toggleAPIKeySettings(id) {
+            document.getElementById('apiKeySettings-' + id).classList.toggle('hidden');
+        }
+
+        function saveAPIKey(key, enabled) {
+            const prefix = 'key' + key.id;
+            const body = {
+                description: key.description,
+                enabled: enabled,
+                expires_at: key.expires_at || null,
+                scopes: key.scopes
+            };
+            const settings = document.getElementById('apiKeySettings-' + key.id);
+            if (!settings.classList.contains('hidden')) {
+                body.description = document.getElementById('keyDescription-' + key.id).value.trim();
+                body.expires_at = fromLocalInput(document.getElementById('keyExpiresAt-' + key.id).value);
+                body.scopes = checkedScopes(prefix);
+            }
+            sendQuestion('PUT', '/admin/apikeys/' + key.id, body)
+                .then(() => loadAPIKeys())
+                .catch(err => alert('保存失败: ' + err.message));
+        }
+
+        let apiKeyRoutingOptions = { platforms: [], defaultPlatform: '', templates: [] };
+
+        // 路由以 平台:模型 的形式显示和编辑，多个平台用逗号分隔，模型名中可以包含冒号
+        function formatRoutes(providers) {
+            return (providers || []).map(r => r.model ? r.platform + ':' + r.model : r.platform).join(', ');
+        }
+
+        function parseRoutes(text) {
+            return text.split(',').map(s => s.trim()).filter(Boolean).map(part => {
+                const i = part.indexOf(':');
+                return i < 0 ? { platform: part } : { platform: part.slice(0, i).trim(), model: part.slice(i + 1).trim() };
+            });
+        }
+
+        function describeRouting(key) {
+            const routes = (key.providers || []).length ? formatRoutes(key.providers).split(', ').join(' → ') : '全局配置（' + apiKeyRoutingOptions.defaultPlatform + '）';
+            return routes + ' · 提示词模板: ' + (key.prompt_template || '默认');
+        }
+
+        function toggleAPIKeyRouting(id) {
+            document.getElementById('apiKeyRouting-' + id).classList.toggle('hidden');
+        }
+
+        function saveAPIKeyRouting(id) {
+            const body = {
+                providers: parseRoutes(document.getElementById('keyProviders-' + id).value),
+                prompt_template: document.getElementById('keyTemplate-' + id).value
+            };
+            sendQuestion('PUT', '/admin/apikeys/' + id + '/routing', body)
+                .then(() => loadAPIKeys())
+                .catch(err => alert('保存失败: ' + err.message));
+        }
+
+        function rotateAPIKey(id) {
+            const hours = prompt('生成新的密钥，旧密钥在宽限期内仍然有效。请输入宽限时长（小时，0表示立即失效）', '24');
+            if (hours === null) {
+                return;
+            }
+            const graceHours = parseFloat(hours);
+            if (isNaN(graceHours) || graceHours < 0) {
+                alert('宽限时长必须是非负数');
+                return;
+            }
+            sendQuestion('POST', '/admin/apikeys/' + id + '/rotate', { grace_hours: graceHours })
+                .then(data => {
+                    document.getElementById('apiKeyCreatedValue').textContent = data.data.api_key;
+                    document.getElementById('apiKeyCreated').classList.remove('hidden');
+                    loadAPIKeys();
+                })
+                .catch(err => alert('轮换失败: ' + err.message));
+        }
+
+        function toggleAPIKeyLimits(id) {
+            document.getElementById('apiKeyLimits-' + id).classList.toggle('hidden');
+        }
+
+        function saveAPIKeyLimits(id) {
+            const limits = {};
+            for (const field of apiKeyLimitFields) {
+                const input = document.getElementById('limit-' + field[0] + '-' + id).value || '0';
+                const value = field[2] ? parseFloat(input) : parseInt(input, 10);
+                if (isNaN(value) || value < 0) {
+                    alert(field[1] + '限制必须是非负' + (field[2] ? '数' : '整数'));
+                    return;
+                }
+                limits[field[0]] = value;
+            }
+            sendQuestion('PUT', '/admin/apikeys/' + id + '/limits', limits)
+                .then(() => loadAPIKeys())
+                .catch(err => alert('保存失败: ' + err.message));
+        }
+
+        // AI调用费用，未选择日期时统计最近30天
+        function loadCostReport() {
+            const error = document.getElementById('costError');
+            const params = new URLSearchParams();
+            const from = document.getElementById('costFrom').value;
+            const to = document.getElementById('costTo').value;
+            if (from) {
+                params.append('from', from);
+            }
+            if (to) {
+                params.append('to', to);
+            }
+            error.classList.add('hidden');
+
+            fetch('/admin/costs?' + params.toString())
+                .then(response => response.json())
+                .then(data => {
+                    if (data.error) {
+                        throw new Error(data.error);
+                    }
+                    const report = data.data;
+                    const total = report.total;
+                    document.getElementById('costSummary').textContent = report.from + ' 至 ' + report.to +
+                        ' · AI调用 ' + total.requests + ' 次 · Token ' + (total.prompt_tokens + total.completion_tokens) +
+                        ' · 费用 ' + formatCost(total.cost);
+                    renderCostRows('costByKeyBody', report.by_key, r => r.description || (r.api_key_id === 0 ? '管理后台重新生成' : '#' + r.api_key_id));
+                    renderCostRows('costByProviderBody', report.by_provider, r => r.platform + ' / ' + r.model);
+                })
+                .catch(err => {
+                    error.textContent = '加载费用统计失败: ' + err.message;
+                    error.classList.remove('hidden');
+                });
+        }
+
+        function renderCostRows(tbodyId, rows, label) {
+            const tbody = document.getElementById(tbodyId);
+            tbody.innerHTML = '';
+            if (!rows || rows.length === 0) {
+                const cell = tbody.insertRow().insertCell(0);
+                cell.colSpan = 5;
+                cell.textContent = '暂无记录';
+                cell.style.textAlign = 'center';
+                return;
+            }
+            rows.forEach(r => {
+                const row = tbody.insertRow();
+                row.insertCell(0).textContent = label(r);
+                row.insertCell(1).textContent = r.requests;
+                row.insertCell(2).textContent = r.prompt_tokens;
+                row.insertCell(3).textContent = r.completion_tokens;
+                row.insertCell(4).textContent = formatCost(r.cost);
+            });
+        }
+
         function renderAPIKeys(apiKeys) {
             const list = document.getElementById('apiKeysList');
             list.innerHTML = '';
             
             apiKeys.forEach(key => {
+                loadedAPIKeys[key.id] = key;
                 const keyElement = document.createElement('div');
                 keyElement.className = 'api-key-item';
                 
@@ -1175,15 +3216,50 @@ func AdminPage(c *gin.Context) {
                 
                 keyElement.innerHTML = 
                     '<div>' +
-                        '<div class="api-key-description">' + escapeHtml(key.description || '未命名密钥') + '</div>' +
-                        '<div class="api-key-value">' + key.api_key + '</div>' +
+                        '<div class="api-key-description">' + escapeHtml(key.description || '未命名密钥') + ' ' + apiKeyStatus(key) + '</div>' +
+                        '<div class="api-key-value">' + escapeHtml(key.prefix) + '…</div>' +
                         '<div class="api-key-stats">' +
                             '<div class="api-key-stat">调用次数: <span class="api-key-call-count">' + (key.call_count || 0) + '</span></div>' +
                             '<div class="api-key-stat">最后使用: <span class="api-key-date">' + lastUsedText + '</span></div>' +
                         '</div>' +
+                        (key.previous_prefix ? '<div class="api-key-date">旧密钥 ' + escapeHtml(key.previous_prefix) + '… 有效至 ' + new Date(key.previous_expires_at).toLocaleString('zh-CN') + '</div>' : '') +
+                        '<div class="api-key-date">权限: ' + (key.scopes || []).map(s => apiKeyScopeNames[s] || s).join('、') +
+                            ' · 过期时间: ' + (key.expires_at ? new Date(key.expires_at).toLocaleString('zh-CN') : '永不过期') + '</div>' +
+                        '<div class="api-key-date">调用限制 ' + formatAPIKeyLimits(key) + '</div>' +
+                        '<div class="api-key-date">AI路由: ' + escapeHtml(describeRouting(key)) + '</div>' +
                         '<div class="api-key-date">创建时间: ' + new Date(key.created_at).toLocaleString('zh-CN') + '</div>' +
+                        '<div id="apiKeyLimits-' + key.id + '" class="api-key-limits hidden">' +
+                            apiKeyLimitFields.map(field =>
+                                '<label>' + field[1] + ' <input type="number" min="0"' + (field[2] ? ' step="0.01"' : '') + ' id="limit-' + field[0] + '-' + key.id + '" value="' + (key[field[0]] || 0) + '"></label>'
+                            ).join('') +
+                            '<button onclick="saveAPIKeyLimits(' + key.id + ')">保存</button>' +
+                        '</div>' +
+                        '<div id="apiKeyRouting-' + key.id + '" class="api-key-limits hidden">' +
+                            '<label>平台 <input type="text" size="40" id="keyProviders-' + key.id + '" value="' + escapeHtml(formatRoutes(key.providers)) + '" placeholder="留空使用全局配置，例如 deepseek:deepseek-chat, ollama:qwen2:7b"></label>' +
+                            '<label>提示词模板 <select id="keyTemplate-' + key.id + '">' +
+                                '<option value="">默认</option>' +
+                                apiKeyRoutingOptions.templates.map(name =>
+                                    '<option value="' + escapeHtml(name) + '"' + (name === key.prompt_template ? ' selected' : '') + '>' + escapeHtml(name) + '</option>'
+                                ).join('') +
+                            '</select></label>' +
+                            '<button onclick="saveAPIKeyRouting(' + key.id + ')">保存</button>' +
+                            '<div class="api-key-date">可用平台: ' + apiKeyRoutingOptions.platforms.join('、') + '；前一个平台调用失败时依次使用下一个</div>' +
+                        '</div>' +
+                        '<div id="apiKeySettings-' + key.id + '" class="hidden">' +
+                            '<div class="api-key-limits">' +
+                                '<label>描述 <input type="text" id="keyDescription-' + key.id + '" value="' + escapeHtml(key.description || '') + '"></label>' +
+                                '<label>过期时间 <input type="datetime-local" id="keyExpiresAt-' + key.id + '" value="' + toLocalInput(key.expires_at) + '"></label>' +
+                            '</div>' +
+                            '<div class="api-key-limits">' + renderScopeCheckboxes('key' + key.id, key.scopes || []) + '</div>' +
+                            '<button onclick="saveAPIKey(loadedAPIKeys[' + key.id + '], ' + key.enabled + ')">保存</button>' +
+                        '</div>' +
                     '</div>' +
                     '<div>' +
+                        '<button class="btn" onclick="toggleAPIKeySettings(' + key.id + ')">设置</button> ' +
+                        '<button class="btn" onclick="saveAPIKey(loadedAPIKeys[' + key.id + '], ' + !key.enabled + ')">' + (key.enabled ? '停用' : '启用') + '</button> ' +
+                        '<button class="btn" onclick="toggleAPIKeyLimits(' + key.id + ')">调用限制</button> ' +
+                        '<button class="btn" onclick="toggleAPIKeyRouting(' + key.id + ')">AI路由</button> ' +
+                        '<button class="btn" onclick="rotateAPIKey(' + key.id + ')">轮换</button> ' +
                         '<button class="btn btn-danger" onclick="deleteAPIKey(' + key.id + ')">删除</button>' +
                     '</div>';
                 list.appendChild(keyElement);
@@ -1208,13 +3284,20 @@ func AdminPage(c *gin.Context) {
                 headers: {
                     'Content-Type': 'application/json'
                 },
-                body: JSON.stringify({ description: description })
+                body: JSON.stringify({
+                    description: description,
+                    scopes: checkedScopes('new'),
+                    expires_at: fromLocalInput(document.getElementById('apiKeyExpiresAt').value)
+                })
             })
             .then(response => response.json())
             .then(data => {
                 loading.classList.add('hidden');
                 if (data.message) {
                     document.getElementById('apiKeyDescription').value = '';
+                    document.getElementById('apiKeyExpiresAt').value = '';
+                    document.getElementById('apiKeyCreatedValue').textContent = data.data.api_key;
+                    document.getElementById('apiKeyCreated').classList.remove('hidden');
                     loadAPIKeys();
                 } else if (data.error) {
                     error.textContent = data.error;
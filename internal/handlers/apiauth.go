@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"ai-ocs/internal/database"
+	"ai-ocs/internal/models"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyIDKey 验证通过的API密钥ID在请求上下文中的键名
+const apiKeyIDKey = "api_key_id"
+
+// apiKeyContextKey 验证通过的API密钥（含调用限制）在请求上下文中的键名
+const apiKeyContextKey = "api_key"
+
+// apiAuth API密钥读取方式，由 ConfigureAPIAuth 设置
+var apiAuth models.APIAuthConfig
+
+// ConfigureAPIAuth 设置API密钥读取方式
+func ConfigureAPIAuth(config models.APIAuthConfig) {
+	apiAuth = config
+}
+
+// errQueryKeyDisabled 未开启 api_auth.allow_query_key 时在查询参数中传递密钥的提示
+const errQueryKeyDisabled = "不支持在查询参数中传递API密钥，请使用 Authorization: Bearer 或 X-API-Key 请求头"
+
+// requestAPIKey 依次从 Authorization: Bearer 请求头、X-API-Key 请求头、JSON请求体的 api_key 字段（withBody 为 true 时）
+// 和 api-key 查询参数（开启 allow_query_key 时）中读取API密钥。queryRejected 为 true 表示密钥只出现在查询参数中，但配置不允许使用查询参数
+func requestAPIKey(c *gin.Context, withBody bool) (apiKey string, queryRejected bool) {
+	if auth := c.GetHeader("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:]), false
+	}
+	if apiKey := strings.TrimSpace(c.GetHeader("X-API-Key")); apiKey != "" {
+		return apiKey, false
+	}
+	if withBody {
+		if apiKey := bodyAPIKey(c); apiKey != "" {
+			return apiKey, false
+		}
+	}
+
+	apiKey = c.Query("api-key")
+	if apiKey != "" && !apiAuth.AllowQueryKey {
+		return "", true
+	}
+	return apiKey, false
+}
+
+// maxKeyBodySize 在请求体中查找API密钥时最多读取的字节数，请求体在验证密钥前读取，需限制大小
+const maxKeyBodySize = 1 << 20
+
+// bodyAPIKey 读取JSON请求体中的 api_key（或 api-key）字段，读取后恢复请求体供后续处理使用。
+// 请求体超过 maxKeyBodySize 时中止请求并返回 413
+func bodyAPIKey(c *gin.Context) string {
+	if c.Request.Body == nil || c.ContentType() != "application/json" {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxKeyBodySize+1))
+	if len(body) > maxKeyBodySize {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"code": 1, "msg": "请求体过大"})
+		return ""
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	var fields struct {
+		APIKey       string `json:"api_key"`
+		LegacyAPIKey string `json:"api-key"`
+	}
+	// 请求体格式错误时忽略，由后续处理返回参数错误
+	if json.Unmarshal(body, &fields) != nil {
+		return ""
+	}
+	if fields.APIKey != "" {
+		return strings.TrimSpace(fields.APIKey)
+	}
+	return strings.TrimSpace(fields.LegacyAPIKey)
+}
+
+// RequireAPIKey 中间件，验证请求头、JSON请求体或 api-key 查询参数（开启 allow_query_key 时）中的API密钥，并把密钥保存到请求上下文，
+// 所有公开接口共用
+func RequireAPIKey(c *gin.Context) {
+	apiKey, queryRejected := requestAPIKey(c, true)
+	if c.IsAborted() {
+		return
+	}
+	if queryRejected {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": errQueryKeyDisabled})
+		return
+	}
+
+	key, status, msg := authenticateAPIKey(apiKey)
+	if key == nil {
+		c.AbortWithStatusJSON(status, gin.H{"code": 1, "msg": msg})
+		return
+	}
+
+	// 使用轮换前的旧密钥时提示调用方尽快更换
+	if key.UsingPrevious && key.PreviousExpiresAt != nil {
+		c.Header("X-API-Key-Deprecated", key.PreviousExpiresAt.Format(time.RFC3339))
+	}
+
+	c.Set(apiKeyIDKey, key.ID)
+	c.Set(apiKeyContextKey, key)
+
+	c.Next()
+}
+
+// authenticateAPIKey 验证API密钥存在、已启用且未过期，失败时返回 nil 及对应的状态码和提示
+func authenticateAPIKey(apiKey string) (*models.APIKey, int, string) {
+	if apiKey == "" {
+		return nil, http.StatusUnauthorized, "API密钥不能为空"
+	}
+
+	key, err := database.FindAPIKey(apiKey)
+	if err != nil {
+		return nil, http.StatusInternalServerError, "验证API密钥时出错"
+	}
+	if key == nil {
+		return nil, http.StatusUnauthorized, "无效的API密钥"
+	}
+	if !key.Enabled {
+		return nil, http.StatusForbidden, "API密钥已停用"
+	}
+	if key.Expired(time.Now()) {
+		return nil, http.StatusForbidden, "API密钥已过期"
+	}
+	return key, http.StatusOK, ""
+}
+
+// RequireScope 中间件，要求当前API密钥拥有 scopes 中的任意一项权限，需在 RequireAPIKey 之后使用
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := currentAPIKey(c)
+		if key != nil {
+			for _, scope := range scopes {
+				if key.HasScope(scope) {
+					c.Next()
+					return
+				}
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"code": 1, "msg": "API密钥无权访问该接口"})
+	}
+}
+
+// currentAPIKeyID 获取当前请求使用的API密钥ID，未验证时返回0
+func currentAPIKeyID(c *gin.Context) int64 {
+	return c.GetInt64(apiKeyIDKey)
+}
+
+// currentAPIKey 获取当前请求使用的API密钥，未验证时返回 nil
+func currentAPIKey(c *gin.Context) *models.APIKey {
+	if v, ok := c.Get(apiKeyContextKey); ok {
+		return v.(*models.APIKey)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"ai-ocs/internal/bank"
+	"ai-ocs/internal/database"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImportQuestions 上传CSV、JSON Lines或XLSX文件批量导入题库
+// 表单字段：file 文件，format 格式（可选，默认按扩展名），sheet 工作表，
+// question/options/type/answer 列映射，dry_run 只预览，on_duplicate 重复处理方式（skip 或 update）
+func ImportQuestions(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请选择要导入的文件"})
+		return
+	}
+
+	format := c.PostForm("format")
+	if format == "" {
+		if format, err = bank.DetectFormat(fileHeader.Filename); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无法读取上传的文件: " + err.Error()})
+		return
+	}
+	defer file.Close()
+
+	dryRun := c.PostForm("dry_run") == "1" || c.PostForm("dry_run") == "true"
+	report, err := bank.Import(file, bank.ImportOptions{
+		Format: format,
+		Sheet:  c.PostForm("sheet"),
+		Mapping: bank.Mapping{
+			Question: c.PostForm("question"),
+			Options:  c.PostForm("options"),
+			Type:     c.PostForm("type"),
+			Answer:   c.PostForm("answer"),
+		},
+		DryRun:      dryRun,
+		OnDuplicate: c.PostForm("on_duplicate"),
+		Operator:    currentAdmin(c),
+		Reason:      "导入自 " + fileHeader.Filename,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "导入失败: " + err.Error()})
+		return
+	}
+
+	message := "导入完成"
+	if dryRun {
+		message = "预览完成，未写入题库"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"data":    report,
+	})
+}
+
+// ExportQuestions 按筛选条件导出题库，边查询边写出响应，不会把整个题库读入内存
+// 参数：format 格式（csv、jsonl、xlsx、md），以及 keyword、type、source、from、to 筛选条件
+func ExportQuestions(c *gin.Context) {
+	format := c.DefaultQuery("format", bank.FormatCSV)
+	contentType, ok := bank.ContentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的导出格式: " + format})
+		return
+	}
+
+	filter, err := questionFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, bank.ExportFileName(format)))
+	c.Status(http.StatusOK)
+
+	count, err := bank.Export(c.Writer, format, filter)
+	if err != nil {
+		// 响应已经开始写出，只能记录日志
+		log.Printf("导出题库失败（已写出 %d 条）: %v", count, err)
+	}
+}
+
+// questionFilter 从请求参数解析题库筛选条件，日期格式为 YYYY-MM-DD，结束日期包含当天
+func questionFilter(c *gin.Context) (database.QuestionFilter, error) {
+	filter := database.QuestionFilter{
+		Keyword: c.Query("keyword"),
+		Type:    c.Query("type"),
+		Source:  c.Query("source"),
+	}
+
+	var err error
+	filter.From, filter.To, err = database.ParseDateRange(c.Query("from"), c.Query("to"))
+	return filter, err
+}
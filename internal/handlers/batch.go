@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"ai-ocs/internal/database"
+	"ai-ocs/internal/models"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BatchQueryRequest POST /api/query/batch 的请求参数
+type BatchQueryRequest struct {
+	Questions []QueryRequest `json:"questions" binding:"required,min=1"`
+	Meta      bool           `json:"meta"` // 是否在每道题目的结果中附带答案来源等元数据
+}
+
+// batchKey 题目、选项和题型都相同的题目在一次批量查询中只查询一次
+type batchKey struct {
+	title        string
+	options      string
+	questionType string
+}
+
+// SearchAnswers 批量查询答案，需在 RequireAPIKey 和 RateLimit 之后使用。
+// 每道题目都计入调用次数；题库中已有答案的题目直接返回，其余题目按 batch.concurrency 限制同时调用AI的数量。
+// 结果按请求中的顺序返回，每项的 code、msg、data 与单题查询的响应相同，index 为题目在请求中的序号
+func SearchAnswers(config *models.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req BatchQueryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "请求参数错误: " + err.Error()})
+			return
+		}
+		if len(req.Questions) > config.Batch.MaxQuestions {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": fmt.Sprintf("一次最多查询 %d 道题目", config.Batch.MaxQuestions)})
+			return
+		}
+
+		now := time.Now()
+		if state := chargeBatchQuota(c, len(req.Questions), now); state != nil {
+			rejectRateLimited(c, *state, now)
+			return
+		}
+
+		if err := database.AddAPIKeyUsage(currentAPIKeyID(c), int64(len(req.Questions))); err != nil {
+			log.Printf("增加API密钥调用次数失败: %v", err)
+		}
+
+		withMeta := req.Meta || metaRequested(c)
+		// 设置了费用上限的密钥在前一次调用的费用计入后才能判断是否超出，逐题调用AI
+		concurrency := config.Batch.Concurrency
+		if serializeAI(c) {
+			concurrency = 1
+		}
+		slots := make(chan struct{}, concurrency)
+
+		// 重复的题目只查询一次，结果复制给相同的题目
+		queries := make([]questionQuery, 0, len(req.Questions))
+		first := make(map[batchKey]int)
+		same := make([]int, len(req.Questions))
+		for i := range req.Questions {
+			q := req.Questions[i].query(withMeta)
+			q.skipHeaders = true
+			q.batch = true
+			q.aiSlots = slots
+
+			k := batchKey{q.title, q.options, q.questionType}
+			if j, ok := first[k]; ok {
+				same[i] = j
+				continue
+			}
+			first[k] = len(queries)
+			same[i] = len(queries)
+			queries = append(queries, q)
+		}
+
+		answers := make([]gin.H, len(queries))
+		var wg sync.WaitGroup
+		for i := range queries {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, answers[i] = answerQuestion(c, config, queries[i])
+			}(i)
+		}
+		wg.Wait()
+
+		results := make([]gin.H, len(req.Questions))
+		for i, j := range same {
+			result := gin.H{"index": i}
+			for k, v := range answers[j] {
+				result[k] = v
+			}
+			results[i] = result
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"code": 0,
+			"msg":  "获取成功",
+			"data": results,
+		})
+	}
+}
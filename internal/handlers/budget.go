@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"ai-ocs/internal/budget"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetBudgetStatus 返回全局每日费用预算的状态
+func GetBudgetStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"data": budget.GetStatus(),
+	})
+}
+
+// TripBudget 手动熔断，停止调用AI直到手动恢复
+func TripBudget(c *gin.Context) {
+	budget.Trip()
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已熔断",
+		"data":    budget.GetStatus(),
+	})
+}
+
+// ResumeBudget 恢复AI调用
+func ResumeBudget(c *gin.Context) {
+	budget.Resume()
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已恢复AI调用",
+		"data":    budget.GetStatus(),
+	})
+}
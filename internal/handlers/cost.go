@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"ai-ocs/internal/database"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCostReportDays 费用统计最多查询的天数
+const maxCostReportDays = 366
+
+// GetCostReport 按API密钥和平台统计一段时间内的AI调用费用，日期格式为 YYYY-MM-DD，默认最近30天
+func GetCostReport(c *gin.Context) {
+	now := time.Now()
+	to := now
+	from := now.AddDate(0, 0, -29)
+
+	var err error
+	if v := c.Query("from"); v != "" {
+		from, err = time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的开始日期"})
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		to, err = time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的结束日期"})
+			return
+		}
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "结束日期不能早于开始日期"})
+		return
+	}
+	if to.Sub(from) > maxCostReportDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "最多查询一年的费用"})
+		return
+	}
+
+	report, err := database.GetCostReport(from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "无法获取费用统计: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": report,
+	})
+}
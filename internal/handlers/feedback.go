@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"ai-ocs/internal/database"
+	"ai-ocs/internal/models"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FeedbackRequest 调用方反馈答案是否正确的请求结构，question_id 和 title 至少提供一个
+type FeedbackRequest struct {
+	QuestionID      int64  `json:"question_id"`
+	Title           string `json:"title"`
+	Correct         *bool  `json:"correct" binding:"required"`
+	SuggestedAnswer string `json:"suggested_answer"`
+}
+
+// ResolveFeedbackRequest 处理反馈的请求结构，answer 为空时保留当前答案
+type ResolveFeedbackRequest struct {
+	Answer string `json:"answer"`
+	Reason string `json:"reason"`
+}
+
+// SubmitFeedback 记录调用方对题库答案的反馈，错误票达到阈值时按配置自动处理答案
+func SubmitFeedback(config *models.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req FeedbackRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "请求参数错误: " + err.Error()})
+			return
+		}
+
+		questionID := req.QuestionID
+		if questionID == 0 {
+			title := strings.TrimSpace(req.Title)
+			if title == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "题目不能为空"})
+				return
+			}
+			qa, err := database.GetAnswer(title)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "查询题目失败"})
+				return
+			}
+			if qa == nil {
+				c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "题目不存在"})
+				return
+			}
+			questionID = qa.ID
+		}
+
+		fb := &models.AnswerFeedback{
+			QuestionID:      questionID,
+			APIKeyID:        currentAPIKeyID(c),
+			Correct:         *req.Correct,
+			SuggestedAnswer: strings.TrimSpace(req.SuggestedAnswer),
+			ClientIP:        c.ClientIP(),
+		}
+		result, err := database.RecordFeedback(fb, config.Feedback)
+		switch {
+		case err == sql.ErrNoRows:
+			c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "题目不存在"})
+			return
+		case err == database.ErrNoAnswer:
+			c.JSON(http.StatusConflict, gin.H{"code": 1, "msg": err.Error()})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "保存反馈失败: " + err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"code": 0,
+			"msg":  "感谢反馈",
+			"data": result,
+		})
+	}
+}
+
+// GetFeedbackQueue 分页获取按题目汇总的反馈，resolved=1 时返回已处理的反馈
+func GetFeedbackQueue(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	offset := (page - 1) * limit
+	resolved := c.Query("resolved") == "1" || c.Query("resolved") == "true"
+
+	items, total, err := database.GetFeedbackQueue(resolved, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "无法获取反馈: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  items,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// ResolveFeedback 处理一道题的待处理反馈：采用新的答案，或确认当前答案正确
+func ResolveFeedback(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的题目ID"})
+		return
+	}
+
+	var req ResolveFeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	answer := strings.TrimSpace(req.Answer)
+	reason := req.Reason
+	if reason == "" {
+		reason = "处理用户反馈"
+	}
+	err = database.ResolveFeedback(id, answer, adminSource(c, reason))
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "题目不存在"})
+		return
+	case err == database.ErrNoAnswer:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "答案已作废，请填写新的答案"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "无法处理反馈: " + err.Error(),
+		})
+		return
+	}
+
+	message := "已确认当前答案"
+	if answer != "" {
+		message = "已更新答案"
+	}
+	updated, _ := database.GetQuestion(id)
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"data":    updated,
+	})
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"ai-ocs/internal/database"
+	"ai-ocs/internal/models"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QuestionRequest 新增或修改题目的请求结构
+type QuestionRequest struct {
+	Question string  `json:"question" binding:"required"`
+	Answer   string  `json:"answer" binding:"required"`
+	Options  *string `json:"options"`
+	Type     *string `json:"type"`
+	Reason   string  `json:"reason"`
+}
+
+// manualConfidence 管理员手动录入的答案视为已确认
+var manualConfidence = 1.0
+
+// toQuestion 校验请求并转换为题目记录，选项和题型为空字符串时视为未填写
+func (r *QuestionRequest) toQuestion() (*models.QuestionAnswer, string) {
+	qa := &models.QuestionAnswer{
+		Question: strings.TrimSpace(r.Question),
+		Answer:   strings.TrimSpace(r.Answer),
+	}
+	if qa.Question == "" {
+		return nil, "题目不能为空"
+	}
+	if qa.Answer == "" {
+		return nil, "答案不能为空"
+	}
+	if r.Options != nil {
+		if v := strings.TrimSpace(*r.Options); v != "" {
+			qa.Options = &v
+		}
+	}
+	if r.Type != nil {
+		if v := strings.TrimSpace(*r.Type); v != "" {
+			qa.Type = &v
+		}
+	}
+	return qa, ""
+}
+
+// adminSource 管理员操作的答案来源
+func adminSource(c *gin.Context, reason string) models.AnswerSource {
+	return models.AnswerSource{
+		Source:     models.AnswerSourceAdmin,
+		Operator:   currentAdmin(c),
+		Reason:     reason,
+		Confidence: &manualConfidence,
+	}
+}
+
+// CreateQuestion 手动添加题目
+func CreateQuestion(c *gin.Context) {
+	var req QuestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	qa, msg := req.toQuestion()
+	if qa == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	reason := req.Reason
+	if reason == "" {
+		reason = "手动添加"
+	}
+	id, err := database.CreateQuestion(qa, adminSource(c, reason))
+	if err == database.ErrQuestionExists {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "无法添加题目: " + err.Error(),
+		})
+		return
+	}
+
+	created, _ := database.GetQuestion(id)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "题目添加成功",
+		"data":    created,
+	})
+}
+
+// UpdateQuestion 修改题目的题干、选项、题型和答案
+func UpdateQuestion(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的题目ID"})
+		return
+	}
+
+	var req QuestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	qa, msg := req.toQuestion()
+	if qa == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	qa.ID = id
+
+	reason := req.Reason
+	if reason == "" {
+		reason = "手动修改"
+	}
+	err = database.UpdateQuestion(qa, adminSource(c, reason))
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "题目不存在"})
+		return
+	case err == database.ErrQuestionExists:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "无法修改题目: " + err.Error(),
+		})
+		return
+	}
+
+	updated, _ := database.GetQuestion(id)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "题目修改成功",
+		"data":    updated,
+	})
+}
+
+// DeleteQuestion 删除单个题目
+func DeleteQuestion(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的题目ID"})
+		return
+	}
+
+	err = database.DeleteQuestion(id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "题目不存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "无法删除题目: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "题目删除成功",
+	})
+}
+
+// DeleteQuestions 按 keyword、type、source、from、to 条件批量删除题目，dry_run=1 时只返回匹配的数量
+func DeleteQuestions(c *gin.Context) {
+	filter, err := questionFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter == (database.QuestionFilter{}) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "批量删除必须指定至少一个筛选条件"})
+		return
+	}
+
+	if c.Query("dry_run") == "1" || c.Query("dry_run") == "true" {
+		count, err := database.CountQuestions(filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "无法统计题目: " + err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"data": gin.H{"count": count},
+		})
+		return
+	}
+
+	deleted, err := database.DeleteQuestions(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "批量删除失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "批量删除成功",
+		"data":    gin.H{"count": deleted},
+	})
+}
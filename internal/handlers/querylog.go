@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"ai-ocs/internal/database"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetQueryLogs 分页查询请求日志，支持按密钥、缓存命中、平台、状态、关键词和日期筛选
+func GetQueryLogs(c *gin.Context) {
+	// 获取分页参数
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	offset := (page - 1) * limit
+
+	// 解析筛选条件
+	filter := database.QueryLogFilter{
+		Platform: c.Query("platform"),
+		Status:   c.Query("status"),
+		Keyword:  c.Query("keyword"),
+	}
+
+	if v := c.Query("api_key_id"); v != "" {
+		filter.APIKeyID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的API密钥ID"})
+			return
+		}
+	}
+
+	if v := c.Query("cache_hit"); v != "" {
+		hit, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的cache_hit参数"})
+			return
+		}
+		filter.CacheHit = &hit
+	}
+
+	// 日期格式为 YYYY-MM-DD，结束日期包含当天
+	filter.From, filter.To, err = database.ParseDateRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	logs, total, err := database.GetQueryLogs(filter, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "无法获取查询日志: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  logs,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
@@ -2,105 +2,344 @@ package handlers
 
 import (
 	"ai-ocs/internal/ai"
+	"ai-ocs/internal/budget"
 	"ai-ocs/internal/database"
 	"ai-ocs/internal/models"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// SearchAnswer 处理查询答案的请求
+// QueryRequest POST /api/query 的请求参数
+type QueryRequest struct {
+	Question string                `json:"question"`
+	Options  []string              `json:"options"`
+	Type     string                `json:"type"`
+	Meta     bool                  `json:"meta"`     // 是否在响应中附带答案来源等元数据，同 GET 请求的 meta 参数
+	Metadata *models.QueryMetadata `json:"metadata"` // 课程、网课平台、图片地址等题目信息，只记录到查询日志
+}
+
+// questionQuery 一次题目查询的参数
+type questionQuery struct {
+	title        string
+	options      string
+	questionType string
+	metadata     *models.QueryMetadata
+	withMeta     bool
+
+	// 超出限制时不设置响应头：批量查询中的题目，或流式查询已开始输出
+	skipHeaders bool
+	// 批量查询中的题目，调用次数已由批量查询统一统计
+	batch bool
+	// 批量查询时限制同时调用AI的题目数，未命中题库时先获取名额
+	aiSlots chan struct{}
+	// 流式查询的进度回调，依次收到 cache、provider、reasoning、token 事件
+	progress func(event string, data gin.H)
+}
+
+// emit 流式查询时发送进度事件
+func (q *questionQuery) emit(event string, data gin.H) {
+	if q.progress != nil {
+		q.progress(event, data)
+	}
+}
+
+// streamHandler 流式查询时将平台、模型输出和推理模型的思考过程作为进度事件发送，非流式查询返回 nil。
+// 答案需审核后才能返回时 withTokens 为 false，只发送平台，不发送模型输出和思考过程
+func (q *questionQuery) streamHandler(withTokens bool) *ai.StreamHandler {
+	if q.progress == nil {
+		return nil
+	}
+	h := &ai.StreamHandler{
+		OnRoute: func(platform, model string) {
+			q.emit("provider", gin.H{"platform": platform, "model": model})
+		},
+	}
+	if withTokens {
+		h.OnToken = func(token string) {
+			q.emit("token", gin.H{"text": token})
+		}
+		h.OnReasoning = func(text string) {
+			q.emit("reasoning", gin.H{"text": text})
+		}
+	}
+	return h
+}
+
+// SearchAnswer 处理 GET 查询答案的请求，题目等参数放在查询字符串中，需在 RequireAPIKey 和 RateLimit 之后使用
 func SearchAnswer(config *models.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 获取查询参数
-		title := strings.TrimSpace(c.Query("title"))
-		options := c.Query("options")
-		questionType := c.Query("type")
-		apiKey := c.Query("api-key")
-
-		// 参数校验
-		if title == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "题目不能为空"})
+		c.JSON(answerQuestion(c, config, questionQuery{
+			title:        strings.TrimSpace(c.Query("title")),
+			options:      c.Query("options"),
+			questionType: c.Query("type"),
+			withMeta:     metaRequested(c),
+		}))
+	}
+}
+
+// SearchAnswerJSON 处理 POST 查询答案的请求，题目等参数放在 JSON 请求体中，避免长题目被截断或特殊字符转义出错。
+// 选项按行拼接后与 GET 请求的 options 参数同样处理，响应格式与 GET 请求相同
+func SearchAnswerJSON(config *models.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req QueryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "请求参数错误: " + err.Error()})
 			return
 		}
+		c.JSON(answerQuestion(c, config, req.query(metaRequested(c))))
+	}
+}
 
-		// 验证API密钥（现在是必须项）
-		if apiKey == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": "API密钥不能为空"})
-			return
+// query 转换为查询参数，去除空白选项
+func (req *QueryRequest) query(withMeta bool) questionQuery {
+	options := make([]string, 0, len(req.Options))
+	for _, o := range req.Options {
+		if o = strings.TrimSpace(o); o != "" {
+			options = append(options, o)
 		}
-		
-		valid, err := database.ValidateAPIKey(apiKey)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "验证API密钥时出错"})
-			return
+	}
+	return questionQuery{
+		title:        strings.TrimSpace(req.Question),
+		options:      strings.Join(options, "\n"),
+		questionType: req.Type,
+		metadata:     req.Metadata,
+		withMeta:     withMeta || req.Meta,
+	}
+}
+
+// metaRequested 查询参数 meta 为 1 或 true 时在响应中附带答案来源等元数据
+func metaRequested(c *gin.Context) bool {
+	return c.Query("meta") == "1" || c.Query("meta") == "true"
+}
+
+// answerQuestion 查询题目答案：先查题库，未命中时按API密钥的路由调用AI，返回响应状态码和响应体，并记录查询日志
+func answerQuestion(c *gin.Context, config *models.Config, q questionQuery) (status int, resp gin.H) {
+	title, options, questionType := q.title, q.options, q.questionType
+
+	// 记录查询日志，在查询结束时异步写入
+	start := time.Now()
+	entry := &models.QueryLog{
+		Question:  title,
+		Options:   options,
+		Type:      questionType,
+		APIKeyID:  currentAPIKeyID(c),
+		Status:    "ok",
+		ClientIP:  c.ClientIP(),
+		Metadata:  q.metadata,
+		CreatedAt: start,
+	}
+	defer func() {
+		entry.LatencyMs = time.Since(start).Milliseconds()
+		if status >= http.StatusBadRequest && entry.Status == "ok" {
+			entry.Status = "error"
+			entry.Error = http.StatusText(status)
 		}
-		if !valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"code": 1, "msg": "无效的API密钥"})
-			return
+		if entry.Question != "" {
+			database.LogQuery(entry)
 		}
+	}()
 
-		// 增加API密钥调用次数统计
-		err = database.IncrementAPIKeyUsage(apiKey)
-		if err != nil {
+	// 参数校验
+	if title == "" {
+		return http.StatusBadRequest, gin.H{"code": 1, "msg": "题目不能为空"}
+	}
+
+	// 增加API密钥调用次数统计，批量查询已按题目数统一累加
+	if !q.batch {
+		if err := database.IncrementAPIKeyUsage(entry.APIKeyID); err != nil {
 			// 记录错误但不中断流程
 			log.Printf("增加API密钥调用次数失败: %v", err)
 		}
+	}
+
+	// 先从数据库查询答案
+	cached, err := database.GetAnswer(title)
+	if err != nil {
+		// 如果数据库查询出错，记录日志但不中断流程
+		log.Printf("数据库查询失败: %v", err)
+	}
+
+	// 部分题型只返回审核通过的答案
+	verifiedOnly := config.Review.VerifiedOnly(questionType)
 
-		// 先从数据库查询答案
-		answer, err := database.GetAnswer(title)
-		if err != nil {
-			// 如果数据库查询出错，记录日志但不中断流程
-			log.Printf("数据库查询失败: %v", err)
+	// 如果数据库中有答案（且未被驳回），直接返回
+	cacheHit := cached != nil && cached.Answer != "" && cached.ReviewStatus != models.ReviewRejected
+	q.emit("cache", gin.H{"hit": cacheHit})
+	if cacheHit {
+		entry.CacheHit = true
+		if verifiedOnly && cached.ReviewStatus != models.ReviewVerified {
+			entry.Status = "pending"
+			entry.Error = "答案尚未审核"
+			return http.StatusOK, gin.H{"code": 1, "msg": "答案尚未审核"}
 		}
+		if err := database.IncrementHitCount(cached.ID); err != nil {
+			log.Printf("更新命中次数失败: %v", err)
+		}
+		cached.HitCount++
 
-		// 如果数据库中有答案，直接返回
-		if answer != "" {
-			c.JSON(http.StatusOK, gin.H{
-				"code": 0,
-				"msg":  "获取成功",
-				"data": gin.H{
-					"data": answer,
-				},
-			})
-			return
+		data := gin.H{"data": cached.Answer}
+		if q.withMeta {
+			data["meta"] = answerMeta(cached, true)
 		}
+		return http.StatusOK, gin.H{
+			"code": 0,
+			"msg":  "获取成功",
+			"data": data,
+		}
+	}
 
-		// 如果数据库中没有答案，调用AI模型获取答案
-		answer, err = ai.QueryLargeModel(
-			title,
-			options,
-			questionType,
-			config.Platform,
-			config.APIKeys,
-			config.Models,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "AI模型调用失败: " + err.Error()})
-			return
+	// 只能查询已有答案的密钥不调用AI
+	if key := currentAPIKey(c); key != nil && !key.CanQueryAI() {
+		entry.Status = "miss"
+		return http.StatusOK, gin.H{"code": 1, "msg": "题库中暂无答案"}
+	}
+
+	// 批量查询中限制同时调用AI的题目数
+	if q.aiSlots != nil {
+		q.aiSlots <- struct{}{}
+		defer func() { <-q.aiSlots }()
+	}
+
+	// 未命中缓存的AI调用单独限制次数和费用，调用前先预留次数，并发的批量查询不会超出限制
+	now := time.Now()
+	reservation, state := reserveAIQuery(c, now)
+	if state != nil {
+		if !q.skipHeaders {
+			setRejectHeaders(c, *state, now)
 		}
+		return http.StatusTooManyRequests, gin.H{"code": 1, "msg": state.msg}
+	}
+	aiUsed := false
+	defer func() {
+		// 未发出AI调用或调用失败时归还预留的次数
+		reservation.finish(aiUsed)
+	}()
 
-		// 将答案存入数据库
-		err = database.SaveAnswer(title, answer)
-		if err != nil {
-			// 如果数据库保存出错，记录日志但不中断流程
-			log.Printf("数据库保存失败: %v", err)
+	// 如果数据库中没有答案，按API密钥的路由调用AI模型获取答案
+	routes, prompt := queryRoutes(c, config)
+
+	// 全局费用预算熔断后只返回已有答案，或改用配置的备用模型
+	if tripped, fallback := budget.Check(); tripped {
+		if fallback == nil {
+			entry.Status = "miss"
+			entry.Error = "今日AI费用预算已用完"
+			return http.StatusOK, gin.H{"code": 1, "msg": "题库中暂无答案"}
 		}
+		routes = []ai.Route{*fallback}
+	}
+	entry.Platform = routes[0].Platform
+	result, err := ai.QueryRoutesStream(
+		c.Request.Context(),
+		title,
+		options,
+		questionType,
+		routes,
+		prompt,
+		config.APIKeys,
+		config.Models,
+		q.streamHandler(!verifiedOnly),
+	)
+	if err != nil {
+		entry.Status = "error"
+		entry.Error = err.Error()
+		return http.StatusInternalServerError, gin.H{"code": 1, "msg": "AI模型调用失败: " + err.Error()}
+	}
+	aiUsed = true
+	recordAIQuery(c, config, result)
+	answer := result.Answer
+	entry.Platform = result.Platform
+	entry.Model = result.Model
+	entry.PromptTokens = result.Usage.PromptTokens
+	entry.CompletionTokens = result.Usage.CompletionTokens
 
-		// 去除可能的markdown代码块标记
-		answer = strings.TrimPrefix(answer, "```json")
-		answer = strings.TrimSuffix(answer, "```")
-		answer = strings.TrimSpace(answer)
+	// 重新生成的答案与已驳回的答案相同时，答案保持驳回状态，不保存也不返回
+	if cached != nil && cached.ReviewStatus == models.ReviewRejected && strings.TrimSpace(answer) == strings.TrimSpace(cached.Answer) {
+		entry.Status = "miss"
+		entry.Error = "重新生成的答案与已驳回的答案相同"
+		return http.StatusOK, gin.H{"code": 1, "msg": "题库中暂无答案"}
+	}
 
-		// 返回结果
-		c.JSON(http.StatusOK, gin.H{
-			"code": 0,
-			"msg":  "获取成功",
-			"data": gin.H{
-				"data": answer,
-			},
-		})
+	// 将答案存入数据库
+	source := models.AnswerSource{
+		Source:           models.AnswerSourceModel,
+		Platform:         result.Platform,
+		Model:            result.Model,
+		PromptTokens:     result.Usage.PromptTokens,
+		CompletionTokens: result.Usage.CompletionTokens,
+	}
+	err = database.SaveAnswer(title, answer, source)
+	if err != nil {
+		// 如果数据库保存出错，记录日志但不中断流程
+		log.Printf("数据库保存失败: %v", err)
+	}
+
+	// 新生成的答案进入审核队列，审核通过前不返回
+	if verifiedOnly {
+		entry.Status = "pending"
+		entry.Error = "答案尚未审核"
+		return http.StatusOK, gin.H{"code": 1, "msg": "答案尚未审核"}
+	}
+
+	// 去除可能的markdown代码块标记
+	answer = strings.TrimPrefix(answer, "```json")
+	answer = strings.TrimSuffix(answer, "```")
+	answer = strings.TrimSpace(answer)
+
+	// 返回结果
+	data := gin.H{"data": answer}
+	if q.withMeta {
+		data["meta"] = answerMeta(&models.QuestionAnswer{
+			Source:           source.Source,
+			Platform:         source.Platform,
+			Model:            source.Model,
+			PromptTokens:     source.PromptTokens,
+			CompletionTokens: source.CompletionTokens,
+			ReviewStatus:     models.ReviewUnverified,
+		}, false)
+	}
+	return http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "获取成功",
+		"data": data,
+	}
+}
+
+// queryRoutes 获取当前API密钥未命中缓存时使用的平台和提示词模板，密钥未配置时使用全局配置
+func queryRoutes(c *gin.Context, config *models.Config) ([]ai.Route, string) {
+	key := currentAPIKey(c)
+	if key == nil {
+		return []ai.Route{{Platform: config.Platform}}, config.PromptTemplate("")
+	}
+
+	routes := make([]ai.Route, 0, len(key.Providers))
+	for _, r := range key.Providers {
+		routes = append(routes, ai.Route{Platform: r.Platform, Model: r.Model})
+	}
+	if len(routes) == 0 {
+		routes = append(routes, ai.Route{Platform: config.Platform})
+	}
+	return routes, config.PromptTemplate(key.PromptTemplate)
+}
+
+// answerMeta 构造返回给调用方的答案元数据
+func answerMeta(qa *models.QuestionAnswer, cacheHit bool) gin.H {
+	meta := gin.H{
+		"cache_hit":         cacheHit,
+		"source":            qa.Source,
+		"platform":          qa.Platform,
+		"model":             qa.Model,
+		"confidence":        qa.Confidence,
+		"prompt_tokens":     qa.PromptTokens,
+		"completion_tokens": qa.CompletionTokens,
+		"hit_count":         qa.HitCount,
+		"review_status":     qa.ReviewStatus,
+	}
+	if qa.UpdatedAt != nil {
+		meta["updated_at"] = qa.UpdatedAt
 	}
-}
\ No newline at end of file
+	return meta
+}
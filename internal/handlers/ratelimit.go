@@ -0,0 +1,406 @@
+package handlers
+
+import (
+	"ai-ocs/internal/ai"
+	"ai-ocs/internal/budget"
+	"ai-ocs/internal/database"
+	"ai-ocs/internal/models"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// quotaUsageKey 请求开始时API密钥的用量在请求上下文中的键名
+const quotaUsageKey = "quota_usage"
+
+// quotaUsageMu 保护请求上下文中的用量，批量查询会在多个 goroutine 中读取和累加
+var quotaUsageMu sync.Mutex
+
+// keyLocks 每个API密钥一把锁
+type keyLocks struct {
+	sync.Mutex
+	keys map[int64]*sync.Mutex
+}
+
+// lock 锁定API密钥，返回解锁函数
+func (l *keyLocks) lock(keyID int64) func() {
+	l.Lock()
+	m := l.keys[keyID]
+	if m == nil {
+		m = &sync.Mutex{}
+		l.keys[keyID] = m
+	}
+	l.Unlock()
+
+	m.Lock()
+	return m.Unlock
+}
+
+// quotaLocks 同一密钥的并发请求依次检查和累加每日、每月调用次数及AI调用次数
+var quotaLocks = &keyLocks{keys: make(map[int64]*sync.Mutex)}
+
+// aiCostLocks 有费用上限的密钥依次调用AI，每次检查费用上限时都已计入此前调用的费用
+var aiCostLocks = &keyLocks{keys: make(map[int64]*sync.Mutex)}
+
+// lockQuota 锁定API密钥的调用次数，返回解锁函数
+func lockQuota(keyID int64) func() {
+	return quotaLocks.lock(keyID)
+}
+
+// lockAICost 锁定API密钥的AI调用，直到本次调用的费用记录完成，返回解锁函数
+func lockAICost(keyID int64) func() {
+	return aiCostLocks.lock(keyID)
+}
+
+// rateWindow 固定时间窗口内的请求计数
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// rateLimiter 按API密钥和时间窗口统计请求次数，只保存在内存中
+type rateLimiter struct {
+	mu      sync.Mutex
+	windows map[rateWindowKey]*rateWindow
+}
+
+type rateWindowKey struct {
+	keyID  int64
+	window time.Duration
+}
+
+var limiter = &rateLimiter{windows: make(map[rateWindowKey]*rateWindow)}
+
+// rateState 一项限制的当前状态，用于生成 X-RateLimit-* 响应头
+type rateState struct {
+	limit     int64
+	remaining int64
+	reset     time.Time
+	msg       string
+}
+
+// take 在窗口内记录一次请求，超过 limit 时不计数并返回 false
+func (l *rateLimiter) take(keyID int64, window time.Duration, limit int, now time.Time) (rateState, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	start := now.Truncate(window)
+	k := rateWindowKey{keyID, window}
+	w := l.windows[k]
+	if w == nil || !w.start.Equal(start) {
+		w = &rateWindow{start: start}
+		l.windows[k] = w
+	}
+
+	state := rateState{limit: int64(limit), reset: start.Add(window)}
+	if w.count >= limit {
+		return state, false
+	}
+	w.count++
+	state.remaining = int64(limit - w.count)
+	return state, true
+}
+
+// RateLimit 中间件，按API密钥的调用限制检查请求频率和每日、每月配额，需在 RequireAPIKey 之后使用。
+// 超出限制时返回 429，并通过 X-RateLimit-* 响应头告知限制、剩余次数和重置时间
+func RateLimit(c *gin.Context) {
+	key := currentAPIKey(c)
+	if key == nil {
+		c.Next()
+		return
+	}
+
+	if !takeQuota(c, key, time.Now()) {
+		return
+	}
+	c.Next()
+}
+
+// takeQuota 检查并记录一次请求，超出限制时中止请求并返回 false。
+// 检查和计数在同一把锁内完成，同一密钥的并发请求不会超出每日、每月配额
+func takeQuota(c *gin.Context, key *models.APIKey, now time.Time) bool {
+	defer lockQuota(key.ID)()
+
+	usage, err := database.GetQuotaUsage(key.ID, now)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "查询API密钥用量时出错"})
+		return false
+	}
+
+	var states []rateState
+
+	// 先检查持久化的配额，超出时不占用频率限制的次数
+	if key.DailyQuota > 0 {
+		state := rateState{limit: key.DailyQuota, remaining: key.DailyQuota - usage.TodayRequests - 1,
+			reset: nextDay(now), msg: "今日调用次数已达上限"}
+		if usage.TodayRequests >= key.DailyQuota {
+			rejectRateLimited(c, state, now)
+			return false
+		}
+		states = append(states, state)
+	}
+	if key.MonthlyQuota > 0 {
+		state := rateState{limit: key.MonthlyQuota, remaining: key.MonthlyQuota - usage.MonthRequests - 1,
+			reset: nextMonth(now), msg: "本月调用次数已达上限"}
+		if usage.MonthRequests >= key.MonthlyQuota {
+			rejectRateLimited(c, state, now)
+			return false
+		}
+		states = append(states, state)
+	}
+
+	windows := []struct {
+		limit  int
+		window time.Duration
+	}{
+		{key.RatePerSecond, time.Second},
+		{key.RatePerMinute, time.Minute},
+	}
+	for _, w := range windows {
+		if w.limit <= 0 {
+			continue
+		}
+		state, ok := limiter.take(key.ID, w.window, w.limit, now)
+		if !ok {
+			state.msg = "请求过于频繁，请稍后再试"
+			rejectRateLimited(c, state, now)
+			return false
+		}
+		states = append(states, state)
+	}
+
+	// 响应头返回剩余次数最少的一项限制
+	if len(states) > 0 {
+		tightest := states[0]
+		for _, s := range states[1:] {
+			if s.remaining < tightest.remaining {
+				tightest = s
+			}
+		}
+		setRateLimitHeaders(c, tightest)
+	}
+
+	if err := database.IncrementQuotaUsage(key.ID, now); err != nil {
+		log.Printf("记录API密钥用量失败: %v", err)
+	}
+	usage.TodayRequests++
+	usage.MonthRequests++
+	c.Set(quotaUsageKey, usage)
+	return true
+}
+
+// aiReservation reserveAIQuery 预留的一次AI调用
+type aiReservation struct {
+	c      *gin.Context
+	keyID  int64
+	now    time.Time
+	unlock func() // 有费用上限的密钥在AI调用完成前持有的锁
+}
+
+// reserveAIQuery 检查API密钥当日未命中缓存的AI调用次数及AI调用费用是否已达上限，超出时返回对应的限制。
+// 检查和预留在密钥的锁内完成并立即计入当日AI调用次数，同一密钥的并发请求不会超出次数上限；
+// 调用结束后需调用 finish，调用未发出或失败时归还预留的次数。
+// 费用在调用完成后才能确定，因此有费用上限的密钥逐个调用AI，预留时已计入此前调用的费用
+func reserveAIQuery(c *gin.Context, now time.Time) (*aiReservation, *rateState) {
+	key := currentAPIKey(c)
+	if key == nil {
+		return &aiReservation{c: c}, nil
+	}
+
+	r := &aiReservation{c: c, keyID: key.ID, now: now}
+	if key.DailyCostCap > 0 || key.MonthlyCostCap > 0 {
+		r.unlock = lockAICost(key.ID)
+	}
+
+	state := r.reserve(key)
+	if state != nil {
+		r.release()
+	}
+	return r, state
+}
+
+func (r *aiReservation) reserve(key *models.APIKey) *rateState {
+	defer lockQuota(key.ID)()
+	quotaUsageMu.Lock()
+	defer quotaUsageMu.Unlock()
+
+	usage := quotaUsageLocked(r.c)
+	if fresh, err := database.GetQuotaUsage(key.ID, r.now); err != nil {
+		log.Printf("查询API密钥用量失败: %v", err)
+	} else {
+		usage = fresh
+	}
+	if key.DailyCostCap > 0 && usage.TodayCost >= key.DailyCostCap {
+		return &rateState{reset: nextDay(r.now), msg: "今日AI调用费用已达上限"}
+	}
+	if key.MonthlyCostCap > 0 && usage.MonthCost >= key.MonthlyCostCap {
+		return &rateState{reset: nextMonth(r.now), msg: "本月AI调用费用已达上限"}
+	}
+	if key.DailyAIQuota > 0 && usage.TodayAIRequests >= key.DailyAIQuota {
+		return &rateState{limit: key.DailyAIQuota, reset: nextDay(r.now), msg: "今日AI调用次数已达上限"}
+	}
+
+	if err := database.AddQuotaAIRequests(key.ID, r.now, 1); err != nil {
+		log.Printf("记录API密钥AI调用次数失败: %v", err)
+	}
+	usage.TodayAIRequests++
+	r.c.Set(quotaUsageKey, usage)
+	return nil
+}
+
+// finish 结束一次预留：used 为 false 时归还预留的AI调用次数，并释放费用上限的锁
+func (r *aiReservation) finish(used bool) {
+	if !used && r.keyID != 0 {
+		defer lockQuota(r.keyID)()
+		if err := database.AddQuotaAIRequests(r.keyID, r.now, -1); err != nil {
+			log.Printf("归还API密钥AI调用次数失败: %v", err)
+		}
+
+		quotaUsageMu.Lock()
+		usage := quotaUsageLocked(r.c)
+		usage.TodayAIRequests--
+		r.c.Set(quotaUsageKey, usage)
+		quotaUsageMu.Unlock()
+	}
+	r.release()
+}
+
+// release 释放费用上限的锁
+func (r *aiReservation) release() {
+	if r.unlock != nil {
+		r.unlock()
+		r.unlock = nil
+	}
+}
+
+// serializeAI 有费用上限的密钥在批量查询中逐题调用AI：费用在调用完成后才能确定，
+// 逐题调用时每次检查都已计入此前调用的费用
+func serializeAI(c *gin.Context) bool {
+	key := currentAPIKey(c)
+	return key != nil && (key.DailyCostCap > 0 || key.MonthlyCostCap > 0)
+}
+
+// recordAIQuery 按价格表累计一次未命中缓存的AI调用的token用量和费用，调用次数已在 reserveAIQuery 中计入
+func recordAIQuery(c *gin.Context, config *models.Config, result *ai.Result) {
+	cost := config.Cost(result.Platform, result.Model, result.Usage.PromptTokens, result.Usage.CompletionTokens)
+	budget.Record(cost)
+
+	keyID := currentAPIKeyID(c)
+	if keyID == 0 {
+		return
+	}
+	addAIUsage(c, cost)
+
+	err := database.RecordAICost(keyID, time.Now(), result.Platform, result.Model,
+		result.Usage.PromptTokens, result.Usage.CompletionTokens, cost)
+	if err != nil {
+		log.Printf("记录API密钥AI调用费用失败: %v", err)
+	}
+}
+
+// chargeBatchQuota 批量查询的每道题目都计入每日、每月调用次数，RateLimit 已计入1次，这里计入其余题目。
+// 剩余次数不足时不计数并返回对应的限制，整批拒绝。与 RateLimit 一样在密钥的锁内重新读取用量后检查
+func chargeBatchQuota(c *gin.Context, questions int, now time.Time) *rateState {
+	key := currentAPIKey(c)
+	if key == nil || questions <= 1 {
+		return nil
+	}
+
+	defer lockQuota(key.ID)()
+	quotaUsageMu.Lock()
+	defer quotaUsageMu.Unlock()
+
+	usage := quotaUsageLocked(c)
+	if fresh, err := database.GetQuotaUsage(key.ID, now); err != nil {
+		log.Printf("查询API密钥用量失败: %v", err)
+	} else {
+		usage = fresh
+	}
+	extra := int64(questions - 1)
+	if key.DailyQuota > 0 && usage.TodayRequests+extra > key.DailyQuota {
+		return &rateState{limit: key.DailyQuota, reset: nextDay(now), msg: "今日剩余调用次数不足"}
+	}
+	if key.MonthlyQuota > 0 && usage.MonthRequests+extra > key.MonthlyQuota {
+		return &rateState{limit: key.MonthlyQuota, reset: nextMonth(now), msg: "本月剩余调用次数不足"}
+	}
+
+	if err := database.AddQuotaRequests(key.ID, now, extra); err != nil {
+		log.Printf("记录API密钥用量失败: %v", err)
+	}
+	usage.TodayRequests += extra
+	usage.MonthRequests += extra
+	c.Set(quotaUsageKey, usage)
+	return nil
+}
+
+// quotaUsageLocked 返回请求上下文中API密钥的用量；需持有 quotaUsageMu
+func quotaUsageLocked(c *gin.Context) models.APIKeyQuotaUsage {
+	var usage models.APIKeyQuotaUsage
+	if v, ok := c.Get(quotaUsageKey); ok {
+		usage = v.(models.APIKeyQuotaUsage)
+	}
+	return usage
+}
+
+// addAIUsage 将本次AI调用的费用累加到请求上下文的用量中（调用次数已在 reserveAIQuery 中预留），
+// 查询用量出错时后续题目据此检查费用上限
+func addAIUsage(c *gin.Context, cost float64) {
+	quotaUsageMu.Lock()
+	defer quotaUsageMu.Unlock()
+
+	v, ok := c.Get(quotaUsageKey)
+	if !ok {
+		return
+	}
+	usage := v.(models.APIKeyQuotaUsage)
+	usage.TodayCost += cost
+	usage.MonthCost += cost
+	c.Set(quotaUsageKey, usage)
+}
+
+func rejectRateLimited(c *gin.Context, state rateState, now time.Time) {
+	setRejectHeaders(c, state, now)
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"code": 1, "msg": state.msg})
+}
+
+// setRejectHeaders 设置超出限制时的响应头；费用不是请求次数，因此费用上限（limit 为0）不设置 X-RateLimit-* 响应头
+func setRejectHeaders(c *gin.Context, state rateState, now time.Time) {
+	if state.limit > 0 {
+		state.remaining = 0
+		setRateLimitHeaders(c, state)
+	}
+	setRetryAfter(c, state.reset, now)
+}
+
+func setRetryAfter(c *gin.Context, reset, now time.Time) {
+	retryAfter := int64(reset.Sub(now).Seconds() + 0.999)
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+}
+
+func setRateLimitHeaders(c *gin.Context, state rateState) {
+	if state.remaining < 0 {
+		state.remaining = 0
+	}
+	c.Header("X-RateLimit-Limit", strconv.FormatInt(state.limit, 10))
+	c.Header("X-RateLimit-Remaining", strconv.FormatInt(state.remaining, 10))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(state.reset.Unix(), 10))
+}
+
+// nextDay 次日零点
+func nextDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+}
+
+// nextMonth 下月第一天零点
+func nextMonth(t time.Time) time.Time {
+	y, m, _ := t.Date()
+	return time.Date(y, m+1, 1, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"ai-ocs/internal/database"
+	"ai-ocs/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// connectTestDB 连接临时目录中的SQLite数据库
+func connectTestDB(t *testing.T) {
+	t.Helper()
+	config := &models.Config{
+		DatabaseType: "sqlite",
+		SQLiteConfig: models.SQLiteConfig{Path: filepath.Join(t.TempDir(), "test.db")},
+	}
+	if err := database.Connect(config); err != nil {
+		t.Fatalf("连接数据库失败: %v", err)
+	}
+}
+
+// keyContext 创建带有API密钥的请求上下文
+func keyContext(key *models.APIKey) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/query", nil)
+	c.Set(apiKeyContextKey, key)
+	return c, w
+}
+
+func TestRateLimiterTakeWindowRollover(t *testing.T) {
+	l := &rateLimiter{windows: make(map[rateWindowKey]*rateWindow)}
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.Local)
+
+	for i := 1; i <= 2; i++ {
+		state, ok := l.take(1, time.Minute, 2, now.Add(time.Duration(i)*time.Second))
+		if !ok {
+			t.Fatalf("第 %d 次请求被拒绝", i)
+		}
+		if state.remaining != int64(2-i) {
+			t.Errorf("第 %d 次请求剩余 %d 次，期望 %d", i, state.remaining, 2-i)
+		}
+	}
+
+	state, ok := l.take(1, time.Minute, 2, now.Add(59*time.Second))
+	if ok {
+		t.Fatal("超出每分钟限制的请求未被拒绝")
+	}
+	if want := now.Add(time.Minute); !state.reset.Equal(want) {
+		t.Errorf("重置时间为 %v，期望 %v", state.reset, want)
+	}
+
+	// 其他密钥和其他窗口不受影响
+	if _, ok := l.take(2, time.Minute, 2, now); !ok {
+		t.Error("其他密钥的请求被拒绝")
+	}
+	if _, ok := l.take(1, time.Second, 2, now.Add(59*time.Second)); !ok {
+		t.Error("每秒窗口的请求被拒绝")
+	}
+
+	state, ok = l.take(1, time.Minute, 2, now.Add(time.Minute))
+	if !ok {
+		t.Fatal("进入下一个窗口后请求仍被拒绝")
+	}
+	if state.remaining != 1 {
+		t.Errorf("新窗口剩余 %d 次，期望 1", state.remaining)
+	}
+}
+
+func TestTakeQuotaDailyLimitAndHeaders(t *testing.T) {
+	connectTestDB(t)
+	now := time.Now()
+	key := &models.APIKey{ID: 101, APIKeyLimits: models.APIKeyLimits{DailyQuota: 3, MonthlyQuota: 10}}
+
+	for i := 1; i <= 3; i++ {
+		c, w := keyContext(key)
+		if !takeQuota(c, key, now) {
+			t.Fatalf("第 %d 次请求被拒绝: %s", i, w.Body.String())
+		}
+		if got, want := w.Header().Get("X-RateLimit-Limit"), "3"; got != want {
+			t.Errorf("第 %d 次请求 X-RateLimit-Limit 为 %q，期望 %q", i, got, want)
+		}
+		if got, want := w.Header().Get("X-RateLimit-Remaining"), strconv.Itoa(3-i); got != want {
+			t.Errorf("第 %d 次请求 X-RateLimit-Remaining 为 %q，期望 %q", i, got, want)
+		}
+	}
+
+	c, w := keyContext(key)
+	if takeQuota(c, key, now) {
+		t.Fatal("超出每日配额的请求未被拒绝")
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("状态码为 %d，期望 429", w.Code)
+	}
+	if got := w.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("X-RateLimit-Remaining 为 %q，期望 0", got)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("缺少 Retry-After 响应头")
+	}
+
+	usage, err := database.GetQuotaUsage(key.ID, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.TodayRequests != 3 {
+		t.Errorf("记录了 %d 次请求，期望 3", usage.TodayRequests)
+	}
+}
+
+func TestChargeBatchQuota(t *testing.T) {
+	connectTestDB(t)
+	now := time.Now()
+	key := &models.APIKey{ID: 102, APIKeyLimits: models.APIKeyLimits{DailyQuota: 10}}
+
+	c, _ := keyContext(key)
+	if !takeQuota(c, key, now) {
+		t.Fatal("批量查询的首次计数被拒绝")
+	}
+
+	// 已用1次，11道题需要再计入10次，超出配额时整批拒绝且不计数
+	state := chargeBatchQuota(c, 11, now)
+	if state == nil {
+		t.Fatal("剩余次数不足的批量查询未被拒绝")
+	}
+	if state.limit != 10 || state.msg != "今日剩余调用次数不足" {
+		t.Errorf("返回的限制不正确: %+v", state)
+	}
+
+	if state := chargeBatchQuota(c, 10, now); state != nil {
+		t.Fatalf("剩余次数足够的批量查询被拒绝: %s", state.msg)
+	}
+	usage, err := database.GetQuotaUsage(key.ID, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.TodayRequests != 10 {
+		t.Errorf("记录了 %d 次请求，期望 10", usage.TodayRequests)
+	}
+
+	c, w := keyContext(key)
+	if takeQuota(c, key, now) {
+		t.Fatal("配额用完后的请求未被拒绝")
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("状态码为 %d，期望 429", w.Code)
+	}
+}
+
+func TestReserveAIQueryConcurrent(t *testing.T) {
+	connectTestDB(t)
+	now := time.Now()
+	key := &models.APIKey{ID: 103, APIKeyLimits: models.APIKeyLimits{DailyAIQuota: 3}}
+
+	const requests = 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var reserved []*aiReservation
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, _ := keyContext(key)
+			r, state := reserveAIQuery(c, now)
+			if state != nil {
+				return
+			}
+			mu.Lock()
+			reserved = append(reserved, r)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if len(reserved) != 3 {
+		t.Fatalf("并发预留成功 %d 次，期望 3", len(reserved))
+	}
+	usage, err := database.GetQuotaUsage(key.ID, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.TodayAIRequests != 3 {
+		t.Errorf("记录了 %d 次AI调用，期望 3", usage.TodayAIRequests)
+	}
+
+	// 调用失败时归还预留的次数
+	reserved[0].finish(true)
+	for _, r := range reserved[1:] {
+		r.finish(false)
+	}
+	usage, err = database.GetQuotaUsage(key.ID, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.TodayAIRequests != 1 {
+		t.Errorf("归还后记录了 %d 次AI调用，期望 1", usage.TodayAIRequests)
+	}
+}
+
+func TestReserveAIQueryCostCapConcurrent(t *testing.T) {
+	connectTestDB(t)
+	now := time.Now()
+	key := &models.APIKey{ID: 104, APIKeyLimits: models.APIKeyLimits{DailyCostCap: 2.5}}
+
+	// 每次调用花费1，有费用上限的密钥逐个调用，费用达到上限后不再预留
+	const requests = 10
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, _ := keyContext(key)
+			r, state := reserveAIQuery(c, now)
+			if state != nil {
+				return
+			}
+			if err := database.RecordAICost(key.ID, now, "openai", "gpt-4o", 10, 10, 1); err != nil {
+				t.Error(err)
+			}
+			r.finish(true)
+
+			mu.Lock()
+			succeeded++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != 3 {
+		t.Errorf("费用上限内调用了 %d 次，期望 3", succeeded)
+	}
+}
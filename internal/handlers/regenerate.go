@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"ai-ocs/internal/ai"
+	"ai-ocs/internal/budget"
+	"ai-ocs/internal/database"
+	"ai-ocs/internal/models"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CandidateRequest 重新生成答案时选择的平台和模型，模型为空时使用配置中的默认模型
+type CandidateRequest struct {
+	Platform string `json:"platform"`
+	Model    string `json:"model"`
+}
+
+// RegenerateRequest 重新生成答案的请求结构
+type RegenerateRequest struct {
+	Candidates []CandidateRequest `json:"candidates" binding:"required"`
+}
+
+// Candidate 某个平台重新生成的候选答案
+type Candidate struct {
+	Platform         string `json:"platform"`
+	Model            string `json:"model"`
+	Answer           string `json:"answer,omitempty"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	LatencyMs        int64  `json:"latency_ms"`
+	Error            string `json:"error,omitempty"`
+}
+
+// AcceptRequest 采用候选答案的请求结构
+type AcceptRequest struct {
+	Answer           string `json:"answer" binding:"required"`
+	Platform         string `json:"platform"`
+	Model            string `json:"model"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	Reason           string `json:"reason"`
+}
+
+// maxCandidates 一次最多同时请求的平台数
+const maxCandidates = 8
+
+// GetPlatforms 返回支持的AI平台及配置中的默认模型，供管理后台选择
+func GetPlatforms(config *models.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var platforms []gin.H
+		for _, p := range ai.Platforms {
+			platforms = append(platforms, gin.H{
+				"platform":   p,
+				"model":      config.Models[p],
+				"configured": p == "ollama" || config.APIKeys[p] != "",
+				"default":    p == config.Platform,
+			})
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"data": platforms,
+		})
+	}
+}
+
+// GetProviderHealth 返回各平台和模型的熔断状态、最近调用的错误率和延迟，
+// 包括调用过的平台和模型，以及已配置密钥但尚未调用的平台的默认模型
+func GetProviderHealth(config *models.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		providers := ai.AllHealth()
+		seen := make(map[string]bool, len(providers))
+		for _, h := range providers {
+			seen[h.Platform+"/"+h.Model] = true
+		}
+		for _, p := range ai.Platforms {
+			configured := p == "ollama" || config.APIKeys[p] != ""
+			if configured && !seen[p+"/"+config.Models[p]] {
+				providers = append(providers, ai.Health(p, config.Models[p]))
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"data":             providers,
+			"default_platform": config.Platform,
+		})
+	}
+}
+
+// ResetProviderCircuit 手动关闭平台和模型的熔断器，下一次请求立即使用
+func ResetProviderCircuit(c *gin.Context) {
+	var req CandidateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	if !ai.IsPlatform(req.Platform) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的平台: " + req.Platform})
+		return
+	}
+	ai.ResetCircuit(req.Platform, req.Model)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已恢复 " + req.Platform + "/" + req.Model,
+		"data":    ai.Health(req.Platform, req.Model),
+	})
+}
+
+// RegenerateAnswer 用选定的平台和模型并发重新生成题目答案，返回当前答案和各平台的候选答案，不修改题库
+func RegenerateAnswer(config *models.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的题目ID"})
+			return
+		}
+
+		var req RegenerateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+			return
+		}
+		if len(req.Candidates) == 0 || len(req.Candidates) > maxCandidates {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请选择 1 到 " + strconv.Itoa(maxCandidates) + " 个平台"})
+			return
+		}
+		for _, cand := range req.Candidates {
+			if !ai.IsPlatform(cand.Platform) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的平台: " + cand.Platform})
+				return
+			}
+		}
+
+		question, err := database.GetQuestion(id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "无法获取题目: " + err.Error(),
+			})
+			return
+		}
+		if question == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "题目不存在"})
+			return
+		}
+
+		// 重新生成同样计入全局费用预算，熔断后不再调用AI
+		if tripped, _ := budget.Check(); tripped {
+			c.JSON(http.StatusForbidden, gin.H{"error": "今日AI费用预算已熔断，请先恢复AI调用"})
+			return
+		}
+
+		var options, questionType string
+		if question.Options != nil {
+			options = *question.Options
+		}
+		if question.Type != nil {
+			questionType = *question.Type
+		}
+
+		candidates := make([]*Candidate, len(req.Candidates))
+		var wg sync.WaitGroup
+		for i, cand := range req.Candidates {
+			wg.Add(1)
+			go func(i int, cand CandidateRequest) {
+				defer wg.Done()
+
+				result := &Candidate{Platform: cand.Platform, Model: strings.TrimSpace(cand.Model)}
+				if result.Model == "" {
+					result.Model = config.Models[cand.Platform]
+				}
+				start := time.Now()
+				answer, err := ai.QueryModel(question.Question, options, questionType, cand.Platform, result.Model, config.APIKeys, config.Models)
+				result.LatencyMs = time.Since(start).Milliseconds()
+				if err != nil {
+					result.Error = err.Error()
+				} else {
+					result.Answer = answer.Answer
+					result.PromptTokens = answer.Usage.PromptTokens
+					result.CompletionTokens = answer.Usage.CompletionTokens
+					recordRegenerateCost(config, answer)
+				}
+				candidates[i] = result
+			}(i, cand)
+		}
+		wg.Wait()
+
+		c.JSON(http.StatusOK, gin.H{
+			"data": gin.H{
+				"question":   question,
+				"candidates": candidates,
+			},
+		})
+	}
+}
+
+// recordRegenerateCost 将重新生成的费用计入全局费用预算，并记录到费用统计中，API密钥ID记为0
+func recordRegenerateCost(config *models.Config, result *ai.Result) {
+	cost := config.Cost(result.Platform, result.Model, result.Usage.PromptTokens, result.Usage.CompletionTokens)
+	budget.Record(cost)
+
+	err := database.RecordAICost(0, time.Now(), result.Platform, result.Model,
+		result.Usage.PromptTokens, result.Usage.CompletionTokens, cost)
+	if err != nil {
+		log.Printf("记录重新生成的AI调用费用失败: %v", err)
+	}
+}
+
+// AcceptAnswer 采用重新生成的候选答案，并记录到答案历史
+func AcceptAnswer(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的题目ID"})
+		return
+	}
+
+	var req AcceptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	reason := req.Reason
+	if reason == "" {
+		reason = "重新生成后选用"
+	}
+	source := models.AnswerSource{
+		Source:           models.AnswerSourceModel,
+		Platform:         req.Platform,
+		Model:            req.Model,
+		Operator:         currentAdmin(c),
+		Reason:           reason,
+		PromptTokens:     req.PromptTokens,
+		CompletionTokens: req.CompletionTokens,
+	}
+
+	// 管理员比较后选用的答案视为已审核
+	err = database.UpdateVerifiedAnswer(id, req.Answer, source, &manualConfidence)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "题目不存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "无法更新答案: " + err.Error(),
+		})
+		return
+	}
+
+	updated, _ := database.GetQuestion(id)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已采用新的答案",
+		"data":    updated,
+	})
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"ai-ocs/internal/database"
+	"ai-ocs/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReviewRequest 批量审核的请求结构
+type ReviewRequest struct {
+	IDs    []int64 `json:"ids" binding:"required"`
+	Status string  `json:"status" binding:"required"`
+}
+
+// maxReviewBatch 一次最多审核的题目数
+const maxReviewBatch = 500
+
+// GetReviewQueue 分页获取审核队列，status 为逗号分隔的审核状态，默认返回有争议和未审核的答案，
+// 同时支持 keyword、type、source、from、to 筛选条件
+func GetReviewQueue(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	offset := (page - 1) * limit
+
+	filter, err := questionFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.ReviewStatus = c.DefaultQuery("status", models.ReviewDisputed+","+models.ReviewUnverified)
+	for _, s := range strings.Split(filter.ReviewStatus, ",") {
+		if !models.IsReviewStatus(strings.TrimSpace(s)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的审核状态: " + s})
+			return
+		}
+	}
+
+	questions, total, err := database.GetReviewQueue(filter, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "无法获取审核队列: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  questions,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// ReviewQuestions 批量设置题目的审核状态：verified 通过，rejected 驳回，disputed 标记有争议，unverified 撤回审核
+func ReviewQuestions(c *gin.Context) {
+	var req ReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	if !models.IsReviewStatus(req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的审核状态: " + req.Status})
+		return
+	}
+	if len(req.IDs) == 0 || len(req.IDs) > maxReviewBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请选择 1 到 " + strconv.Itoa(maxReviewBatch) + " 道题目"})
+		return
+	}
+
+	updated, err := database.SetReviewStatus(req.IDs, req.Status, &manualConfidence)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "审核失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已更新 " + strconv.FormatInt(updated, 10) + " 道题目的审核状态",
+		"data":    gin.H{"count": updated},
+	})
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"ai-ocs/internal/database"
+	"ai-ocs/internal/models"
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RollbackRequest 答案回滚请求结构
+type RollbackRequest struct {
+	RevisionID      int64  `json:"revision_id" binding:"required"`
+	RestorePrevious bool   `json:"restore_previous"`
+	Reason          string `json:"reason"`
+}
+
+// GetAnswerRevisions 获取题目答案的历史版本
+func GetAnswerRevisions(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的题目ID"})
+		return
+	}
+
+	revisions, err := database.GetAnswerRevisions(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "无法获取历史版本: " + err.Error(),
+		})
+		return
+	}
+
+	if revisions == nil {
+		revisions = []*models.AnswerRevision{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": revisions,
+	})
+}
+
+// RollbackAnswer 将题目答案回滚到指定历史版本
+func RollbackAnswer(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的题目ID"})
+		return
+	}
+
+	var req RollbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	answer, err := database.RollbackAnswer(id, req.RevisionID, req.RestorePrevious, currentAdmin(c), req.Reason)
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "题目或历史版本不存在"})
+		return
+	case err == database.ErrNoPreviousAnswer:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "回滚失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "答案已回滚",
+		"data": gin.H{
+			"answer": answer,
+		},
+	})
+}
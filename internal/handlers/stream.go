@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"ai-ocs/internal/models"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StreamAnswer 以 SSE 流式返回查询进度和答案，需在 RequireAPIKey 和 RateLimit 之后使用。
+// GET 请求的参数与 GET /api/query 相同，POST 请求的JSON请求体与 POST /api/query 相同。客户端断开连接时停止调用AI。依次发送以下事件：
+//
+//   - cache: 是否命中题库，{"hit": true}
+//   - provider: 开始调用的平台和模型，前一个平台失败后会再次发送，此前收到的 token 应丢弃
+//   - reasoning: 一段推理模型的思考过程，{"text": "..."}，不属于答案；review.verified_only_types 中的题型不发送
+//   - token: 一段模型输出，{"text": "..."}；review.verified_only_types 中的题型不发送
+//   - answer: 最终结果，与 /api/query 的响应相同
+func StreamAnswer(config *models.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q := questionQuery{
+			title:        strings.TrimSpace(c.Query("title")),
+			options:      c.Query("options"),
+			questionType: c.Query("type"),
+			withMeta:     metaRequested(c),
+		}
+		if c.Request.Method == http.MethodPost {
+			var req QueryRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "请求参数错误: " + err.Error()})
+				return
+			}
+			q = req.query(metaRequested(c))
+		}
+
+		// 参数错误时还未开始输出，直接返回JSON
+		if q.title == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "题目不能为空"})
+			return
+		}
+
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no") // 避免 Nginx 缓冲事件
+		q.skipHeaders = true
+		q.progress = func(event string, data gin.H) {
+			c.SSEvent(event, data)
+			c.Writer.Flush()
+		}
+
+		_, resp := answerQuestion(c, config, q)
+		c.SSEvent("answer", resp)
+		c.Writer.Flush()
+	}
+}
@@ -1,10 +1,9 @@
 package handlers
 
 import (
-	"ai-ocs/internal/ai"
 	"ai-ocs/internal/models"
 	"net/http"
-	"path/filepath"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,58 +19,29 @@ func TestHandler(c *gin.Context) {
 	})
 }
 
-// TestAnswerHandler 测试答题功能的接口处理器
-func TestAnswerHandler(c *gin.Context) {
-	// 使用相对路径获取配置文件路径
-	configPath := filepath.Join("configs", "config.json")
-
-	// 加载配置
-	config, err := models.LoadConfig(configPath)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code": 1,
-			"msg":  "配置加载失败: " + err.Error(),
-		})
-		return
-	}
-
-	// 获取查询参数
-	title := c.Query("title")
-	options := c.Query("options")
-	questionType := c.Query("type")
-
-	// 参数校验
-	if title == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code": 1,
-			"msg":  "题目不能为空",
+// TestAnswerHandler 测试答题功能的接口处理器，与查询接口一样经过题库缓存、AI调用限制和费用统计
+func TestAnswerHandler(config *models.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status, resp := answerQuestion(c, config, questionQuery{
+			title:        strings.TrimSpace(c.Query("title")),
+			options:      c.Query("options"),
+			questionType: c.Query("type"),
+			withMeta:     true,
 		})
-		return
-	}
-
-	// 调用AI模型获取答案
-	answer, err := ai.QueryLargeModel(
-		title,
-		options,
-		questionType,
-		config.Platform,
-		config.APIKeys,
-		config.Models,
-	)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code": 1,
-			"msg":  "AI模型调用失败: " + err.Error(),
+		if resp["code"] != 0 {
+			c.JSON(status, resp)
+			return
+		}
+
+		// 返回结果
+		data := resp["data"].(gin.H)
+		c.JSON(http.StatusOK, gin.H{
+			"code": 0,
+			"msg":  "测试答题成功",
+			"data": gin.H{
+				"answer": data["data"],
+				"meta":   data["meta"],
+			},
 		})
-		return
 	}
-
-	// 返回结果
-	c.JSON(http.StatusOK, gin.H{
-		"code": 0,
-		"msg":  "测试答题成功",
-		"data": gin.H{
-			"answer": answer,
-		},
-	})
 }
\ No newline at end of file
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"ai-ocs/internal/database"
+	"ai-ocs/internal/verifier"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetVerifierStatus 返回后台答案复核的运行状态
+func GetVerifierStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"data": verifier.GetStatus(),
+	})
+}
+
+// PauseVerifier 暂停后台答案复核
+func PauseVerifier(c *gin.Context) {
+	verifier.Pause()
+	c.JSON(http.StatusOK, gin.H{
+		"message": "答案复核已暂停",
+		"data":    verifier.GetStatus(),
+	})
+}
+
+// ResumeVerifier 恢复后台答案复核
+func ResumeVerifier(c *gin.Context) {
+	verifier.Resume()
+	c.JSON(http.StatusOK, gin.H{
+		"message": "答案复核已恢复",
+		"data":    verifier.GetStatus(),
+	})
+}
+
+// RunVerifier 立即开始一轮答案复核
+func RunVerifier(c *gin.Context) {
+	if err := verifier.RunNow(); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已开始复核",
+	})
+}
+
+// GetVerifications 分页查询复核记录，status 按结果筛选，disputed=1 只返回仍有争议的题目
+func GetVerifications(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	offset := (page - 1) * limit
+	disputed := c.Query("disputed") == "1" || c.Query("disputed") == "true"
+
+	records, total, err := database.GetVerifications(c.Query("status"), disputed, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "无法获取复核记录: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  records,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"encoding/json"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -23,6 +24,80 @@ type Config struct {
 	SQLiteConfig SQLiteConfig `json:"sqlite"`
 	// 管理员账户配置
 	Admin AdminConfig `json:"admin"`
+	// 查询日志配置
+	QueryLog QueryLogConfig `json:"query_log"`
+	// 定时备份配置
+	Backup BackupConfig `json:"backup"`
+	// 答案复核配置
+	Verifier VerifierConfig `json:"verifier"`
+	// 用户反馈配置
+	Feedback FeedbackConfig `json:"feedback"`
+	// 答案审核配置
+	Review ReviewConfig `json:"review"`
+	// 命名的提示词模板，可在API密钥中选用；default 为未指定模板时使用的模板
+	PromptTemplates map[string]string `json:"prompt_templates"`
+	// 模型价格表，键为 平台:模型、模型名或平台名，用于统计AI调用费用
+	Prices map[string]ModelPrice `json:"prices"`
+	// 全局每日AI费用预算
+	Budget BudgetConfig `json:"budget"`
+	// AI平台熔断配置
+	ProviderHealth ProviderHealthConfig `json:"provider_health"`
+	// API密钥读取方式
+	APIAuth APIAuthConfig `json:"api_auth"`
+	// 批量查询配置
+	Batch BatchConfig `json:"batch"`
+}
+
+// BatchConfig 批量查询配置
+type BatchConfig struct {
+	// 一次最多查询的题目数，默认50
+	MaxQuestions int `json:"max_questions"`
+	// 未命中题库时同时调用AI的题目数，默认4
+	Concurrency int `json:"concurrency"`
+}
+
+// APIAuthConfig API密钥读取方式：默认接受 Authorization、X-API-Key 请求头和JSON请求体中的密钥
+type APIAuthConfig struct {
+	// 兼容旧的OCS配置，同时接受 api-key 查询参数，默认关闭。查询参数中的密钥会出现在访问日志、
+	// 浏览器历史和代理日志中，只应在调用方无法设置请求头时开启
+	AllowQueryKey bool `json:"allow_query_key"`
+}
+
+// ProviderHealthConfig AI平台熔断配置：平台连续调用失败后暂停使用，冷却结束后用一次请求试探是否恢复
+type ProviderHealthConfig struct {
+	// 连续失败多少次后熔断，默认5
+	FailureThreshold int `json:"failure_threshold"`
+	// 熔断后多少秒允许一次试探请求，默认30
+	CooldownSeconds int `json:"cooldown_seconds"`
+	// 统计错误率和延迟的最近调用次数，默认50
+	Window int `json:"window"`
+}
+
+// ModelPrice 模型每百万token的价格
+type ModelPrice struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+// Cost 按价格表计算一次调用的费用，依次查找 平台:模型、模型名和平台名，均未配置时为0
+func (c *Config) Cost(platform, model string, promptTokens, completionTokens int) float64 {
+	price, ok := c.Prices[platform+":"+model]
+	if !ok {
+		price, ok = c.Prices[model]
+	}
+	if !ok {
+		price = c.Prices[platform]
+	}
+	return (float64(promptTokens)*price.Prompt + float64(completionTokens)*price.Completion) / 1e6
+}
+
+// PromptTemplate 获取指定名称的提示词模板，名称为空或不存在时使用 default 模板，
+// 未配置 default 时返回空字符串，表示使用内置模板
+func (c *Config) PromptTemplate(name string) string {
+	if tmpl, ok := c.PromptTemplates[name]; ok && name != "" {
+		return tmpl
+	}
+	return c.PromptTemplates["default"]
 }
 
 // MySQLConfig MySQL数据库配置
@@ -45,14 +120,407 @@ type AdminConfig struct {
 	Password string `json:"password"`
 }
 
+// QueryLogConfig 查询日志配置
+type QueryLogConfig struct {
+	// 是否关闭查询日志
+	Disabled bool `json:"disabled"`
+	// 日志保留天数
+	RetentionDays int `json:"retention_days"`
+	// 异步写入队列长度，队列满时丢弃新日志
+	BufferSize int `json:"buffer_size"`
+}
+
+// BackupConfig 定时备份配置
+type BackupConfig struct {
+	// 是否启用定时备份
+	Enabled bool `json:"enabled"`
+	// 备份文件目录
+	Dir string `json:"dir"`
+	// 备份间隔（小时）
+	IntervalHours int `json:"interval_hours"`
+	// 保留的备份份数
+	Keep int `json:"keep"`
+	// 是否备份查询日志
+	IncludeLogs bool `json:"include_logs"`
+	// 是否备份配置文件（包含各平台密钥，请妥善保管备份文件）
+	IncludeConfig bool `json:"include_config"`
+}
+
+// VerifierConfig 后台答案复核配置：定期挑选符合条件的答案，用更强的模型重新作答并比对
+type VerifierConfig struct {
+	// 是否启用后台复核
+	Enabled bool `json:"enabled"`
+	// 复核使用的平台和模型，模型为空时使用 models 中该平台的模型
+	Platform string `json:"platform"`
+	Model    string `json:"model"`
+	// 每轮复核的间隔（分钟）和每轮最多复核的题目数
+	IntervalMinutes int `json:"interval_minutes"`
+	BatchSize       int `json:"batch_size"`
+	// 每分钟最多请求次数
+	RequestsPerMinute int `json:"requests_per_minute"`
+	// 每日token预算，用完后当天不再复核，0表示不限制
+	DailyTokenBudget int `json:"daily_token_budget"`
+
+	// 以下为挑选答案的条件
+	// 只复核置信度低于该值（未评估视为0）的答案，管理员确认的答案置信度为1
+	MaxConfidence float64 `json:"max_confidence"`
+	// 只复核最后更新超过指定天数的答案，0表示不限制
+	MinAgeDays int `json:"min_age_days"`
+	// 只复核由这些模型生成的答案，为空表示不限制
+	Models []string `json:"models"`
+	// 只复核有争议的答案
+	OnlyDisputed bool `json:"only_disputed"`
+	// 已复核的答案超过指定天数后再次复核，0表示只复核从未复核过的答案
+	ReverifyAfterDays int `json:"reverify_after_days"`
+}
+
+// ReviewConfig 答案审核配置
+type ReviewConfig struct {
+	// 这些题型只返回已审核通过的答案，未审核的答案进入审核队列
+	VerifiedOnlyTypes []string `json:"verified_only_types"`
+}
+
+// VerifiedOnly 判断该题型是否只返回已审核通过的答案
+func (r ReviewConfig) VerifiedOnly(questionType string) bool {
+	questionType = strings.TrimSpace(questionType)
+	if questionType == "" {
+		return false
+	}
+	for _, t := range r.VerifiedOnlyTypes {
+		if strings.EqualFold(strings.TrimSpace(t), questionType) {
+			return true
+		}
+	}
+	return false
+}
+
+// FeedbackConfig 用户反馈配置：当前答案收到足够多的“错误”反馈时自动处理
+type FeedbackConfig struct {
+	// 当前答案的错误票数达到该值时自动处理，默认3
+	WrongThreshold int `json:"wrong_threshold"`
+	// 错误票占当前答案全部票数的最低比例，默认0.5
+	WrongRatio float64 `json:"wrong_ratio"`
+	// 自动处理方式，见 FeedbackAction* 常量，默认 demote
+	Action string `json:"action"`
+}
+
+// 反馈达到阈值后的处理方式
+const (
+	FeedbackActionNone       = "none"       // 不自动处理，只进入审核队列
+	FeedbackActionFlag       = "flag"       // 将答案标记为有争议
+	FeedbackActionDemote     = "demote"     // 标记为有争议并将置信度降为0，使其进入后台复核
+	FeedbackActionInvalidate = "invalidate" // 清空答案，下次查询时重新生成
+)
+
+// BudgetConfig 全局每日AI费用预算：当日所有平台的预估费用（按 prices 计算，包括后台复核）达到上限时熔断
+type BudgetConfig struct {
+	// 每日费用上限，0表示不限制
+	DailyLimit float64 `json:"daily_limit"`
+	// 费用达到上限的该比例时发出预警，默认0.8
+	WarnRatio float64 `json:"warn_ratio"`
+	// 熔断后的处理方式，见 BudgetAction* 常量，默认 cache-only
+	Action string `json:"action"`
+	// fallback 时使用的平台和模型，模型为空时使用 models 中该平台的模型
+	FallbackPlatform string `json:"fallback_platform"`
+	FallbackModel    string `json:"fallback_model"`
+	// 预警和熔断时以POST方式发送JSON通知的地址，为空时只记录日志
+	AlertWebhook string `json:"alert_webhook"`
+}
+
+// 预算熔断后的处理方式
+const (
+	BudgetActionCacheOnly = "cache-only" // 只返回题库中已有的答案，不再调用AI
+	BudgetActionFallback  = "fallback"   // 改用 fallback_platform 指定的平台和模型
+)
+
 // APIKey API密钥结构
 type APIKey struct {
-	ID          int64     `json:"id"`
-	APIKey      string    `json:"api_key"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-	CallCount   int64     `json:"call_count"`     // 调用次数
-	LastUsedAt  time.Time `json:"last_used_at"`   // 最后使用时间
+	ID          int64      `json:"id"`
+	APIKey      string     `json:"api_key,omitempty"` // 完整密钥，只在创建时返回一次
+	Prefix      string     `json:"prefix"`            // 密钥前缀，用于识别密钥
+	Description string     `json:"description"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CallCount   int64      `json:"call_count"`           // 调用次数
+	LastUsedAt  time.Time  `json:"last_used_at"`         // 最后使用时间
+	Enabled     bool       `json:"enabled"`              // 停用的密钥不能调用任何接口
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // 过期时间，为空表示永不过期
+	Scopes      []string   `json:"scopes"`               // 权限范围，见 APIScope* 常量
+
+	// 轮换前的旧密钥在宽限期内仍然有效
+	PreviousPrefix    string     `json:"previous_prefix,omitempty"`
+	PreviousExpiresAt *time.Time `json:"previous_expires_at,omitempty"`
+	UsingPrevious     bool       `json:"-"` // 本次请求使用的是旧密钥
+	APIKeyLimits
+	APIKeyRouting
+	Usage APIKeyQuotaUsage `json:"usage"` // 当日及当月的用量
+}
+
+// ProviderRoute API密钥路由中的一个平台，Model 为空时使用全局配置的模型
+type ProviderRoute struct {
+	Platform string `json:"platform" binding:"required"`
+	Model    string `json:"model,omitempty"`
+}
+
+// APIKeyRouting API密钥未命中缓存时调用AI的路由，为空时使用全局配置
+type APIKeyRouting struct {
+	Providers      []ProviderRoute `json:"providers" binding:"dive"` // 依次尝试的平台，前一个失败时使用下一个
+	PromptTemplate string          `json:"prompt_template"`          // prompt_templates 中的模板名，为空时使用默认模板
+}
+
+// API密钥的权限范围
+const (
+	APIScopeQuery     = "query"      // 查询答案，题库中没有时调用AI生成
+	APIScopeCacheOnly = "cache-only" // 只查询题库中已有的答案，从不调用AI
+	APIScopeFeedback  = "feedback"   // 反馈答案是否正确
+	APIScopeAdminAPI  = "admin-api"  // 调用管理后台接口
+)
+
+// APIScopes 全部权限范围
+var APIScopes = []string{APIScopeQuery, APIScopeCacheOnly, APIScopeFeedback, APIScopeAdminAPI}
+
+// DefaultAPIScopes 新建密钥的默认权限范围
+var DefaultAPIScopes = []string{APIScopeQuery, APIScopeFeedback}
+
+// IsAPIScope 检查是否为有效的权限范围
+func IsAPIScope(scope string) bool {
+	for _, s := range APIScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope 检查密钥是否拥有指定的权限范围
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired 检查密钥在 now 时是否已过期
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// CanQueryAI 检查密钥查询时能否调用AI，拥有 cache-only 权限的密钥只能查询已有答案
+func (k *APIKey) CanQueryAI() bool {
+	return k.HasScope(APIScopeQuery) && !k.HasScope(APIScopeCacheOnly)
+}
+
+// APIKeyLimits API密钥的调用限制，0表示不限制
+type APIKeyLimits struct {
+	RatePerSecond int   `json:"rate_per_second" binding:"min=0"` // 每秒最多请求次数
+	RatePerMinute int   `json:"rate_per_minute" binding:"min=0"` // 每分钟最多请求次数
+	DailyQuota    int64 `json:"daily_quota" binding:"min=0"`     // 每日最多请求次数
+	MonthlyQuota  int64 `json:"monthly_quota" binding:"min=0"`   // 每月最多请求次数
+	DailyAIQuota  int64 `json:"daily_ai_quota" binding:"min=0"`  // 每日最多未命中缓存、需要调用AI的次数
+
+	DailyCostCap   float64 `json:"daily_cost_cap" binding:"min=0"`   // 每日AI调用费用上限
+	MonthlyCostCap float64 `json:"monthly_cost_cap" binding:"min=0"` // 每月AI调用费用上限
+}
+
+// APIKeyQuotaUsage API密钥在配额周期内的用量
+type APIKeyQuotaUsage struct {
+	TodayRequests   int64 `json:"today_requests"`
+	TodayAIRequests int64 `json:"today_ai_requests"`
+	MonthRequests   int64 `json:"month_requests"`
+
+	TodayCost float64 `json:"today_cost"`
+	MonthCost float64 `json:"month_cost"`
+}
+
+// CostRollup 按日期、API密钥或平台汇总的AI调用用量和费用，未参与分组的字段为零值
+type CostRollup struct {
+	Day              string  `json:"day,omitempty"`
+	APIKeyID         int64   `json:"api_key_id,omitempty"`
+	Description      string  `json:"description,omitempty"`
+	Platform         string  `json:"platform,omitempty"`
+	Model            string  `json:"model,omitempty"`
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// CostReport 一段时间内的AI调用费用统计
+type CostReport struct {
+	From       string        `json:"from"`
+	To         string        `json:"to"`
+	Total      CostRollup    `json:"total"`
+	ByKey      []*CostRollup `json:"by_key"`
+	ByProvider []*CostRollup `json:"by_provider"`
+	Daily      []*CostRollup `json:"daily"`
+}
+
+// QuestionAnswer 题库中的一条题目记录
+type QuestionAnswer struct {
+	ID               int64      `json:"id"`
+	Question         string     `json:"question"`
+	Answer           string     `json:"answer"`
+	Options          *string    `json:"options,omitempty"`
+	Type             *string    `json:"type,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	Source           string     `json:"source,omitempty"`     // 答案来源，见 AnswerSource* 常量
+	Platform         string     `json:"platform,omitempty"`   // 生成答案的AI平台
+	Model            string     `json:"model,omitempty"`      // 生成答案的模型
+	Confidence       *float64   `json:"confidence,omitempty"` // 置信度（0-1），未评估时为空
+	PromptTokens     int        `json:"prompt_tokens"`
+	CompletionTokens int        `json:"completion_tokens"`
+	HitCount         int64      `json:"hit_count"` // 缓存命中次数
+	UpdatedAt        *time.Time `json:"updated_at,omitempty"`
+	VerifiedAt       *time.Time `json:"verified_at,omitempty"`   // 最近一次后台复核的时间
+	VerifyStatus     string     `json:"verify_status,omitempty"` // 最近一次复核结果，见 Verify* 常量
+	ReviewStatus     string     `json:"review_status"`           // 审核状态，见 Review* 常量
+}
+
+// 答案审核状态
+const (
+	ReviewUnverified = "unverified" // 未审核，AI生成或导入的答案
+	ReviewVerified   = "verified"   // 已审核通过
+	ReviewDisputed   = "disputed"   // 有争议：复核不一致或用户反馈错误，等待人工处理
+	ReviewRejected   = "rejected"   // 已驳回，不再作为缓存答案返回，下次查询时重新生成
+)
+
+// IsReviewStatus 判断是否为有效的审核状态
+func IsReviewStatus(status string) bool {
+	switch status {
+	case ReviewUnverified, ReviewVerified, ReviewDisputed, ReviewRejected:
+		return true
+	}
+	return false
+}
+
+// 答案复核结果
+const (
+	VerifyAgree    = "agree"    // 复核模型的答案与现有答案一致
+	VerifyMismatch = "mismatch" // 答案不一致，已标记待处理
+	VerifyError    = "error"    // 复核请求失败
+)
+
+// AnswerVerification 一次后台复核的记录
+type AnswerVerification struct {
+	ID               int64     `json:"id"`
+	QuestionID       int64     `json:"question_id"`
+	Question         string    `json:"question,omitempty"`
+	CurrentAnswer    string    `json:"current_answer,omitempty"` // 题库中现在的答案
+	Answer           string    `json:"answer"`                   // 复核时题库中的答案
+	VerifierAnswer   string    `json:"verifier_answer"`          // 复核模型给出的答案
+	Status           string    `json:"status"`
+	Platform         string    `json:"platform"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Error            string    `json:"error,omitempty"`
+	ReviewStatus     string    `json:"review_status"` // 题目当前的审核状态
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// AnswerFeedback 调用方对某道题当前答案的一次反馈
+type AnswerFeedback struct {
+	ID              int64     `json:"id"`
+	QuestionID      int64     `json:"question_id"`
+	APIKeyID        int64     `json:"api_key_id"`
+	Answer          string    `json:"answer"` // 反馈时题库中的答案
+	Correct         bool      `json:"correct"`
+	SuggestedAnswer string    `json:"suggested_answer,omitempty"`
+	ClientIP        string    `json:"client_ip,omitempty"`
+	Resolved        bool      `json:"resolved"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// FeedbackSummary 一道题待处理反馈的汇总，用于审核队列
+type FeedbackSummary struct {
+	QuestionID     int64                `json:"question_id"`
+	Question       string               `json:"question"`
+	Answer         string               `json:"answer"`
+	Confidence     *float64             `json:"confidence,omitempty"`
+	ReviewStatus   string               `json:"review_status"`
+	CorrectVotes   int64                `json:"correct_votes"` // 针对当前答案的票数
+	WrongVotes     int64                `json:"wrong_votes"`
+	StaleVotes     int64                `json:"stale_votes"` // 针对已被修改的旧答案的票数
+	Suggestions    []FeedbackSuggestion `json:"suggestions"`
+	LastFeedbackAt time.Time            `json:"last_feedback_at"`
+}
+
+// FeedbackSuggestion 调用方建议的答案及建议次数
+type FeedbackSuggestion struct {
+	Answer string `json:"answer"`
+	Count  int64  `json:"count"`
+}
+
+// 答案来源类型
+const (
+	AnswerSourceModel    = "model"    // AI模型生成
+	AnswerSourceAdmin    = "admin"    // 管理员修改
+	AnswerSourceImport   = "import"   // 批量导入
+	AnswerSourceFeedback = "feedback" // 用户反馈
+)
+
+// AnswerSource 答案变更的来源信息
+type AnswerSource struct {
+	Source   string // 来源类型，见 AnswerSource* 常量
+	Platform string // AI平台
+	Model    string // 模型名称
+	Operator string // 操作的管理员
+	Reason   string // 变更原因
+
+	Confidence       *float64 // 置信度，为空表示未评估
+	PromptTokens     int      // 生成答案消耗的输入token
+	CompletionTokens int      // 生成答案消耗的输出token
+}
+
+// AnswerRevision 答案的一次历史变更
+type AnswerRevision struct {
+	ID             int64     `json:"id"`
+	QuestionID     int64     `json:"question_id"`
+	Answer         string    `json:"answer"`
+	PreviousAnswer string    `json:"previous_answer"`
+	Source         string    `json:"source"`
+	Platform       string    `json:"platform,omitempty"`
+	Model          string    `json:"model,omitempty"`
+	Operator       string    `json:"operator,omitempty"`
+	Reason         string    `json:"reason,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// SearchResult 题目检索结果，高亮字段已做HTML转义，命中部分以<mark>标记
+type SearchResult struct {
+	QuestionAnswer
+	Score               float64 `json:"score"`
+	HighlightedQuestion string  `json:"highlighted_question"`
+	Snippet             string  `json:"snippet,omitempty"`
+}
+
+// QueryLog 单次查询请求的日志记录
+type QueryLog struct {
+	ID               int64          `json:"id"`
+	APIKeyID         int64          `json:"api_key_id"`
+	APIKeyDesc       string         `json:"api_key_description,omitempty"`
+	Question         string         `json:"question"`
+	Options          string         `json:"options,omitempty"`
+	Type             string         `json:"type,omitempty"`
+	CacheHit         bool           `json:"cache_hit"`
+	Platform         string         `json:"platform,omitempty"`
+	Model            string         `json:"model,omitempty"`
+	LatencyMs        int64          `json:"latency_ms"`
+	PromptTokens     int            `json:"prompt_tokens"`
+	CompletionTokens int            `json:"completion_tokens"`
+	Status           string         `json:"status"` // ok 或 error
+	Error            string         `json:"error,omitempty"`
+	ClientIP         string         `json:"client_ip,omitempty"`
+	Metadata         *QueryMetadata `json:"metadata,omitempty"` // POST 查询时调用方附带的题目信息
+	CreatedAt        time.Time      `json:"created_at"`
+}
+
+// QueryMetadata 调用方附带的题目信息，只记录到查询日志
+type QueryMetadata struct {
+	Course   string   `json:"course,omitempty"`   // 课程名称
+	Platform string   `json:"platform,omitempty"` // 网课平台
+	Images   []string `json:"images,omitempty"`   // 题目中的图片地址
 }
 
 // LoadConfig 从文件加载配置
@@ -169,5 +637,92 @@ func LoadConfig(filePath string) (*Config, error) {
 		config.Admin.Username = "admin"
 	}
 
+	// 设置查询日志默认值
+	if config.QueryLog.RetentionDays <= 0 {
+		config.QueryLog.RetentionDays = 30
+	}
+
+	if config.QueryLog.BufferSize <= 0 {
+		config.QueryLog.BufferSize = 1024
+	}
+
+	// 设置定时备份默认值
+	if config.Backup.Dir == "" {
+		config.Backup.Dir = "backups"
+	}
+
+	if config.Backup.IntervalHours <= 0 {
+		config.Backup.IntervalHours = 24
+	}
+
+	if config.Backup.Keep <= 0 {
+		config.Backup.Keep = 7
+	}
+
+	// 设置答案复核默认值
+	if config.Verifier.Platform == "" {
+		config.Verifier.Platform = config.Platform
+	}
+
+	if config.Verifier.IntervalMinutes <= 0 {
+		config.Verifier.IntervalMinutes = 60
+	}
+
+	if config.Verifier.BatchSize <= 0 {
+		config.Verifier.BatchSize = 20
+	}
+
+	if config.Verifier.RequestsPerMinute <= 0 {
+		config.Verifier.RequestsPerMinute = 10
+	}
+
+	if config.Verifier.MaxConfidence <= 0 {
+		config.Verifier.MaxConfidence = 1
+	}
+
+	// 设置用户反馈默认值
+	if config.Feedback.WrongThreshold <= 0 {
+		config.Feedback.WrongThreshold = 3
+	}
+
+	if config.Feedback.WrongRatio <= 0 {
+		config.Feedback.WrongRatio = 0.5
+	}
+
+	if config.Feedback.Action == "" {
+		config.Feedback.Action = FeedbackActionDemote
+	}
+
+	// 设置平台熔断默认值
+	if config.ProviderHealth.FailureThreshold <= 0 {
+		config.ProviderHealth.FailureThreshold = 5
+	}
+
+	if config.ProviderHealth.CooldownSeconds <= 0 {
+		config.ProviderHealth.CooldownSeconds = 30
+	}
+
+	if config.ProviderHealth.Window <= 0 {
+		config.ProviderHealth.Window = 50
+	}
+
+	// 设置费用预算默认值
+	if config.Budget.WarnRatio <= 0 || config.Budget.WarnRatio > 1 {
+		config.Budget.WarnRatio = 0.8
+	}
+
+	if config.Budget.Action == "" {
+		config.Budget.Action = BudgetActionCacheOnly
+	}
+
+	// 设置批量查询默认值
+	if config.Batch.MaxQuestions <= 0 {
+		config.Batch.MaxQuestions = 50
+	}
+
+	if config.Batch.Concurrency <= 0 {
+		config.Batch.Concurrency = 4
+	}
+
 	return &config, nil
 }
\ No newline at end of file
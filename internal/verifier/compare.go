@@ -0,0 +1,65 @@
+package verifier
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// extractAnswer 从模型原始输出中取出答案文本，兼容代码块包裹和 anwser/answer 字段
+func extractAnswer(raw string) string {
+	s := strings.TrimSpace(raw)
+	s = strings.TrimPrefix(s, "```json")
+	s = strings.TrimPrefix(s, "```")
+	s = strings.TrimSuffix(s, "```")
+	s = strings.TrimSpace(s)
+
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &obj); err == nil {
+		for _, key := range []string{"anwser", "answer"} {
+			if v, ok := obj[key]; ok {
+				switch v := v.(type) {
+				case string:
+					return v
+				case []interface{}:
+					parts := make([]string, 0, len(v))
+					for _, p := range v {
+						if ps, ok := p.(string); ok {
+							parts = append(parts, ps)
+						}
+					}
+					return strings.Join(parts, "#")
+				}
+			}
+		}
+	}
+	return s
+}
+
+// normalizeAnswer 归一化答案：NFKC、转小写、去掉空白和标点；纯选项字母的答案按字母排序，
+// 使 "A,C" 与 "CA" 视为相同
+func normalizeAnswer(answer string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKC.String(answer) {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			continue
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	s := b.String()
+
+	if len(s) > 0 && len(s) <= 8 && strings.Trim(s, "abcdefgh") == "" {
+		letters := strings.Split(s, "")
+		sort.Strings(letters)
+		s = strings.Join(letters, "")
+	}
+	return s
+}
+
+// sameAnswer 判断两个答案是否一致
+func sameAnswer(a, b string) bool {
+	return normalizeAnswer(extractAnswer(a)) == normalizeAnswer(extractAnswer(b))
+}
@@ -0,0 +1,292 @@
+package verifier
+
+import (
+	"ai-ocs/internal/ai"
+	"ai-ocs/internal/budget"
+	"ai-ocs/internal/database"
+	"ai-ocs/internal/models"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Status 后台复核的运行状态，供管理后台展示
+type Status struct {
+	Enabled          bool       `json:"enabled"`
+	Paused           bool       `json:"paused"`
+	Running          bool       `json:"running"`
+	Platform         string     `json:"platform"`
+	Model            string     `json:"model"`
+	IntervalMinutes  int        `json:"interval_minutes"`
+	BatchSize        int        `json:"batch_size"`
+	RequestsPerMin   int        `json:"requests_per_minute"`
+	DailyTokenBudget int        `json:"daily_token_budget"`
+	TokensToday      int64      `json:"tokens_today"`
+	BudgetExhausted  bool       `json:"budget_exhausted"`
+	Pending          int64      `json:"pending"`
+	LastRunAt        *time.Time `json:"last_run_at,omitempty"`
+	NextRunAt        *time.Time `json:"next_run_at,omitempty"`
+	Processed        int64      `json:"processed"`
+	Agreed           int64      `json:"agreed"`
+	Mismatched       int64      `json:"mismatched"`
+	Errors           int64      `json:"errors"`
+	LastError        string     `json:"last_error,omitempty"`
+}
+
+var (
+	mu      sync.Mutex
+	status  Status
+	config  *models.Config
+	runNow  = make(chan struct{}, 1)
+	started bool
+)
+
+// Start 按配置启动后台复核；未启用时只记录配置，管理后台仍可查看状态
+func Start(cfg *models.Config) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	config = cfg
+	vc := cfg.Verifier
+	status.Enabled = vc.Enabled
+	status.Platform = vc.Platform
+	status.Model = vc.Model
+	if status.Model == "" {
+		status.Model = cfg.Models[vc.Platform]
+	}
+	status.IntervalMinutes = vc.IntervalMinutes
+	status.BatchSize = vc.BatchSize
+	status.RequestsPerMin = vc.RequestsPerMinute
+	status.DailyTokenBudget = vc.DailyTokenBudget
+
+	if !vc.Enabled || started {
+		return
+	}
+	started = true
+
+	log.Printf("已启用答案复核，使用 %s/%s，每 %d 分钟复核最多 %d 条", status.Platform, status.Model, vc.IntervalMinutes, vc.BatchSize)
+	go loop(time.Duration(vc.IntervalMinutes) * time.Minute)
+}
+
+// Pause 暂停复核，正在进行的一轮会在当前题目完成后停止
+func Pause() {
+	mu.Lock()
+	status.Paused = true
+	mu.Unlock()
+}
+
+// Resume 恢复复核
+func Resume() {
+	mu.Lock()
+	status.Paused = false
+	mu.Unlock()
+}
+
+// RunNow 立即开始一轮复核，已在运行时忽略
+func RunNow() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if !status.Enabled {
+		return fmt.Errorf("答案复核未启用")
+	}
+	if status.Paused {
+		return fmt.Errorf("答案复核已暂停")
+	}
+	select {
+	case runNow <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// GetStatus 返回当前状态，并刷新今日token用量和待复核数量
+func GetStatus() Status {
+	mu.Lock()
+	s := status
+	configured := config != nil
+	var criteria database.VerifyCriteria
+	if configured {
+		criteria = criteriaOf(config.Verifier)
+	}
+	mu.Unlock()
+
+	if tokens, err := database.VerificationTokensSince(startOfDay(time.Now())); err == nil {
+		s.TokensToday = tokens
+		s.BudgetExhausted = s.DailyTokenBudget > 0 && tokens >= int64(s.DailyTokenBudget)
+	}
+	if configured {
+		if pending, err := database.CountVerifyCandidates(criteria); err == nil {
+			s.Pending = pending
+		}
+	}
+	return s
+}
+
+// criteriaOf 由配置生成挑选条件
+func criteriaOf(vc models.VerifierConfig) database.VerifyCriteria {
+	return database.VerifyCriteria{
+		MaxConfidence:     vc.MaxConfidence,
+		MinAgeDays:        vc.MinAgeDays,
+		Models:            vc.Models,
+		OnlyDisputed:      vc.OnlyDisputed,
+		ReverifyAfterDays: vc.ReverifyAfterDays,
+	}
+}
+
+// loop 按间隔定时复核，或在收到 RunNow 信号时立即复核
+func loop(interval time.Duration) {
+	for {
+		next := time.Now().Add(interval)
+		mu.Lock()
+		status.NextRunAt = &next
+		mu.Unlock()
+
+		select {
+		case <-time.After(interval):
+		case <-runNow:
+		}
+
+		mu.Lock()
+		paused := status.Paused
+		mu.Unlock()
+		if paused {
+			continue
+		}
+		runBatch()
+	}
+}
+
+// runBatch 复核一批答案，受每分钟请求数和每日token预算限制
+func runBatch() {
+	mu.Lock()
+	vc := config.Verifier
+	apiKeys, modelMap := config.APIKeys, config.Models
+	now := time.Now()
+	status.Running = true
+	status.LastRunAt = &now
+	model := status.Model
+	mu.Unlock()
+
+	defer func() {
+		mu.Lock()
+		status.Running = false
+		mu.Unlock()
+	}()
+
+	candidates, err := database.ListVerifyCandidates(criteriaOf(vc), vc.BatchSize)
+	if err != nil {
+		setError(fmt.Sprintf("获取待复核答案失败: %v", err))
+		return
+	}
+	if len(candidates) == 0 {
+		return
+	}
+
+	delay := time.Minute / time.Duration(vc.RequestsPerMinute)
+	agreed, mismatched, failed := 0, 0, 0
+	for i, qa := range candidates {
+		mu.Lock()
+		paused := status.Paused
+		mu.Unlock()
+		if paused {
+			break
+		}
+
+		if vc.DailyTokenBudget > 0 {
+			used, err := database.VerificationTokensSince(startOfDay(time.Now()))
+			if err != nil {
+				setError(fmt.Sprintf("统计token用量失败: %v", err))
+				break
+			}
+			if used >= int64(vc.DailyTokenBudget) {
+				log.Printf("答案复核已达到今日token预算 %d，暂停到明天", vc.DailyTokenBudget)
+				break
+			}
+		}
+
+		// 全局费用预算熔断后不再复核
+		if tripped, _ := budget.Check(); tripped {
+			log.Printf("全局费用预算已熔断，暂停答案复核")
+			break
+		}
+
+		if i > 0 {
+			time.Sleep(delay)
+		}
+
+		v := verify(qa, vc.Platform, model, apiKeys, modelMap)
+		if err := database.RecordVerification(v); err != nil {
+			setError(fmt.Sprintf("保存复核结果失败: %v", err))
+			break
+		}
+
+		mu.Lock()
+		status.Processed++
+		switch v.Status {
+		case models.VerifyAgree:
+			status.Agreed++
+			agreed++
+		case models.VerifyMismatch:
+			status.Mismatched++
+			mismatched++
+		default:
+			status.Errors++
+			status.LastError = v.Error
+			failed++
+		}
+		mu.Unlock()
+	}
+
+	log.Printf("答案复核完成：一致 %d，不一致 %d，失败 %d", agreed, mismatched, failed)
+}
+
+// verify 用复核模型重新作答并与现有答案比对
+func verify(qa *models.QuestionAnswer, platform, model string, apiKeys, modelMap map[string]string) *models.AnswerVerification {
+	v := &models.AnswerVerification{
+		QuestionID: qa.ID,
+		Answer:     qa.Answer,
+		Platform:   platform,
+		Model:      model,
+	}
+
+	var options, questionType string
+	if qa.Options != nil {
+		options = *qa.Options
+	}
+	if qa.Type != nil {
+		questionType = *qa.Type
+	}
+
+	result, err := ai.QueryModel(qa.Question, options, questionType, platform, model, apiKeys, modelMap)
+	if err != nil {
+		v.Status = models.VerifyError
+		v.Error = err.Error()
+		return v
+	}
+
+	v.VerifierAnswer = result.Answer
+	v.Model = result.Model
+	v.PromptTokens = result.Usage.PromptTokens
+	v.CompletionTokens = result.Usage.CompletionTokens
+	if sameAnswer(qa.Answer, result.Answer) {
+		v.Status = models.VerifyAgree
+	} else {
+		v.Status = models.VerifyMismatch
+	}
+	return v
+}
+
+// setError 记录最近一次错误
+func setError(msg string) {
+	log.Print(msg)
+	mu.Lock()
+	status.LastError = msg
+	mu.Unlock()
+}
+
+// startOfDay 返回当天零点（本地时间）
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}